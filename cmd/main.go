@@ -14,5 +14,7 @@ func main() {
 	db := setup.SetupDatabase(logger)
 	defer db.Close()
 
-	server.ServerLaunch(db, logger)
+	images := setup.SetupStorage(logger)
+
+	server.ServerLaunch(db, images, logger)
 }
@@ -8,22 +8,148 @@ END
 $$;
 
 CREATE TYPE order_status AS ENUM ('open', 'closed');
-CREATE TYPE unit_types AS ENUM ('ml', 'shots', 'g');
+CREATE TYPE unit_dimension AS ENUM ('mass', 'volume', 'count');
+CREATE TYPE order_channel AS ENUM ('dine_in', 'takeaway', 'delivery', 'online');
+CREATE TYPE check_status AS ENUM ('open', 'paid');
+CREATE TYPE item_status AS ENUM ('queued', 'in_progress', 'done', 'served');
+CREATE TYPE price_change_mode AS ENUM ('percent', 'amount', 'set');
+CREATE TYPE price_change_status AS ENUM ('pending', 'applied', 'failed', 'cancelled');
+
+CREATE TABLE menu_categories (
+    ID SERIAL PRIMARY KEY,
+    Name VARCHAR(50) NOT NULL UNIQUE,
+    ParentID INT,
+    DisplayOrder INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (ParentID) REFERENCES menu_categories(ID) ON DELETE SET NULL
+);
 
 CREATE TABLE menu_items (
     ID SERIAL PRIMARY KEY,
     Name VARCHAR(50) NOT NULL,
     Description TEXT NOT NULL,
     Price NUMERIC(10, 2) NOT NULL CHECK(Price > 0),
-    Image VARCHAR(255) DEFAULT 'uploads/default.jpg'
+    Image VARCHAR(255) DEFAULT 'uploads/default.jpg',
+    CategoryID INT,
+    Position INT NOT NULL DEFAULT 0,
+    -- Set while a manager has manually taken the item off the menu ("86"ed it)
+    EightySixReason TEXT,
+    EightySixedAt TIMESTAMP,
+    -- Archived items are off the menu for good but stay referenced by past orders and reports
+    ArchivedAt TIMESTAMP,
+    -- Recipe version currently mirrored into menu_item_ingredients
+    RecipeVersionID INT,
+    -- Set for items that must not be made with substitutes of ingredients that ran out
+    NoSubstitutes BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Row version for optimistic concurrency, incremented on every write
+    Version INT NOT NULL DEFAULT 1,
+    FOREIGN KEY (CategoryID) REFERENCES menu_categories(ID) ON DELETE SET NULL
 );
 
+-- Units of measure. Factor converts one unit into the base unit of its dimension: grams for
+-- mass, millilitres for volume and pieces for count. Decimals is the number of decimals stock
+-- counted in the unit is kept to; what an order uses is rounded up to it.
+CREATE TABLE units (
+    Code VARCHAR(20) PRIMARY KEY,
+    Name VARCHAR(50) NOT NULL,
+    Dimension unit_dimension NOT NULL,
+    Factor NUMERIC(14, 6) NOT NULL CHECK(Factor > 0),
+    Decimals INT NOT NULL DEFAULT 3 CHECK(Decimals BETWEEN 0 AND 3)
+);
+
+INSERT INTO units (Code, Name, Dimension, Factor, Decimals) VALUES
+('g', 'gram', 'mass', 1, 1),
+('kg', 'kilogram', 'mass', 1000, 3),
+('oz', 'ounce', 'mass', 28.349523, 2),
+('lb', 'pound', 'mass', 453.59237, 3),
+('ml', 'millilitre', 'volume', 1, 0),
+('l', 'litre', 'volume', 1000, 3),
+('fl_oz', 'fluid ounce', 'volume', 29.573530, 2),
+('tsp', 'teaspoon', 'volume', 4.928922, 2),
+('tbsp', 'tablespoon', 'volume', 14.786765, 2),
+('shots', 'espresso shot', 'volume', 30, 0),
+('pc', 'piece', 'count', 1, 0),
+('dozen', 'dozen', 'count', 12, 3);
 
 CREATE TABLE inventory (
     IngredientID SERIAL PRIMARY KEY,
     Name VARCHAR(50) NOT NULL,
-    Quantity INT NOT NULL CHECK(Quantity >= 0),
-    Unit unit_types NOT NULL
+    Quantity NUMERIC(12, 3) NOT NULL CHECK(Quantity >= 0),
+    -- Stock unit. Quantity, UnitCost and recipe usage are all counted in it.
+    Unit VARCHAR(20) NOT NULL REFERENCES units(Code),
+    -- Grams per millilitre, needed to convert between mass and volume
+    Density NUMERIC(10, 4) CHECK(Density > 0),
+    -- What one unit of the ingredient costs the shop: the weighted average cost of the stock,
+    -- updated on every restock
+    UnitCost NUMERIC(14, 8) NOT NULL DEFAULT 0 CHECK(UnitCost >= 0),
+    -- What one unit cost on the last restock
+    PurchaseCost NUMERIC(14, 8) NOT NULL DEFAULT 0 CHECK(PurchaseCost >= 0),
+    Version INT NOT NULL DEFAULT 1
+);
+
+-- Pack units that only make sense for one ingredient, e.g. a bag of coffee beans. Quantity is
+-- how much of the ingredient's stock unit one pack holds.
+CREATE TABLE ingredient_units (
+    IngredientID INT NOT NULL,
+    Code VARCHAR(20) NOT NULL,
+    Quantity NUMERIC(14, 6) NOT NULL CHECK(Quantity > 0),
+    PRIMARY KEY (IngredientID, Code),
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+-- Ingredients that stand in for another one when it runs out, tried in Priority order. Ratio is
+-- how much of the substitute replaces one unit of the original.
+CREATE TABLE ingredient_substitutions (
+    IngredientID INT NOT NULL,
+    SubstituteID INT NOT NULL,
+    Ratio NUMERIC(10, 4) NOT NULL DEFAULT 1 CHECK(Ratio > 0),
+    Priority INT NOT NULL,
+    PRIMARY KEY (IngredientID, SubstituteID),
+    CHECK(IngredientID <> SubstituteID),
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE,
+    FOREIGN KEY (SubstituteID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+-- Names and descriptions of menu items in other languages. menu_items itself holds the
+-- English text.
+CREATE TABLE menu_item_translations (
+    MenuID INT NOT NULL,
+    Locale VARCHAR(5) NOT NULL CHECK (Locale IN ('ru', 'kk', 'de', 'fr', 'es')),
+    Name VARCHAR(100) NOT NULL,
+    Description TEXT NOT NULL,
+    PRIMARY KEY (MenuID, Locale),
+    FOREIGN KEY (MenuID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+-- Recipes are versioned. The version in effect is the latest one whose EffectiveFrom has passed,
+-- and its ingredients are mirrored into menu_item_ingredients.
+CREATE TABLE recipe_versions (
+    ID SERIAL PRIMARY KEY,
+    MenuID INT NOT NULL,
+    Version INT NOT NULL,
+    EffectiveFrom TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    Note TEXT,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (MenuID, Version),
+    FOREIGN KEY (MenuID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+CREATE TABLE recipe_version_ingredients (
+    VersionID INT NOT NULL,
+    IngredientID INT NOT NULL,
+    Quantity NUMERIC(12, 3) NOT NULL CHECK(Quantity > 0),
+    -- Unit the quantity is given in. It is always stored, so changing the ingredient's stock
+    -- unit later does not change what the recipe means.
+    Unit VARCHAR(20) NOT NULL,
+    PRIMARY KEY (VersionID, IngredientID),
+    FOREIGN KEY (VersionID) REFERENCES recipe_versions(ID) ON DELETE CASCADE,
+    -- Past recipes are kept for the ingredient usage report, so their ingredients cannot be deleted
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE RESTRICT
+);
+
+CREATE TABLE dining_tables (
+    ID SERIAL PRIMARY KEY,
+    Name VARCHAR(20) NOT NULL UNIQUE,
+    Seats INT NOT NULL CHECK(Seats > 0)
 );
 
 CREATE TABLE orders (
@@ -31,16 +157,109 @@ CREATE TABLE orders (
     CustomerName VARCHAR(50) NOT NULL,
     Status order_status DEFAULT 'open',
     Notes JSONB, 
-    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    Channel order_channel NOT NULL DEFAULT 'dine_in',
+    TableID INT,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    Version INT NOT NULL DEFAULT 1,
+    FOREIGN KEY (TableID) REFERENCES dining_tables(ID) ON DELETE SET NULL
 );
 
 CREATE TABLE order_items (
+    ID SERIAL PRIMARY KEY,
     OrderID INT,
     ProductID INT NOT NULL,
+    Seat INT NOT NULL DEFAULT 0 CHECK(Seat >= 0),
     Quantity INT NOT NULL CHECK(Quantity > 0),
-    PRIMARY KEY (OrderID, ProductID),
+    Price NUMERIC(10, 2) CHECK(Price > 0),
+    Status item_status NOT NULL DEFAULT 'queued',
+    StatusChangedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- Recipe the line was made with
+    RecipeVersionID INT,
+    UNIQUE (OrderID, ProductID, Seat),
     FOREIGN KEY (OrderID) REFERENCES orders(ID) ON DELETE CASCADE , 
-    FOREIGN KEY (ProductID) REFERENCES menu_items(ID) ON DELETE CASCADE
+    FOREIGN KEY (ProductID) REFERENCES menu_items(ID) ON DELETE RESTRICT,
+    FOREIGN KEY (RecipeVersionID) REFERENCES recipe_versions(ID) ON DELETE SET NULL
+);
+
+-- Components sold as part of a bundle line, with the share of the line's revenue each one earned.
+CREATE TABLE order_item_components (
+    OrderItemID INT NOT NULL,
+    ProductID INT NOT NULL,
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    Revenue NUMERIC(10, 2) NOT NULL,
+    RecipeVersionID INT,
+    PRIMARY KEY (OrderItemID, ProductID),
+    FOREIGN KEY (OrderItemID) REFERENCES order_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (ProductID) REFERENCES menu_items(ID) ON DELETE RESTRICT,
+    FOREIGN KEY (RecipeVersionID) REFERENCES recipe_versions(ID) ON DELETE SET NULL
+);
+
+-- Ingredients an order line was made with in place of recipe ingredients that had run out,
+-- with the amount of the original they replaced.
+CREATE TABLE order_item_substitutions (
+    OrderItemID INT NOT NULL,
+    IngredientID INT NOT NULL,
+    SubstituteID INT NOT NULL,
+    ReplacedQuantity NUMERIC(12, 3) NOT NULL CHECK(ReplacedQuantity > 0),
+    Quantity NUMERIC(12, 3) NOT NULL CHECK(Quantity > 0),
+    PRIMARY KEY (OrderItemID, IngredientID, SubstituteID),
+    FOREIGN KEY (OrderItemID) REFERENCES order_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) ON DELETE CASCADE,
+    FOREIGN KEY (SubstituteID) REFERENCES inventory(IngredientID) ON DELETE CASCADE
+);
+
+-- Checks an order was split into. The order keeps its items, so revenue and
+-- inventory are only ever counted once; checks just divide the bill.
+CREATE TABLE order_checks (
+    ID SERIAL PRIMARY KEY,
+    OrderID INT NOT NULL,
+    Label VARCHAR(50) NOT NULL,
+    Amount NUMERIC(10, 2) NOT NULL CHECK(Amount >= 0),
+    Status check_status NOT NULL DEFAULT 'open',
+    PaidAt TIMESTAMP,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (OrderID) REFERENCES orders(ID) ON DELETE CASCADE
+);
+
+CREATE TABLE order_check_items (
+    CheckID INT NOT NULL,
+    ProductID INT NOT NULL,
+    Seat INT NOT NULL DEFAULT 0,
+    Quantity INT NOT NULL CHECK(Quantity > 0),
+    Price NUMERIC(10, 2) NOT NULL,
+    PRIMARY KEY (CheckID, ProductID, Seat),
+    FOREIGN KEY (CheckID) REFERENCES order_checks(ID) ON DELETE CASCADE,
+    FOREIGN KEY (ProductID) REFERENCES menu_items(ID) ON DELETE RESTRICT
+);
+
+-- Audit trail of every change to an order and its items. There is no foreign key
+-- on OrderID so the trail survives when the order itself is deleted.
+CREATE TABLE order_history (
+    ID SERIAL PRIMARY KEY,
+    OrderID INT NOT NULL,
+    Action VARCHAR(20) NOT NULL,
+    Actor VARCHAR(50) NOT NULL,
+    Reason TEXT,
+    Before JSONB,
+    After JSONB,
+    ChangedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Price changes planned for a future date. Without a category or product list the change
+-- applies to the whole menu.
+CREATE TABLE scheduled_price_changes (
+    ID SERIAL PRIMARY KEY,
+    EffectiveAt TIMESTAMP NOT NULL,
+    Mode price_change_mode NOT NULL,
+    Value NUMERIC(10, 2) NOT NULL,
+    CategoryID INT,
+    ProductIDs INT[],
+    Note TEXT,
+    Status price_change_status NOT NULL DEFAULT 'pending',
+    AppliedAt TIMESTAMP,
+    Error TEXT,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (CategoryID) REFERENCES menu_categories(ID) ON DELETE SET NULL
 );
 
 CREATE TABLE price_history (
@@ -49,18 +268,67 @@ CREATE TABLE price_history (
     old_price NUMERIC(10, 2) NOT NULL CHECK(old_price > 0),
     new_price NUMERIC(10, 2) NOT NULL CHECK(new_price > 0),
     ChangedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (Menu_ItemID) REFERENCES menu_items(ID) ON DELETE CASCADE 
+    ScheduledChangeID INT,
+    FOREIGN KEY (Menu_ItemID) REFERENCES menu_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (ScheduledChangeID) REFERENCES scheduled_price_changes(ID) ON DELETE SET NULL
 );
 
 CREATE TABLE menu_item_ingredients (
     MenuID INT,
     IngredientID INT NOT NULL,
-    Quantity INT NOT NULL CHECK(Quantity > 0),
+    Quantity NUMERIC(12, 3) NOT NULL CHECK(Quantity > 0),
+    Unit VARCHAR(20) NOT NULL,
     PRIMARY KEY (MenuID, IngredientID),
     FOREIGN KEY (MenuID) REFERENCES menu_items(ID) ON DELETE CASCADE,
     FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) on DELETE CASCADE
 );
 
+-- Bundle slots. A menu item with slots is a bundle sold at its own price; each slot is either
+-- a fixed product or a choice of any product from a category.
+CREATE TABLE menu_item_bundle_slots (
+    ID SERIAL PRIMARY KEY,
+    BundleID INT NOT NULL,
+    ProductID INT,
+    CategoryID INT,
+    Quantity INT NOT NULL DEFAULT 1 CHECK(Quantity > 0),
+    CHECK ((ProductID IS NULL) <> (CategoryID IS NULL)),
+    FOREIGN KEY (BundleID) REFERENCES menu_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (ProductID) REFERENCES menu_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (CategoryID) REFERENCES menu_categories(ID) ON DELETE CASCADE
+);
+
+-- Availability windows. Items without a schedule are always available; otherwise any matching
+-- schedule makes them available. Days use ISO numbering (1 = Monday ... 7 = Sunday).
+CREATE TABLE menu_item_schedules (
+    ID SERIAL PRIMARY KEY,
+    MenuID INT NOT NULL,
+    Days INT[],
+    StartTime TIME,
+    EndTime TIME,
+    StartDate DATE,
+    EndDate DATE,
+    CHECK (StartDate IS NULL OR EndDate IS NULL OR StartDate <= EndDate),
+    FOREIGN KEY (MenuID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+-- Channel-specific price overrides. Channels without a row use menu_items.Price.
+CREATE TABLE menu_item_channel_prices (
+    MenuID INT,
+    Channel order_channel NOT NULL,
+    Price NUMERIC(10, 2) NOT NULL CHECK(Price > 0),
+    PRIMARY KEY (MenuID, Channel),
+    FOREIGN KEY (MenuID) REFERENCES menu_items(ID) ON DELETE CASCADE
+);
+
+-- Packaging deducted from inventory for takeaway, delivery and online orders.
+CREATE TABLE menu_item_packaging (
+    MenuID INT,
+    IngredientID INT NOT NULL,
+    Quantity NUMERIC(12, 3) NOT NULL CHECK(Quantity > 0),
+    PRIMARY KEY (MenuID, IngredientID),
+    FOREIGN KEY (MenuID) REFERENCES menu_items(ID) ON DELETE CASCADE,
+    FOREIGN KEY (IngredientID) REFERENCES inventory(IngredientID) on DELETE CASCADE
+);
 
 CREATE TABLE order_status_history (
     ID SERIAL PRIMARY KEY,
@@ -73,32 +341,60 @@ CREATE TABLE order_status_history (
 CREATE TABLE inventory_transactions (
     transactionId SERIAL PRIMARY KEY,
     IngredientID INT REFERENCES inventory(IngredientID) ON DELETE CASCADE,
-    quantity_change FLOAT NOT NULL,
+    quantity_change NUMERIC(12, 3) NOT NULL,
+    -- Unit cost of the ingredient after the change, used to value stock at past dates
+    unit_cost NUMERIC(14, 8) NOT NULL DEFAULT 0,
     reason TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
 -- menu_items
 CREATE INDEX idx_menu_items_name ON menu_items (Name);
+CREATE INDEX idx_menu_items_category_id ON menu_items (CategoryID, Position);
 
 -- inventory
 CREATE INDEX idx_inventory_name ON inventory (Name);
+CREATE INDEX idx_inventory_transactions_ingredient_id ON inventory_transactions (IngredientID, created_at);
 
 -- orders
 CREATE INDEX idx_orders_customer_name ON orders (CustomerName);
 CREATE INDEX idx_orders_status ON orders (Status);
 CREATE INDEX idx_orders_created_at ON orders (CreatedAt);
+CREATE INDEX idx_orders_channel ON orders (Channel);
+CREATE INDEX idx_orders_table_id ON orders (TableID);
 
 -- order_items
 CREATE INDEX idx_order_items_order_id ON order_items (OrderID);
 CREATE INDEX idx_order_items_product_id ON order_items (ProductID);
 
+-- order_checks
+CREATE INDEX idx_order_checks_order_id ON order_checks (OrderID);
+
+-- order_history
+CREATE INDEX idx_order_history_order_id ON order_history (OrderID, ChangedAt);
+
+-- price_history
+CREATE INDEX idx_price_history_menu_item_id ON price_history (Menu_ItemID, ChangedAt);
+CREATE INDEX idx_price_history_changed_at ON price_history (ChangedAt);
+
+-- scheduled_price_changes
+CREATE INDEX idx_scheduled_price_changes_pending ON scheduled_price_changes (EffectiveAt) WHERE Status = 'pending';
+
 -- menu_item_ingredients
 CREATE INDEX idx_menu_item_ingredients_menu_id ON menu_item_ingredients (MenuID);
+CREATE INDEX idx_recipe_versions_menu_id ON recipe_versions (MenuID, EffectiveFrom);
+CREATE INDEX idx_menu_item_schedules_menu_id ON menu_item_schedules (MenuID);
+CREATE INDEX idx_menu_item_bundle_slots_bundle_id ON menu_item_bundle_slots (BundleID);
 CREATE INDEX idx_menu_item_ingredients_ingredient_id ON menu_item_ingredients (IngredientID);
 
 -- search indexes for full text search
 CREATE INDEX idx_menu_item_search_id on menu_items using gin(to_tsvector('english' , name || ' ' || COALESCE(description, '')));
+-- one index per locale, each built with the text search configuration of its language
+CREATE INDEX idx_menu_item_translations_search_ru ON menu_item_translations USING gin(to_tsvector('russian', Name || ' ' || Description)) WHERE Locale = 'ru';
+CREATE INDEX idx_menu_item_translations_search_kk ON menu_item_translations USING gin(to_tsvector('simple', Name || ' ' || Description)) WHERE Locale = 'kk';
+CREATE INDEX idx_menu_item_translations_search_de ON menu_item_translations USING gin(to_tsvector('german', Name || ' ' || Description)) WHERE Locale = 'de';
+CREATE INDEX idx_menu_item_translations_search_fr ON menu_item_translations USING gin(to_tsvector('french', Name || ' ' || Description)) WHERE Locale = 'fr';
+CREATE INDEX idx_menu_item_translations_search_es ON menu_item_translations USING gin(to_tsvector('spanish', Name || ' ' || Description)) WHERE Locale = 'es';
 
 
 -- Функция для логирования изменения цены в price_history
@@ -106,8 +402,10 @@ CREATE OR REPLACE FUNCTION log_price_change()
 RETURNS TRIGGER AS $$
 BEGIN
     IF NEW.price <> OLD.price THEN
-        INSERT INTO price_history (Menu_ItemID, old_price, new_price, ChangedAt)
-        VALUES (OLD.ID,  OLD.price, NEW.price, CURRENT_TIMESTAMP);
+        -- Scheduled changes set app.price_change_id for their transaction
+        INSERT INTO price_history (Menu_ItemID, old_price, new_price, ChangedAt, ScheduledChangeID)
+        VALUES (OLD.ID,  OLD.price, NEW.price, CURRENT_TIMESTAMP,
+                NULLIF(current_setting('app.price_change_id', true), '')::int);
     END IF;
     RETURN NEW;
 END;
@@ -139,9 +437,11 @@ EXECUTE FUNCTION insert_order_status_history();
 CREATE OR REPLACE FUNCTION update_order_status_history()
 RETURNS TRIGGER AS $$
 BEGIN
-    UPDATE order_status_history
-    SET ClosedAt = CURRENT_TIMESTAMP
-    WHERE OrderID = NEW.ID AND ClosedAt IS NULL;
+    IF NEW.Status = 'closed' AND OLD.Status <> 'closed' THEN
+        UPDATE order_status_history
+        SET ClosedAt = CURRENT_TIMESTAMP
+        WHERE OrderID = NEW.ID AND ClosedAt IS NULL;
+    END IF;
     RETURN NEW;
 END;
 $$ LANGUAGE plpgsql;
@@ -158,21 +458,24 @@ RETURNS TRIGGER AS $$
 BEGIN
 
     IF TG_OP = 'UPDATE' THEN
-        IF NEW.quantity <> OLD.quantity THEN
-            INSERT INTO inventory_transactions(IngredientID, quantity_change, reason, created_at)
+        -- Writes can name their reason with set_config('inventory.reason', ..., true)
+        IF NEW.quantity <> OLD.quantity OR NEW.unitcost <> OLD.unitcost THEN
+            INSERT INTO inventory_transactions(IngredientID, quantity_change, unit_cost, reason, created_at)
             VALUES (
                 OLD.IngredientID,
                 NEW.quantity - OLD.quantity,
-                'Inventory adjustment',
+                NEW.unitcost,
+                COALESCE(NULLIF(current_setting('inventory.reason', true), ''), 'Inventory adjustment'),
                 CURRENT_TIMESTAMP
             );
         END IF;
 
     ELSIF TG_OP = 'INSERT' THEN
-        INSERT INTO inventory_transactions(IngredientID, quantity_change, reason, created_at)
+        INSERT INTO inventory_transactions(IngredientID, quantity_change, unit_cost, reason, created_at)
         VALUES (
             NEW.IngredientID,
             NEW.quantity,
+            NEW.unitcost,
             'Initial stock',
             CURRENT_TIMESTAMP
         );
@@ -187,6 +490,122 @@ AFTER INSERT OR UPDATE ON inventory
 FOR EACH ROW
 EXECUTE FUNCTION log_inventory_transaction();
 
+-- Converts a quantity given in any unit into the stock unit of an ingredient. Pack units of the
+-- ingredient are tried first, then units of the same dimension, then mass and volume through
+-- the ingredient's density. Returns NULL when the unit cannot be converted.
+CREATE OR REPLACE FUNCTION convert_to_stock_unit(p_quantity NUMERIC, p_unit VARCHAR, p_ingredient INT)
+RETURNS NUMERIC AS $$
+DECLARE
+    v_stock units%ROWTYPE;
+    v_from units%ROWTYPE;
+    v_density NUMERIC;
+    v_pack NUMERIC;
+BEGIN
+    SELECT u.* INTO v_stock
+    FROM inventory i JOIN units u ON u.Code = i.Unit
+    WHERE i.IngredientID = p_ingredient;
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+    SELECT i.Density INTO v_density FROM inventory i WHERE i.IngredientID = p_ingredient;
+    IF p_unit IS NULL OR p_unit = v_stock.Code THEN
+        RETURN p_quantity;
+    END IF;
+
+    SELECT iu.Quantity INTO v_pack FROM ingredient_units iu
+    WHERE iu.IngredientID = p_ingredient AND iu.Code = p_unit;
+    IF FOUND THEN
+        RETURN p_quantity * v_pack;
+    END IF;
+
+    SELECT * INTO v_from FROM units WHERE Code = p_unit;
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+    IF v_from.Dimension = v_stock.Dimension THEN
+        RETURN p_quantity * v_from.Factor / v_stock.Factor;
+    ELSIF v_density IS NULL THEN
+        RETURN NULL;
+    ELSIF v_from.Dimension = 'volume' AND v_stock.Dimension = 'mass' THEN
+        RETURN p_quantity * v_from.Factor * v_density / v_stock.Factor;
+    ELSIF v_from.Dimension = 'mass' AND v_stock.Dimension = 'volume' THEN
+        RETURN p_quantity * v_from.Factor / v_density / v_stock.Factor;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- Rounds a quantity up to the decimals kept for a unit, so an order never uses less than its
+-- recipe asks for.
+CREATE OR REPLACE FUNCTION round_to_unit(p_quantity NUMERIC, p_unit VARCHAR)
+RETURNS NUMERIC AS $$
+    SELECT CEIL(p_quantity * POWER(10::numeric, u.Decimals)) / POWER(10::numeric, u.Decimals)
+    FROM units u WHERE u.Code = p_unit;
+$$ LANGUAGE sql STABLE;
+
+
+-- Row versions behind the ETags of menu items, inventory items and orders. Every update
+-- increments the version, whatever the statement sets it to.
+CREATE OR REPLACE FUNCTION bump_row_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.Version := OLD.Version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER menu_items_version_trigger
+BEFORE UPDATE ON menu_items
+FOR EACH ROW
+EXECUTE FUNCTION bump_row_version();
+
+CREATE TRIGGER inventory_version_trigger
+BEFORE UPDATE ON inventory
+FOR EACH ROW
+EXECUTE FUNCTION bump_row_version();
+
+CREATE TRIGGER orders_version_trigger
+BEFORE UPDATE ON orders
+FOR EACH ROW
+EXECUTE FUNCTION bump_row_version();
+
+-- Translations are part of the menu item, and lines and checks part of the order, so
+-- changing them bumps the version of the row they belong to.
+CREATE OR REPLACE FUNCTION bump_parent_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'menu_item_translations' THEN
+        UPDATE menu_items SET Version = Version WHERE ID = COALESCE(NEW.MenuID, OLD.MenuID);
+    ELSE
+        UPDATE orders SET Version = Version WHERE ID = COALESCE(NEW.OrderID, OLD.OrderID);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER menu_item_translations_version_trigger
+AFTER INSERT OR UPDATE OR DELETE ON menu_item_translations
+FOR EACH ROW
+EXECUTE FUNCTION bump_parent_version();
+
+CREATE TRIGGER order_items_version_trigger
+AFTER INSERT OR UPDATE OR DELETE ON order_items
+FOR EACH ROW
+EXECUTE FUNCTION bump_parent_version();
+
+CREATE TRIGGER order_checks_version_trigger
+AFTER INSERT OR UPDATE OR DELETE ON order_checks
+FOR EACH ROW
+EXECUTE FUNCTION bump_parent_version();
+
+-- Mock data for menu_categories
+INSERT INTO menu_categories (Name, ParentID, DisplayOrder) VALUES
+('Coffee', NULL, 1),
+('Espresso Drinks', 1, 1),
+('Brewed Coffee', 1, 2),
+('Pastries', NULL, 2),
+('Savory', NULL, 3),
+('Deals', NULL, 4);
 
 -- Mock data for menu_items
 INSERT INTO menu_items (Name, Description, Price,Image) VALUES
@@ -206,61 +625,140 @@ INSERT INTO menu_items (Name, Description, Price,Image) VALUES
 ('Ham & Cheese Sandwich', 'Classic sandwich with ham and cheese', 4.50, 'uploads/sandwich.jpg'),
 ('Oatmeal Cookie', 'Soft and chewy oatmeal cookie', 2.30, 'uploads/oatmealcookie.jpg');
 
+UPDATE menu_items m SET CategoryID = c.CategoryID, Position = c.Position
+FROM (VALUES
+    ('Espresso', 2, 1), ('Americano', 2, 2), ('Cappuccino', 2, 3), ('Caffe Latte', 2, 4),
+    ('Vanilla Latte', 2, 5), ('Mocha', 2, 6), ('Iced Latte', 2, 7),
+    ('Black Coffee', 3, 1),
+    ('Blueberry Muffin', 4, 1), ('Chocolate Croissant', 4, 2), ('Carrot Cake', 4, 3), ('Oatmeal Cookie', 4, 4),
+    ('Cheese Croissant', 5, 1), ('Bagel with Cream Cheese', 5, 2), ('Ham & Cheese Sandwich', 5, 3)
+) AS c(Name, CategoryID, Position)
+WHERE m.Name = c.Name;
+
+-- Mock data for menu_item_translations
+INSERT INTO menu_item_translations (MenuID, Locale, Name, Description)
+SELECT m.ID, t.Locale, t.Name, t.Description
+FROM (VALUES
+    ('Caffe Latte', 'ru', 'Латте', 'Эспрессо со вспененным молоком'),
+    ('Espresso', 'ru', 'Эспрессо', 'Крепкий и насыщенный кофе'),
+    ('Cappuccino', 'ru', 'Капучино', 'Капучино с молоком и пенкой'),
+    ('Blueberry Muffin', 'ru', 'Черничный маффин', 'Свежий маффин с черникой'),
+    ('Carrot Cake', 'ru', 'Морковный торт', 'Пряный торт с кремом из сливочного сыра'),
+    ('Caffe Latte', 'kk', 'Латте', 'Көпіршітілген сүт қосылған эспрессо'),
+    ('Espresso', 'kk', 'Эспрессо', 'Күшті әрі қою кофе'),
+    ('Caffe Latte', 'de', 'Milchkaffee', 'Espresso mit aufgeschäumter Milch'),
+    ('Blueberry Muffin', 'de', 'Blaubeermuffin', 'Frisch gebackener Muffin mit Blaubeeren'),
+    ('Carrot Cake', 'de', 'Karottenkuchen', 'Gewürzter Kuchen mit Frischkäse-Glasur')
+) AS t(Item, Locale, Name, Description)
+JOIN menu_items m ON m.Name = t.Item;
+
+-- Mock data for menu_item_schedules: breakfast items are sold until 11:00
+INSERT INTO menu_item_schedules (MenuID, Days, StartTime, EndTime)
+SELECT ID, NULL, '06:00', '11:00' FROM menu_items WHERE Name IN ('Bagel with Cream Cheese', 'Ham & Cheese Sandwich');
+
 -- Mock data for inventory
-INSERT INTO inventory (Name, Quantity, Unit) VALUES
-('Espresso Shot', 500, 'shots'),
-('Milk', 5000, 'ml'),
-('Flour', 10000, 'g'),
-('Blueberries', 2000, 'g'),
-('Sugar', 5000, 'g'),
-('Butter', 3000, 'g'),
-('Chocolate', 1500, 'g'),
-('Coffee Beans', 2000, 'g'),
-('Cocoa Powder', 1000, 'g'),
-('Vanilla Syrup', 800, 'ml'),
-('Cheese', 2000, 'g'),
-('Bagels', 5000, 'g'),
-('Ham', 3000, 'g'),
-('Oats', 2500, 'g');
+INSERT INTO inventory (Name, Quantity, Unit, Density, UnitCost, PurchaseCost) VALUES
+('Espresso Shot', 500, 'shots', NULL, 0.25, 0.25),
+('Milk', 5000, 'ml', 1.03, 0.0012, 0.0012),
+('Flour', 10000, 'g', NULL, 0.002, 0.002),
+('Blueberries', 2000, 'g', NULL, 0.012, 0.012),
+('Sugar', 5000, 'g', NULL, 0.0015, 0.0015),
+('Butter', 3000, 'g', NULL, 0.009, 0.009),
+('Chocolate', 1500, 'g', NULL, 0.015, 0.015),
+('Coffee Beans', 2000, 'g', NULL, 0.02, 0.02),
+('Cocoa Powder', 1000, 'g', NULL, 0.012, 0.012),
+('Vanilla Syrup', 800, 'ml', 1.3, 0.01, 0.01),
+('Cheese', 2000, 'g', NULL, 0.012, 0.012),
+('Bagels', 5000, 'g', NULL, 0.00318182, 0.00318182),
+('Ham', 3000, 'g', NULL, 0.015, 0.015),
+('Oats', 2500, 'g', NULL, 0.004, 0.004),
+('Skim Milk', 3000, 'ml', 1.03, 0.001, 0.001),
+('Oat Milk', 2000, 'ml', 1.03, 0.003, 0.003);
+
+-- Pack units: coffee beans are bought in 1 kg bags and a bagel weighs 110 g
+INSERT INTO ingredient_units (IngredientID, Code, Quantity) VALUES
+(8, 'bag', 1000),
+(12, 'bagel', 110);
+
+-- When milk runs out, lattes are made with skim milk, then with oat milk
+INSERT INTO ingredient_substitutions (IngredientID, SubstituteID, Ratio, Priority) VALUES
+(2, 15, 1, 1),
+(2, 16, 1, 2);
 
 
 
 -- Mock data for menu_item_ingredients
-INSERT INTO menu_item_ingredients (MenuID, IngredientID, Quantity) VALUES
-(1, 1, 1),  -- Caffe Latte: 1 Espresso Shot
-(1, 2, 200),  -- Caffe Latte: 200 ml Milk
-(2, 3, 100),  -- Blueberry Muffin: 100 g Flour
-(2, 4, 20),  -- Blueberry Muffin: 20 g Butter
-(2, 5, 30),  -- Blueberry Muffin: 30 g Sugar
-(3, 1, 1),  -- Espresso: 1 Espresso Shot
-(4, 1, 1),  -- Cappuccino: 1 Espresso Shot
-(4, 2, 200),  -- Cappuccino: 200 ml Milk
-(5, 1, 1),  -- Mocha: 1 Espresso Shot
-(5, 2, 200),  -- Mocha: 200 ml Milk
-(5, 6, 30),  -- Mocha: 30 g Chocolate
-(6, 1, 1),  -- Iced Latte: 1 Espresso Shot
-(6, 2, 200),  -- Iced Latte: 200 ml Milk
-(7, 1, 1),  -- Americano: 1 Espresso Shot
-(8, 3, 100),  -- Carrot Cake: 100 g Flour
-(8, 4, 20),  -- Carrot Cake: 20 g Butter
-(9, 1, 1),  -- Vanilla Latte: 1 Espresso Shot
-(9, 2, 200),  -- Vanilla Latte: 200 ml Milk
-(10, 7, 50),  -- Chocolate Croissant: 50 g Chocolate
-(11, 8, 10),  -- Black Coffee: 10 g Coffee Beans
-(12, 3, 100),  -- Cheese Croissant: 100 g Flour
-(12, 4, 30),  -- Cheese Croissant: 30 g Butter
-(12, 11, 50),  -- Cheese Croissant: 50 g Cheese
-(13, 12, 1),  -- Bagel with Cream Cheese: 1 Bagel
-(13, 11, 40),  -- Bagel with Cream Cheese: 40 g Cheese
-(14, 12, 1),  -- Ham & Cheese Sandwich: 1 Bagel
-(14, 11, 50),  -- Ham & Cheese Sandwich: 50 g Cheese
-(14, 13, 50),  -- Ham & Cheese Sandwich: 50 g Ham
-(15, 14, 50),  -- Oatmeal Cookie: 50 g Oats
-(15, 3, 50),  -- Oatmeal Cookie: 50 g Flour
-(15, 5, 20),  -- Oatmeal Cookie: 20 g Sugar
-(15, 4, 15);  -- Oatmeal Cookie: 15 g Butter
-
-
+INSERT INTO menu_item_ingredients (MenuID, IngredientID, Quantity, Unit) VALUES
+(1, 1, 1, 'shots'),  -- Caffe Latte: 1 Espresso Shot
+(1, 2, 200, 'ml'),  -- Caffe Latte: 200 ml Milk
+(2, 3, 100, 'g'),  -- Blueberry Muffin: 100 g Flour
+(2, 4, 20, 'g'),  -- Blueberry Muffin: 20 g Butter
+(2, 5, 30, 'g'),  -- Blueberry Muffin: 30 g Sugar
+(3, 1, 1, 'shots'),  -- Espresso: 1 Espresso Shot
+(4, 1, 1, 'shots'),  -- Cappuccino: 1 Espresso Shot
+(4, 2, 200, 'ml'),  -- Cappuccino: 200 ml Milk
+(5, 1, 1, 'shots'),  -- Mocha: 1 Espresso Shot
+(5, 2, 200, 'ml'),  -- Mocha: 200 ml Milk
+(5, 6, 30, 'g'),  -- Mocha: 30 g Chocolate
+(6, 1, 1, 'shots'),  -- Iced Latte: 1 Espresso Shot
+(6, 2, 200, 'ml'),  -- Iced Latte: 200 ml Milk
+(7, 1, 1, 'shots'),  -- Americano: 1 Espresso Shot
+(8, 3, 100, 'g'),  -- Carrot Cake: 100 g Flour
+(8, 4, 20, 'g'),  -- Carrot Cake: 20 g Butter
+(9, 1, 1, 'shots'),  -- Vanilla Latte: 1 Espresso Shot
+(9, 2, 200, 'ml'),  -- Vanilla Latte: 200 ml Milk
+(10, 7, 50, 'g'),  -- Chocolate Croissant: 50 g Chocolate
+(11, 8, 10, 'g'),  -- Black Coffee: 10 g Coffee Beans
+(12, 3, 100, 'g'),  -- Cheese Croissant: 100 g Flour
+(12, 4, 30, 'g'),  -- Cheese Croissant: 30 g Butter
+(12, 11, 50, 'g'),  -- Cheese Croissant: 50 g Cheese
+(13, 12, 1, 'bagel'),  -- Bagel with Cream Cheese: 1 Bagel
+(13, 11, 40, 'g'),  -- Bagel with Cream Cheese: 40 g Cheese
+(14, 12, 1, 'bagel'),  -- Ham & Cheese Sandwich: 1 Bagel
+(14, 11, 50, 'g'),  -- Ham & Cheese Sandwich: 50 g Cheese
+(14, 13, 50, 'g'),  -- Ham & Cheese Sandwich: 50 g Ham
+(15, 14, 50, 'g'),  -- Oatmeal Cookie: 50 g Oats
+(15, 3, 50, 'g'),  -- Oatmeal Cookie: 50 g Flour
+(15, 5, 20, 'g'),  -- Oatmeal Cookie: 20 g Sugar
+(15, 4, 15, 'g');  -- Oatmeal Cookie: 15 g Butter
+
+-- The mock recipes are the first version of each recipe
+INSERT INTO recipe_versions (MenuID, Version, EffectiveFrom, Note)
+SELECT DISTINCT MenuID, 1, TIMESTAMP '2024-01-01 00:00:00', 'Initial recipe' FROM menu_item_ingredients;
+
+INSERT INTO recipe_version_ingredients (VersionID, IngredientID, Quantity, Unit)
+SELECT rv.ID, mii.IngredientID, mii.Quantity, mii.Unit
+FROM menu_item_ingredients mii
+JOIN recipe_versions rv ON rv.MenuID = mii.MenuID;
+
+UPDATE menu_items mi SET RecipeVersionID = rv.ID
+FROM recipe_versions rv WHERE rv.MenuID = mi.ID;
+
+-- Mock data for menu_item_channel_prices
+INSERT INTO menu_item_channel_prices (MenuID, Channel, Price) VALUES
+(1, 'delivery', 3.90),  -- Caffe Latte
+(6, 'delivery', 4.20),  -- Iced Latte
+(14, 'delivery', 4.90),  -- Ham & Cheese Sandwich
+(14, 'online', 4.70);  -- Ham & Cheese Sandwich
+
+-- Mock data for bundles: black coffee with any pastry
+INSERT INTO menu_items (Name, Description, Price, Image, CategoryID, Position) VALUES
+('Coffee & Pastry Deal', 'Black coffee with a pastry of your choice', 3.90, 'uploads/default.jpg', 6, 1);
+
+INSERT INTO menu_item_bundle_slots (BundleID, ProductID, CategoryID, Quantity) VALUES
+(16, 11, NULL, 1),  -- Black Coffee
+(16, NULL, 4, 1);  -- any Pastry
+
+
+
+-- Mock data for dining_tables
+INSERT INTO dining_tables (Name, Seats) VALUES
+('T1', 2),
+('T2', 2),
+('T3', 4),
+('T4', 4),
+('T5', 6),
+('Bar', 8);
 
 -- Mock data for orders 
 --2024
@@ -320,3 +818,37 @@ INSERT INTO order_items (OrderID, ProductID, Quantity) VALUES
 (19, 3, 1),  -- Steve: 1 Espresso
 (20, 9, 1);  -- Tina: 1 Vanilla Latte
 
+-- Mock order lines were made with the recipes of the time
+UPDATE order_items oi SET RecipeVersionID = mi.RecipeVersionID
+FROM menu_items mi WHERE mi.ID = oi.ProductID;
+
+-- Items of closed orders have already been served
+UPDATE order_items SET Status = 'served'
+WHERE OrderID IN (SELECT ID FROM orders WHERE Status = 'closed');
+
+
+-- How often two menu items were bought in the same order, for "frequently bought together"
+-- recommendations. Confidence is the share of orders with ProductID that also had PairedID;
+-- lift compares that to how often PairedID is bought at all. Refreshed by the server in the background.
+CREATE MATERIALIZED VIEW menu_item_pairs AS
+WITH baskets AS (
+    SELECT DISTINCT OrderID, ProductID FROM order_items
+),
+product_orders AS (
+    SELECT ProductID, COUNT(*) AS Orders FROM baskets GROUP BY ProductID
+),
+total_orders AS (
+    SELECT COUNT(DISTINCT OrderID) AS Orders FROM baskets
+)
+SELECT a.ProductID, b.ProductID AS PairedID, COUNT(*) AS PairOrders,
+    COUNT(*)::NUMERIC / pa.Orders AS Confidence,
+    COUNT(*)::NUMERIC * t.Orders / (pa.Orders * pb.Orders) AS Lift
+FROM baskets a
+JOIN baskets b ON b.OrderID = a.OrderID AND b.ProductID <> a.ProductID
+JOIN product_orders pa ON pa.ProductID = a.ProductID
+JOIN product_orders pb ON pb.ProductID = b.ProductID
+CROSS JOIN total_orders t
+GROUP BY a.ProductID, b.ProductID, pa.Orders, pb.Orders, t.Orders;
+
+-- The unique index lets the view be refreshed concurrently, without blocking readers
+CREATE UNIQUE INDEX idx_menu_item_pairs ON menu_item_pairs (ProductID, PairedID);
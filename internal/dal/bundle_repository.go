@@ -0,0 +1,234 @@
+package dal
+
+import (
+	"fmt"
+	"math"
+
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+// bundleComponent is one product a bundle is made of.
+type bundleComponent struct {
+	ProductID int
+	Quantity  int     // per bundle
+	Price     float64 // standalone menu price, used to split the bundle's revenue
+}
+
+// getBundleSlots returns the slots of a bundle, or nil for regular menu items.
+func getBundleSlots(q queryer, bundleID int) ([]models.BundleSlot, error) {
+	query := `
+		SELECT ID, COALESCE(ProductID, 0), COALESCE(CategoryID, 0), Quantity
+		FROM menu_item_bundle_slots WHERE BundleID = $1 ORDER BY ID
+	`
+	rows, err := q.Query(query, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []models.BundleSlot
+	for rows.Next() {
+		var slot models.BundleSlot
+		if err := rows.Scan(&slot.ID, &slot.ProductID, &slot.CategoryID, &slot.Quantity); err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	return slots, rows.Err()
+}
+
+// saveBundleSlots writes the slots of a bundle. When replace is true the existing slots are removed first.
+func saveBundleSlots(q queryer, menuItem models.MenuItem, replace bool) error {
+	if replace {
+		if _, err := q.Exec(`DELETE FROM menu_item_bundle_slots WHERE BundleID = $1`, menuItem.ID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO menu_item_bundle_slots (BundleID, ProductID, CategoryID, Quantity)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4)
+	`
+	for _, slot := range menuItem.Bundle {
+		if _, err := q.Exec(query, menuItem.ID, slot.ProductID, slot.CategoryID, slot.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// categoryProducts returns the regular (non-bundle) menu items of a category and of its subcategories.
+func categoryProducts(q queryer, categoryID int) ([]int, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT ID FROM menu_categories WHERE ID = $1
+			UNION ALL
+			SELECT c.ID FROM menu_categories c JOIN tree t ON c.ParentID = t.ID
+		)
+		SELECT ARRAY(
+			SELECT mi.ID FROM menu_items mi
+			WHERE mi.CategoryID IN (SELECT ID FROM tree)
+			AND NOT EXISTS (SELECT 1 FROM menu_item_bundle_slots s WHERE s.BundleID = mi.ID)
+			ORDER BY mi.ID
+		)
+	`
+	var ids pq.Int64Array
+	if err := q.QueryRow(query, categoryID).Scan(&ids); err != nil {
+		return nil, err
+	}
+	products := make([]int, 0, len(ids))
+	for _, id := range ids {
+		products = append(products, int(id))
+	}
+	return products, nil
+}
+
+// productsByCategory returns the regular (non-bundle) menu items of each of the categories and
+// of their subcategories, in one query.
+func productsByCategory(q queryer, categoryIDs []int) (map[int][]int, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT ID AS RootID, ID FROM menu_categories WHERE ID = ANY($1)
+			UNION ALL
+			SELECT t.RootID, c.ID FROM menu_categories c JOIN tree t ON c.ParentID = t.ID
+		)
+		SELECT t.RootID, mi.ID FROM tree t
+		JOIN menu_items mi ON mi.CategoryID = t.ID
+		WHERE NOT EXISTS (SELECT 1 FROM menu_item_bundle_slots s WHERE s.BundleID = mi.ID)
+		ORDER BY t.RootID, mi.ID
+	`
+	rows, err := q.Query(query, pq.Array(categoryIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make(map[int][]int, len(categoryIDs))
+	for rows.Next() {
+		var categoryID, productID int
+		if err := rows.Scan(&categoryID, &productID); err != nil {
+			return nil, err
+		}
+		products[categoryID] = append(products[categoryID], productID)
+	}
+	return products, rows.Err()
+}
+
+// resolveBundle turns the slots of a bundle and the customer's choices into the products it is
+// made of. It returns nil for regular menu items.
+func resolveBundle(q queryer, bundleID int, choices []models.BundleChoice) ([]bundleComponent, error) {
+	slots, err := getBundleSlots(q, bundleID)
+	if err != nil || len(slots) == 0 {
+		return nil, err
+	}
+
+	chosen := make(map[int]int)
+	for _, choice := range choices {
+		chosen[choice.SlotID] = choice.ProductID
+	}
+
+	var components []bundleComponent
+	for _, slot := range slots {
+		productID := slot.ProductID
+		if slot.CategoryID != 0 {
+			productID = chosen[slot.ID]
+			if productID == 0 {
+				return nil, fmt.Errorf("%w: slot %d of bundle %d needs a choice", models.ErrBundleChoice, slot.ID, bundleID)
+			}
+			allowed, err := categoryProducts(q, slot.CategoryID)
+			if err != nil {
+				return nil, err
+			}
+			if !containsInt(allowed, productID) {
+				return nil, fmt.Errorf("%w: product %d cannot be chosen for slot %d of bundle %d", models.ErrBundleChoice, productID, slot.ID, bundleID)
+			}
+		}
+
+		var price float64
+		if err := q.QueryRow(`SELECT Price FROM menu_items WHERE ID = $1`, productID).Scan(&price); err != nil {
+			return nil, err
+		}
+
+		merged := false
+		for i := range components {
+			if components[i].ProductID == productID {
+				components[i].Quantity += slot.Quantity
+				merged = true
+			}
+		}
+		if !merged {
+			components = append(components, bundleComponent{ProductID: productID, Quantity: slot.Quantity, Price: price})
+		}
+	}
+	return components, nil
+}
+
+// addBundleComponents records the components of bundles sold on an order line and splits the
+// revenue of those bundles between them, in proportion to the standalone price of each component.
+// Rounding leftovers go to the last component so the shares always add up to the revenue.
+func addBundleComponents(q queryer, lineID int, components []bundleComponent, bundles int, revenue float64) error {
+	var totalWeight float64
+	for _, c := range components {
+		totalWeight += c.Price * float64(c.Quantity)
+	}
+
+	revenueCents := int64(math.Round(revenue * 100))
+	remaining := revenueCents
+	query := `
+		INSERT INTO order_item_components (OrderItemID, ProductID, Quantity, Revenue, RecipeVersionID)
+		SELECT $1, $2, $3, $4, RecipeVersionID FROM menu_items WHERE ID = $2
+		ON CONFLICT (OrderItemID, ProductID)
+		DO UPDATE SET Quantity = order_item_components.Quantity + EXCLUDED.Quantity,
+			Revenue = order_item_components.Revenue + EXCLUDED.Revenue
+	`
+	for i, c := range components {
+		share := remaining
+		if i < len(components)-1 {
+			weight := 1 / float64(len(components))
+			if totalWeight > 0 {
+				weight = c.Price * float64(c.Quantity) / totalWeight
+			}
+			share = int64(math.Round(float64(revenueCents) * weight))
+		}
+		remaining -= share
+
+		_, err := q.Exec(query, lineID, c.ProductID, c.Quantity*bundles, float64(share)/100)
+		if err != nil {
+			return fmt.Errorf("failed to record bundle component: %w", err)
+		}
+	}
+	return nil
+}
+
+// getOrderItemComponents returns the components recorded for a bundle line.
+func getOrderItemComponents(q queryer, lineID int) ([]models.OrderItemComponent, error) {
+	rows, err := q.Query(`
+		SELECT ProductID, Quantity, Revenue, COALESCE(RecipeVersionID, 0) FROM order_item_components
+		WHERE OrderItemID = $1 ORDER BY ProductID
+	`, lineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var components []models.OrderItemComponent
+	for rows.Next() {
+		var component models.OrderItemComponent
+		if err := rows.Scan(&component.ProductID, &component.Quantity, &component.Revenue, &component.RecipeVersionID); err != nil {
+			return nil, err
+		}
+		components = append(components, component)
+	}
+	return components, rows.Err()
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package dal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProductsByCategory(t *testing.T) {
+	db := openTestDB(t)
+
+	var parent, child, empty int
+	for _, c := range []struct {
+		name string
+		id   *int
+	}{{"Test parent", &parent}, {"Test child", &child}, {"Test empty", &empty}} {
+		if err := db.QueryRow(`INSERT INTO menu_categories (Name) VALUES ($1) RETURNING ID`, c.name).Scan(c.id); err != nil {
+			t.Fatal(err)
+		}
+		id := *c.id
+		t.Cleanup(func() { db.Exec(`DELETE FROM menu_categories WHERE ID = $1`, id) })
+	}
+
+	inParent := addTestMenuItem(t, db, 3)
+	inChild := addTestMenuItem(t, db, 4)
+	bundle := addTestMenuItem(t, db, 6)
+	setup := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE menu_categories SET ParentID = $1 WHERE ID = $2`, []interface{}{parent, child}},
+		{`UPDATE menu_items SET CategoryID = $1 WHERE ID = $2`, []interface{}{parent, inParent}},
+		{`UPDATE menu_items SET CategoryID = $1 WHERE ID IN ($2, $3)`, []interface{}{child, inChild, bundle}},
+		{`INSERT INTO menu_item_bundle_slots (BundleID, ProductID, Quantity) VALUES ($1, $2, 1)`, []interface{}{bundle, inParent}},
+	}
+	for _, step := range setup {
+		if _, err := db.Exec(step.query, step.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := productsByCategory(db, []int{parent, child, empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int][]int{parent: {inParent, inChild}, child: {inChild}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
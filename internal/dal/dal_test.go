@@ -0,0 +1,78 @@
+package dal
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to the database in TEST_DATABASE_URL, which must have init.sql loaded.
+// Tests that need it are skipped when it is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// addTestMenuItem adds a menu item with the given price and removes it when the test ends.
+func addTestMenuItem(t *testing.T, db *sql.DB, price float64) int {
+	t.Helper()
+	var id int
+	err := db.QueryRow(`INSERT INTO menu_items (Name, Description, Price) VALUES ('Test item', 'Test', $1) RETURNING ID`, price).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM menu_items WHERE ID = $1`, id) })
+	return id
+}
+
+// addTestOrder adds an open dine-in order, seated at tableID unless it is 0, and removes it when the test ends.
+func addTestOrder(t *testing.T, db *sql.DB, tableID int) int {
+	t.Helper()
+	var id int
+	err := db.QueryRow(`INSERT INTO orders (CustomerName, TableID) VALUES ('Test customer', NULLIF($1, 0)) RETURNING ID`, tableID).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM orders WHERE ID = $1`, id) })
+	return id
+}
+
+// addTestOrderItem adds a line to an order with the given quantity and status and returns its ID.
+func addTestOrderItem(t *testing.T, db *sql.DB, orderID, productID, quantity int, status string) int {
+	t.Helper()
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO order_items (OrderID, ProductID, Quantity, Price, Status)
+		VALUES ($1, $2, $3, 5, $4) RETURNING ID
+	`, orderID, productID, quantity, status).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// lineStatus returns the quantity and status of an order line.
+func lineStatus(t *testing.T, db *sql.DB, orderID, productID int) (int, string) {
+	t.Helper()
+	var quantity int
+	var status string
+	err := db.QueryRow(`SELECT Quantity, Status FROM order_items WHERE OrderID = $1 AND ProductID = $2`, orderID, productID).Scan(&quantity, &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return quantity, status
+}
@@ -7,6 +7,8 @@ import (
 	"strconv"
 
 	"hot-coffee/models"
+
+	"github.com/lib/pq"
 )
 
 // InventoryRepository is responsible for interacting with the inventory data in the database.
@@ -23,7 +25,7 @@ func NewInventoryRepository(db *sql.DB) *InventoryRepository {
 func (repo *InventoryRepository) GetAll() ([]models.InventoryItem, error) {
 	// SQL query to get all inventory items
 	queryGetIngridients := `
-	select IngredientID, Name, Quantity, Unit from inventory
+	select IngredientID, Name, Quantity, Unit, COALESCE(Density, 0), UnitCost, PurchaseCost, Version from inventory
 	`
 	rows, err := repo.db.Query(queryGetIngridients)
 	if err != nil {
@@ -34,7 +36,7 @@ func (repo *InventoryRepository) GetAll() ([]models.InventoryItem, error) {
 	// Iterate through all rows returned by the query
 	for rows.Next() {
 		var InventoryItem models.InventoryItem
-		err = rows.Scan(&InventoryItem.IngredientID, &InventoryItem.Name, &InventoryItem.Quantity, &InventoryItem.Unit)
+		err = rows.Scan(&InventoryItem.IngredientID, &InventoryItem.Name, &InventoryItem.Quantity, &InventoryItem.Unit, &InventoryItem.Density, &InventoryItem.UnitCost, &InventoryItem.PurchaseCost, &InventoryItem.Version)
 		if err != nil {
 			return []models.InventoryItem{}, nil // Return nil if scanning fails
 		}
@@ -77,46 +79,137 @@ func (repo *InventoryRepository) SubtractIngredients(ingredients map[int]float64
 
 // AddInventoryItemRepo adds a new inventory item to the database.
 func (repo *InventoryRepository) AddInventoryItemRepo(item models.InventoryItem) error {
-	// SQL query to insert a new inventory item into the database
+	// SQL query to insert a new inventory item into the database. The initial stock was bought
+	// at the unit cost.
 	queryToAddInventory := `
-	insert into inventory (Name, Quantity, Unit) values
-	($1, $2, $3)
+	insert into inventory (Name, Quantity, Unit, Density, UnitCost, PurchaseCost) values
+	($1, $2, $3, NULLIF($4, 0), $5, $5)
 	`
-	_, err := repo.db.Exec(queryToAddInventory, item.Name, item.Quantity, item.Unit)
+	_, err := repo.db.Exec(queryToAddInventory, item.Name, item.Quantity, item.Unit, item.Density, item.UnitCost)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return models.ErrUnknownUnit
+	}
 	if err != nil {
 		return err // Return error if insertion fails
 	}
 	return nil // Return nil if item is successfully added
 }
 
-// UpdateItemRepo updates an existing inventory item's details in the database.
+// UpdateItemRepo updates an existing inventory item's details in the database. Quantity and unit
+// cost are stored as given, for stock counts and corrections; deliveries go through
+// RestockItemRepo so the unit cost stays a weighted average.
+// Changes to the unit or density that leave a recipe unable to convert its quantity into the
+// stock unit are rejected with models.ErrIncompatibleUnit.
 func (repo *InventoryRepository) UpdateItemRepo(id int, newItem models.InventoryItem) error {
-	// SQL query to update an inventory item based on the provided ID
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// SQL query to update an inventory item based on the provided ID. A non-zero version
+	// must match the stored one so an outdated copy cannot overwrite newer changes.
 	queryToUpdate := `
 	update inventory
-	set Quantity = $1, Name = $2, Unit = $3
-	where IngredientID = $4
+	set Quantity = $1, Name = $2, Unit = $3, Density = NULLIF($4, 0), UnitCost = $5
+	where IngredientID = $6 and ($7 = 0 or Version = $7)
 	`
-	_, err := repo.db.Exec(queryToUpdate, newItem.Quantity, newItem.Name, newItem.Unit, id)
+	result, err := tx.Exec(queryToUpdate, newItem.Quantity, newItem.Name, newItem.Unit, newItem.Density, newItem.UnitCost, id, newItem.Version)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return models.ErrUnknownUnit
+	}
 	if err != nil {
 		return err // Return error if update fails
 	}
-	return nil // Return nil if update is successful
+	if err = repo.checkVersionedWrite(result, id); err != nil {
+		return err
+	}
+	if err = checkIngredientRecipes(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// DeleteItemRepo deletes an inventory item based on its ID.
-func (repo *InventoryRepository) DeleteItemRepo(id int) error {
+// RestockItemRepo adds a delivery to the stock of an inventory item. The unit cost becomes the
+// weighted average of the stock held and the delivery, and the purchase cost what one unit of
+// the delivery cost. A non-zero version must match the stored one.
+func (repo *InventoryRepository) RestockItemRepo(id int, restock models.Restock, version int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var held, unitCost float64
+	var current int
+	err = tx.QueryRow(`SELECT Quantity, UnitCost, Version FROM inventory WHERE IngredientID = $1 FOR UPDATE`, id).Scan(&held, &unitCost, &current)
+	if err == sql.ErrNoRows {
+		return errors.New("inventory item does not exist")
+	}
+	if err != nil {
+		return err
+	}
+	if version != 0 && version != current {
+		return models.ErrVersionConflict
+	}
+
+	quantity, err := convertToStockUnit(tx, id, restock.Quantity, restock.Unit)
+	if err != nil {
+		return err
+	}
+	// The inventory trigger logs the change under this reason
+	if _, err = tx.Exec(`SELECT set_config('inventory.reason', 'Restock', true)`); err != nil {
+		return err
+	}
+	queryRestock := `
+	update inventory
+	set Quantity = Quantity + $1::numeric, UnitCost = $2::numeric(14, 8), PurchaseCost = $3::numeric(14, 8)
+	where IngredientID = $4
+	`
+	_, err = tx.Exec(queryRestock, quantity, weightedAverageCost(held, unitCost, quantity, restock.Cost), restock.Cost/quantity, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// weightedAverageCost returns the unit cost of the stock after a delivery of quantity units
+// that cost paid in total is added to held units at unitCost each. Stock that ran out, or
+// below zero after a correction, does not count towards the average.
+func weightedAverageCost(held, unitCost, quantity, paid float64) float64 {
+	if held <= 0 {
+		return paid / quantity
+	}
+	return (held*unitCost + paid) / (held + quantity)
+}
+
+// DeleteItemRepo deletes an inventory item based on its ID. A non-zero version must match the stored one.
+// Items that any recipe version uses are rejected with models.ErrIngredientInUse.
+func (repo *InventoryRepository) DeleteItemRepo(id, version int) error {
 	// SQL query to delete an inventory item using the given ID
 	queryToDelete := `
 	delete from inventory
-	where IngredientID = $1
+	where IngredientID = $1 and ($2 = 0 or Version = $2)
 	`
-	_, err := repo.db.Exec(queryToDelete, id)
+	result, err := repo.db.Exec(queryToDelete, id, version)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return models.ErrIngredientInUse
+	}
 	if err != nil {
-		fmt.Println("Delete error:", err)
 		return err // Return error if deletion fails
 	}
-	return nil // Return nil if item is successfully deleted
+	return repo.checkVersionedWrite(result, id)
+}
+
+// checkVersionedWrite tells a write that lost to a newer version apart from one on a missing item.
+func (repo *InventoryRepository) checkVersionedWrite(result sql.Result, id int) error {
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	if !repo.Exists(id) {
+		return errors.New("inventory item does not exist")
+	}
+	return models.ErrVersionConflict
 }
 
 // GetLeftOvers retrieves a paginated list of inventory items, sorted by a specified field (either 'price' or 'quantity').
@@ -135,16 +228,16 @@ func (repo *InventoryRepository) GetLeftOvers(sortBy, page, pageSize string) (ma
 
 	// Base query to retrieve inventory items
 	query := `
-        SELECT i.IngredientID, i.Name, i.Quantity, i.Unit
+        SELECT i.IngredientID, i.Name, i.Quantity, i.Unit, i.UnitCost
         FROM inventory i
     `
 
-	// Sort the query based on the sortBy parameter
+	// Sort the query based on the sortBy parameter. The price of an ingredient is its unit cost.
 	switch sortBy {
 	case "price":
-		query += " ORDER BY i.Price"
+		query += " ORDER BY i.UnitCost, i.IngredientID"
 	case "quantity":
-		query += " ORDER BY i.Quantity"
+		query += " ORDER BY i.Quantity, i.IngredientID"
 	default:
 		return nil, errors.New("invalid sortBy value, must be 'price' or 'quantity'") // Return error if invalid sortBy value
 	}
@@ -163,9 +256,10 @@ func (repo *InventoryRepository) GetLeftOvers(sortBy, page, pageSize string) (ma
 	for rows.Next() {
 		var ingredientID int
 		var name string
-		var quantity int
+		var quantity float64
 		var unit string
-		if err := rows.Scan(&ingredientID, &name, &quantity, &unit); err != nil {
+		var price float64
+		if err := rows.Scan(&ingredientID, &name, &quantity, &unit, &price); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %v", err) // Return error if row scan fails
 		}
 
@@ -175,6 +269,7 @@ func (repo *InventoryRepository) GetLeftOvers(sortBy, page, pageSize string) (ma
 			"name":         name,
 			"quantity":     quantity,
 			"unit":         unit,
+			"price":        price,
 		})
 	}
 
@@ -0,0 +1,29 @@
+package dal
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightedAverageCost(t *testing.T) {
+	tests := []struct {
+		name                           string
+		held, unitCost, quantity, paid float64
+		want                           float64
+	}{
+		{"bags of coffee beans", 2000, 0.02, 2000, 36, 0.019},
+		{"same price", 500, 0.25, 100, 25, 0.25},
+		{"free delivery", 100, 0.5, 100, 0, 0.25},
+		{"empty stock", 0, 0.5, 1000, 3, 0.003},
+		{"negative stock", -20, 0.5, 1000, 3, 0.003},
+		{"small unit cost", 5000, 0.0012, 1000, 1.3, 0.00121667},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := weightedAverageCost(tt.held, tt.unitCost, tt.quantity, tt.paid)
+			if math.Abs(got-tt.want) > 5e-9 {
+				t.Errorf("got %.8f, want %.8f", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package dal
+
+import (
+	"fmt"
+
+	"hot-coffee/models"
+)
+
+// GetCategories retrieves all menu categories in display order.
+func (repo *MenuRepository) GetCategories() ([]models.MenuCategory, error) {
+	query := `
+		SELECT ID, Name, COALESCE(ParentID, 0), DisplayOrder
+		FROM menu_categories
+		ORDER BY DisplayOrder, ID
+	`
+	rows, err := repo.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for menu_categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []models.MenuCategory{}
+	for rows.Next() {
+		var category models.MenuCategory
+		if err := rows.Scan(&category.ID, &category.Name, &category.ParentID, &category.DisplayOrder); err != nil {
+			return nil, fmt.Errorf("error scanning row in menu_categories: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// CategoryExists checks whether a menu category with the given ID exists.
+func (repo *MenuRepository) CategoryExists(id int) bool {
+	var exists bool
+	err := repo.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM menu_categories WHERE ID = $1)`, id).Scan(&exists)
+	return err == nil && exists
+}
+
+// AddCategory inserts a new menu category and returns its ID.
+func (repo *MenuRepository) AddCategory(category models.MenuCategory) (int, error) {
+	query := `
+		INSERT INTO menu_categories (Name, ParentID, DisplayOrder)
+		VALUES ($1, NULLIF($2, 0), $3) RETURNING ID
+	`
+	var id int
+	err := repo.db.QueryRow(query, category.Name, category.ParentID, category.DisplayOrder).Scan(&id)
+	return id, err
+}
+
+// UpdateCategory updates the name, parent and display order of a menu category.
+func (repo *MenuRepository) UpdateCategory(category models.MenuCategory) error {
+	query := `
+		UPDATE menu_categories
+		SET Name = $1, ParentID = NULLIF($2, 0), DisplayOrder = $3
+		WHERE ID = $4
+	`
+	result, err := repo.db.Exec(query, category.Name, category.ParentID, category.DisplayOrder, category.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.ErrCategoryNotFound
+	}
+	return nil
+}
+
+// DeleteCategory removes a menu category. Its items and subcategories are kept without a category.
+// Pending price changes that target the category are cancelled, so they cannot fall back to the
+// whole menu once the category is gone.
+func (repo *MenuRepository) DeleteCategory(id int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE scheduled_price_changes SET Status = 'cancelled', Error = 'category deleted'
+		WHERE CategoryID = $1 AND Status = 'pending'
+	`, id)
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM menu_categories WHERE ID = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.ErrCategoryNotFound
+	}
+	return tx.Commit()
+}
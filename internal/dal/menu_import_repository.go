@@ -0,0 +1,59 @@
+package dal
+
+import (
+	"fmt"
+	"time"
+
+	"hot-coffee/models"
+)
+
+// ImportMenuItems creates the menu items without an ID and updates the others in a single
+// transaction, so a failing row leaves the menu untouched. Updates change the name,
+// description, price, category, position and recipe; images, channel prices, packaging,
+// schedules and bundle slots are left as they are. A changed recipe becomes a new version.
+func (repo *MenuRepository) ImportMenuItems(menuItems []models.MenuItem) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queryAddItem := `
+		INSERT INTO menu_items (Name, Description, Price, Image, CategoryID, Position)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6) RETURNING ID
+	`
+	queryUpdateItem := `
+		UPDATE menu_items
+		SET Name = $1, Description = $2, Price = $3, CategoryID = NULLIF($4, 0), Position = $5
+		WHERE ID = $6
+	`
+	for _, menuItem := range menuItems {
+		if menuItem.ID == 0 {
+			err = tx.QueryRow(queryAddItem, menuItem.Name, menuItem.Description, menuItem.Price, menuItem.Image,
+				menuItem.CategoryID, menuItem.Position).Scan(&menuItem.ID)
+			if err != nil {
+				return fmt.Errorf("failed to add menu item %q: %w", menuItem.Name, err)
+			}
+			if len(menuItem.Ingredients) == 0 {
+				continue
+			}
+		} else {
+			_, err = tx.Exec(queryUpdateItem, menuItem.Name, menuItem.Description, menuItem.Price,
+				menuItem.CategoryID, menuItem.Position, menuItem.ID)
+			if err != nil {
+				return fmt.Errorf("failed to update menu item %q: %w", menuItem.Name, err)
+			}
+			changed, err := recipeChanged(tx, menuItem.ID, menuItem.Ingredients)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				continue
+			}
+		}
+		if _, err = addRecipeVersion(tx, menuItem.ID, menuItem.Ingredients, time.Time{}, "menu import"); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -3,8 +3,11 @@ package dal
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"hot-coffee/models"
+
+	"github.com/lib/pq"
 )
 
 // MenuRepository defines methods for interacting with the menu in the database.
@@ -20,8 +23,40 @@ func NewMenuRepository(db *sql.DB) *MenuRepository {
 // GetAll retrieves all menu items from the database, along with their ingredients.
 func (repo *MenuRepository) GetAll() ([]models.MenuItem, error) {
 	// Query to get all menu items
+	// Items are listed in menu order: by category, then by position inside the category.
+	// Servings are limited by the scarcest ingredient of the recipe. An ingredient that runs out
+	// can be made up for by its best stocked substitute unless the item opts out. Recipe
+	// quantities are converted into the stock unit of each ingredient. An order line rounds what
+	// all of its servings use up to the decimals kept for the unit, so n servings fit as long as
+	// n times the recipe quantity fits into the stock rounded down to those decimals; for a
+	// substitute that is its stock divided by the ratio.
 	queryMenuItems := `
-	select ID, Name, Description, Price, Image from menu_items
+	select mi.ID, mi.Name, mi.Description, mi.Price, mi.Image, COALESCE(mi.CategoryID, 0), mi.Position,
+		servings.count, mi.EightySixReason, mi.EightySixedAt, mi.ArchivedAt, COALESCE(rv.Version, 0), mi.Version,
+		mi.NoSubstitutes
+	from menu_items mi
+	left join menu_categories c on c.ID = mi.CategoryID
+	left join recipe_versions rv on rv.ID = mi.RecipeVersionID
+	left join (
+		select mii.MenuID, MIN(GREATEST(FLOOR(FLOOR(inv.Quantity * need.scale) / need.scale / need.quantity), COALESCE(sub.count, 0)))::int as count
+		from menu_item_ingredients mii
+		join menu_items m on m.ID = mii.MenuID
+		join inventory inv on inv.IngredientID = mii.IngredientID
+		cross join lateral (
+			select convert_to_stock_unit(mii.Quantity, mii.Unit, mii.IngredientID) as quantity,
+				POWER(10::numeric, u.Decimals) as scale
+			from units u where u.Code = inv.Unit
+		) need
+		left join lateral (
+			select MAX(FLOOR(FLOOR(si.Quantity / s.Ratio * need.scale) / need.scale / need.quantity)) as count
+			from ingredient_substitutions s
+			join inventory si on si.IngredientID = s.SubstituteID
+			where s.IngredientID = mii.IngredientID and not m.NoSubstitutes
+		) sub on true
+		where need.quantity > 0
+		group by mii.MenuID
+	) servings on servings.MenuID = mi.ID
+	order by c.DisplayOrder nulls last, c.ID, mi.Position, mi.ID
 	`
 	rows, err := repo.db.Query(queryMenuItems)
 	if err != nil {
@@ -32,15 +67,33 @@ func (repo *MenuRepository) GetAll() ([]models.MenuItem, error) {
 	// Iterate through each menu item in the result set
 	for rows.Next() {
 		var MenuItem models.MenuItem
-		err := rows.Scan(&MenuItem.ID, &MenuItem.Name, &MenuItem.Description, &MenuItem.Price, &MenuItem.Image)
+		var servings sql.NullInt64
+		var reason sql.NullString
+		var since sql.NullTime
+		var archivedAt sql.NullTime
+		err := rows.Scan(&MenuItem.ID, &MenuItem.Name, &MenuItem.Description, &MenuItem.Price, &MenuItem.Image, &MenuItem.CategoryID, &MenuItem.Position, &servings, &reason, &since, &archivedAt, &MenuItem.RecipeVersion, &MenuItem.Version, &MenuItem.NoSubstitutes)
 		if err != nil {
 			return []models.MenuItem{}, err
 		}
+		if servings.Valid {
+			count := int(servings.Int64)
+			MenuItem.AvailableServings = &count
+			MenuItem.SoldOut = count == 0
+		}
+		if reason.Valid {
+			MenuItem.EightySix = &models.EightySix{Reason: reason.String, Since: since.Time}
+			MenuItem.SoldOut = true
+		}
+		if archivedAt.Valid {
+			MenuItem.ArchivedAt = &archivedAt.Time
+		}
 
 		// Get ingredients for each menu item
 		var MenuItemIngredients []models.MenuItemIngredient
 		queryMenuItemIngredients := `
-			select IngredientID, Quantity from menu_item_ingredients where MenuID = $1
+			select IngredientID, Quantity, COALESCE(Unit, ''),
+				COALESCE(convert_to_stock_unit(Quantity, Unit, IngredientID), 0)
+			from menu_item_ingredients where MenuID = $1
 		`
 		rows1, err := repo.db.Query(queryMenuItemIngredients, MenuItem.ID)
 		if err != nil {
@@ -49,13 +102,36 @@ func (repo *MenuRepository) GetAll() ([]models.MenuItem, error) {
 		// Iterate through ingredients for each menu item
 		for rows1.Next() {
 			var MenuItemIngredient models.MenuItemIngredient
-			rows1.Scan(&MenuItemIngredient.IngredientID, &MenuItemIngredient.Quantity)
+			rows1.Scan(&MenuItemIngredient.IngredientID, &MenuItemIngredient.Quantity, &MenuItemIngredient.Unit, &MenuItemIngredient.StockQuantity)
 			MenuItemIngredients = append(MenuItemIngredients, MenuItemIngredient)
 		}
 		// Assign ingredients to the MenuItem
 		MenuItem.Ingredients = MenuItemIngredients
+
+		// Get channel prices and takeaway packaging for each menu item
+		MenuItem.ChannelPrices, err = repo.getChannelPrices(MenuItem.ID)
+		if err != nil {
+			return []models.MenuItem{}, err
+		}
+		MenuItem.Packaging, err = repo.getPackaging(MenuItem.ID)
+		if err != nil {
+			return []models.MenuItem{}, err
+		}
+		MenuItem.Schedules, err = repo.getSchedules(MenuItem.ID)
+		if err != nil {
+			return []models.MenuItem{}, err
+		}
+		MenuItem.Bundle, err = getBundleSlots(repo.db, MenuItem.ID)
+		if err != nil {
+			return []models.MenuItem{}, err
+		}
 		MenuItems = append(MenuItems, MenuItem)
 	}
+
+	// Bundles have no recipe of their own, their servings depend on their components
+	if err := repo.setBundleServings(MenuItems); err != nil {
+		return []models.MenuItem{}, err
+	}
 	return MenuItems, nil // Return all menu items
 }
 
@@ -70,56 +146,118 @@ func (repo *MenuRepository) Exists(itemID int) bool {
 	return false // Return false if not found
 }
 
-// DeleteMenuItemRepo deletes a menu item from the database using the given ID.
-func (repo *MenuRepository) DeleteMenuItemRepo(MenuItemID int) error {
+// ArchiveMenuItemRepo takes a menu item off the menu for good while keeping its row for past orders.
+// Archiving an already archived item keeps the original archive time. A non-zero version must
+// match the item's current version, otherwise models.ErrVersionConflict is returned.
+func (repo *MenuRepository) ArchiveMenuItemRepo(MenuItemID, version int) error {
+	queryArchiveMenuItem := `
+	update menu_items set ArchivedAt = COALESCE(ArchivedAt, CURRENT_TIMESTAMP)
+	where ID = $1 and ($2 = 0 or Version = $2)
+	`
+	result, err := repo.db.Exec(queryArchiveMenuItem, MenuItemID, version)
+	if err != nil {
+		return err
+	}
+	return repo.checkVersionedWrite(result, MenuItemID)
+}
+
+// checkVersionedWrite tells a write that lost to a newer version apart from one on a missing menu item.
+func (repo *MenuRepository) checkVersionedWrite(result sql.Result, MenuItemID int) error {
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	if !repo.MenuCheckByIDRepo(MenuItemID) {
+		return models.ErrMenuItemNotFound
+	}
+	return models.ErrVersionConflict
+}
+
+// RestoreMenuItemRepo puts an archived menu item back on the menu.
+func (repo *MenuRepository) RestoreMenuItemRepo(MenuItemID int) error {
+	_, err := repo.db.Exec(`update menu_items set ArchivedAt = NULL where ID = $1`, MenuItemID)
+	return err
+}
+
+// IsReferencedByOrders reports whether any order line, bundle component or check refers to the menu item.
+func (repo *MenuRepository) IsReferencedByOrders(MenuItemID int) (bool, error) {
+	queryReferenced := `
+	select exists (select 1 from order_items where ProductID = $1)
+		or exists (select 1 from order_item_components where ProductID = $1)
+		or exists (select 1 from order_check_items where ProductID = $1)
+	`
+	var referenced bool
+	err := repo.db.QueryRow(queryReferenced, MenuItemID).Scan(&referenced)
+	return referenced, err
+}
+
+// PurgeMenuItemRepo deletes a menu item from the database using the given ID.
+// Items still referenced by orders are rejected with models.ErrMenuItemInUse. A non-zero
+// version must match the item's current version.
+func (repo *MenuRepository) PurgeMenuItemRepo(MenuItemID, version int) error {
 	queryDeleteMenuItem := `
 	delete from menu_items
-	where ID = $1
+	where ID = $1 and ($2 = 0 or Version = $2)
 	`
 	// Execute delete query
-	_, err := repo.db.Exec(queryDeleteMenuItem, MenuItemID)
+	result, err := repo.db.Exec(queryDeleteMenuItem, MenuItemID, version)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		// An order was placed between the reference check and the delete
+		return models.ErrMenuItemInUse
+	}
 	if err != nil {
-		return err // Return error if deletion fails
+		return err
 	}
-	return nil // Return nil if deletion is successful
+	return repo.checkVersionedWrite(result, MenuItemID)
 }
 
-// UpdateMenuItemRepo updates the details of an existing menu item in the database.
+// UpdateMenuItemRepo updates the details of an existing menu item in the database. A non-zero
+// menuItem.Version must match the item's current version, so an edit based on an outdated
+// copy is rejected with models.ErrVersionConflict instead of overwriting newer changes.
+// The item, its recipe, channel options, schedules and bundle slots change in one transaction.
 func (repo *MenuRepository) UpdateMenuItemRepo(menuItem models.MenuItem) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// Query to update menu item
 	queryUpdateMenu := `
 	update menu_items
-	set Name = $1, Description = $2, Price = $3, Image=$4
-	where ID = $5
+	set Name = $1, Description = $2, Price = $3, Image=$4, CategoryID = NULLIF($5, 0), Position = $6, NoSubstitutes = $9
+	where ID = $7 and ($8 = 0 or Version = $8)
 	`
 	// Execute the update query
-	_, err := repo.db.Exec(queryUpdateMenu, menuItem.Name, menuItem.Description, menuItem.Price, menuItem.Image, menuItem.ID)
+	result, err := tx.Exec(queryUpdateMenu, menuItem.Name, menuItem.Description, menuItem.Price, menuItem.Image, menuItem.CategoryID, menuItem.Position, menuItem.ID, menuItem.Version, menuItem.NoSubstitutes)
 	if err != nil {
 		return err // Return error if update fails
 	}
+	if err = repo.checkVersionedWrite(result, menuItem.ID); err != nil {
+		return err
+	}
 
-	// Delete existing ingredients for this menu item
-	queryUpdateMenuIngredients1 := `
-			delete from menu_item_ingredients 
-			where MenuID = $1
-		`
-	_, err = repo.db.Exec(queryUpdateMenuIngredients1, menuItem.ID)
+	// A changed recipe becomes a new version so the old one stays on record
+	changed, err := recipeChanged(tx, menuItem.ID, menuItem.Ingredients)
 	if err != nil {
-		return err // Return error if ingredient deletion fails
+		return err
 	}
-
-	// Insert new ingredients for this menu item
-	for _, v := range menuItem.Ingredients {
-		queryUpdateMenuIngredients2 := `
-			insert into menu_item_ingredients (MenuID, IngredientID, Quantity) values
-			($1, $2, $3)
-		`
-		_, err = repo.db.Exec(queryUpdateMenuIngredients2, menuItem.ID, v.IngredientID, v.Quantity)
-		if err != nil {
-			return err // Return error if ingredient insertion fails
+	if changed {
+		if _, err = addRecipeVersion(tx, menuItem.ID, menuItem.Ingredients, time.Time{}, ""); err != nil {
+			return err
 		}
 	}
-	return nil // Return nil if update is successful
+
+	// Replace channel prices, packaging and schedules
+	if err = saveChannelOptions(tx, menuItem, true); err != nil {
+		return err
+	}
+	if err = saveSchedules(tx, menuItem, true); err != nil {
+		return err
+	}
+	if err = saveBundleSlots(tx, menuItem, true); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // UpdateMenuItemImageRepo updates the image path of a menu item using the provided ID and image path.
@@ -135,34 +273,364 @@ func (r *MenuRepository) UpdateMenuItemImageRepo(id int, imagePath string) error
 	return nil // Return nil if image update is successful
 }
 
-// AddMenuItemRepo adds a new menu item to the database.
+// AddMenuItemRepo adds a new menu item to the database, with its recipe, channel options,
+// schedules and bundle slots, in one transaction.
 func (repo *MenuRepository) AddMenuItemRepo(menuItem models.MenuItem) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Recipe lines must be given in units that convert into the stock unit
+	if err := checkRecipeUnits(tx, menuItem.Ingredients); err != nil {
+		return err
+	}
+
 	// Query to insert new menu item
 	queryAddItem := `
-		INSERT INTO menu_items (Name, Description, Price, Image) 
-		VALUES ($1, $2, $3, $4) RETURNING ID
+		INSERT INTO menu_items (Name, Description, Price, Image, CategoryID, Position, NoSubstitutes) 
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7) RETURNING ID
 	`
 	var newID int
 	// Execute the insert query and get the new ID
-	err := repo.db.QueryRow(queryAddItem, menuItem.Name, menuItem.Description, menuItem.Price, menuItem.Image).Scan(&newID)
+	err = tx.QueryRow(queryAddItem, menuItem.Name, menuItem.Description, menuItem.Price, menuItem.Image, menuItem.CategoryID, menuItem.Position, menuItem.NoSubstitutes).Scan(&newID)
 	if err != nil {
 		return err // Return error if insertion fails
 	}
 
 	menuItem.ID = newID // Set the ID of the new menu item
 
-	// Add ingredients for the new menu item
-	for _, v := range menuItem.Ingredients {
-		queryAddItemIngredients := `
-			INSERT INTO menu_item_ingredients (MenuID, IngredientID, Quantity) 
-			VALUES ($1, $2, $3)
+	// The ingredients of a new menu item are the first version of its recipe
+	if len(menuItem.Ingredients) > 0 {
+		if _, err = addRecipeVersion(tx, menuItem.ID, menuItem.Ingredients, time.Time{}, ""); err != nil {
+			return err
+		}
+	}
+
+	// Add channel prices, packaging and schedules for the new menu item
+	if err = saveChannelOptions(tx, menuItem, false); err != nil {
+		return err
+	}
+	if err = saveSchedules(tx, menuItem, false); err != nil {
+		return err
+	}
+	if err = saveBundleSlots(tx, menuItem, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// getChannelPrices returns the channel price overrides of a menu item keyed by channel.
+func (repo *MenuRepository) getChannelPrices(menuItemID int) (map[string]float64, error) {
+	query := `
+		select Channel, Price from menu_item_channel_prices where MenuID = $1
+	`
+	rows, err := repo.db.Query(query, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices map[string]float64
+	for rows.Next() {
+		var channel string
+		var price float64
+		if err := rows.Scan(&channel, &price); err != nil {
+			return nil, err
+		}
+		if prices == nil {
+			prices = make(map[string]float64)
+		}
+		prices[channel] = price
+	}
+	return prices, rows.Err()
+}
+
+// getPackaging returns the packaging ingredients of a menu item.
+func (repo *MenuRepository) getPackaging(menuItemID int) ([]models.MenuItemIngredient, error) {
+	query := `
+		select IngredientID, Quantity from menu_item_packaging where MenuID = $1
+	`
+	rows, err := repo.db.Query(query, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var packaging []models.MenuItemIngredient
+	for rows.Next() {
+		var ingredient models.MenuItemIngredient
+		if err := rows.Scan(&ingredient.IngredientID, &ingredient.Quantity); err != nil {
+			return nil, err
+		}
+		packaging = append(packaging, ingredient)
+	}
+	return packaging, rows.Err()
+}
+
+// saveChannelOptions writes the channel prices and packaging of a menu item.
+// When replace is true the existing rows are removed first.
+func saveChannelOptions(q queryer, menuItem models.MenuItem, replace bool) error {
+	if replace {
+		if _, err := q.Exec(`delete from menu_item_channel_prices where MenuID = $1`, menuItem.ID); err != nil {
+			return err
+		}
+		if _, err := q.Exec(`delete from menu_item_packaging where MenuID = $1`, menuItem.ID); err != nil {
+			return err
+		}
+	}
+
+	for channel, price := range menuItem.ChannelPrices {
+		queryAddChannelPrice := `
+			insert into menu_item_channel_prices (MenuID, Channel, Price) values
+			($1, $2, $3)
+		`
+		if _, err := q.Exec(queryAddChannelPrice, menuItem.ID, channel, price); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range menuItem.Packaging {
+		queryAddPackaging := `
+			insert into menu_item_packaging (MenuID, IngredientID, Quantity) values
+			($1, $2, $3)
 		`
-		_, err = repo.db.Exec(queryAddItemIngredients, menuItem.ID, v.IngredientID, v.Quantity)
+		if _, err := q.Exec(queryAddPackaging, menuItem.ID, v.IngredientID, v.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setBundleServings works out how many of each bundle can be made. A slot can be filled as
+// long as one of its products is available, and the bundle is limited by its scarcest slot.
+func (repo *MenuRepository) setBundleServings(menuItems []models.MenuItem) error {
+	byID := make(map[int]models.MenuItem, len(menuItems))
+	var categoryIDs []int
+	for _, item := range menuItems {
+		byID[item.ID] = item
+		for _, slot := range item.Bundle {
+			if slot.CategoryID != 0 {
+				categoryIDs = append(categoryIDs, slot.CategoryID)
+			}
+		}
+	}
+	byCategory := map[int][]int{}
+	if len(categoryIDs) > 0 {
+		var err error
+		if byCategory, err = productsByCategory(repo.db, categoryIDs); err != nil {
+			return err
+		}
+	}
+
+	for i, bundle := range menuItems {
+		if len(bundle.Bundle) == 0 {
+			continue
+		}
+		var servings *int
+		for _, slot := range bundle.Bundle {
+			candidates := []int{slot.ProductID}
+			if slot.CategoryID != 0 {
+				candidates = byCategory[slot.CategoryID]
+			}
+
+			// The best candidate decides how often the slot can be filled; nil means unlimited
+			best, unlimited := 0, false
+			for _, id := range candidates {
+				item, ok := byID[id]
+				if !ok || item.EightySix != nil {
+					continue
+				}
+				if item.AvailableServings == nil {
+					unlimited = true
+					break
+				}
+				if n := *item.AvailableServings / slot.Quantity; n > best {
+					best = n
+				}
+			}
+			if unlimited {
+				continue
+			}
+			if servings == nil || best < *servings {
+				servings = &best
+			}
+		}
+
+		menuItems[i].AvailableServings = servings
+		menuItems[i].SoldOut = bundle.EightySix != nil || (servings != nil && *servings == 0)
+	}
+	return nil
+}
+
+// GetAvailability retrieves what decides whether the given menu items can be ordered: whether
+// they are archived or 86'd, their availability windows and their bundle slots. The fixed
+// products of bundles are retrieved along with them. Items that do not exist are left out.
+func (repo *MenuRepository) GetAvailability(ids []int) (map[int]models.MenuItem, error) {
+	queryItems := `
+		select mi.ID, mi.Name, mi.EightySixReason, mi.EightySixedAt, mi.ArchivedAt
+		from menu_items mi
+		where mi.ID = ANY($1)
+		or mi.ID in (select ProductID from menu_item_bundle_slots where BundleID = ANY($1))
+	`
+	rows, err := repo.db.Query(queryItems, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[int]models.MenuItem)
+	var found []int
+	for rows.Next() {
+		var item models.MenuItem
+		var reason sql.NullString
+		var since, archivedAt sql.NullTime
+		if err := rows.Scan(&item.ID, &item.Name, &reason, &since, &archivedAt); err != nil {
+			return nil, err
+		}
+		if reason.Valid {
+			item.EightySix = &models.EightySix{Reason: reason.String, Since: since.Time}
+		}
+		if archivedAt.Valid {
+			item.ArchivedAt = &archivedAt.Time
+		}
+		items[item.ID] = item
+		found = append(found, item.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scheduleRows, err := repo.db.Query(querySchedules+` where MenuID = ANY($1) order by ID`, pq.Array(found))
+	if err != nil {
+		return nil, err
+	}
+	defer scheduleRows.Close()
+	for scheduleRows.Next() {
+		menuID, schedule, err := scanSchedule(scheduleRows)
+		if err != nil {
+			return nil, err
+		}
+		item := items[menuID]
+		item.Schedules = append(item.Schedules, schedule)
+		items[menuID] = item
+	}
+	if err := scheduleRows.Err(); err != nil {
+		return nil, err
+	}
+
+	slotRows, err := repo.db.Query(`
+		select ID, BundleID, COALESCE(ProductID, 0), COALESCE(CategoryID, 0), Quantity
+		from menu_item_bundle_slots where BundleID = ANY($1) order by ID
+	`, pq.Array(found))
+	if err != nil {
+		return nil, err
+	}
+	defer slotRows.Close()
+	for slotRows.Next() {
+		var slot models.BundleSlot
+		var bundleID int
+		if err := slotRows.Scan(&slot.ID, &bundleID, &slot.ProductID, &slot.CategoryID, &slot.Quantity); err != nil {
+			return nil, err
+		}
+		item := items[bundleID]
+		item.Bundle = append(item.Bundle, slot)
+		items[bundleID] = item
+	}
+	return items, slotRows.Err()
+}
+
+// getSchedules returns the availability windows of a menu item.
+func (repo *MenuRepository) getSchedules(menuItemID int) ([]models.MenuItemSchedule, error) {
+	rows, err := repo.db.Query(querySchedules+` where MenuID = $1 order by ID`, menuItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []models.MenuItemSchedule
+	for rows.Next() {
+		_, schedule, err := scanSchedule(rows)
 		if err != nil {
-			return err // Return error if ingredient insertion fails
+			return nil, err
 		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// querySchedules selects availability windows in the form scanSchedule reads.
+const querySchedules = `
+	select MenuID, Days,
+		COALESCE(to_char(StartTime, 'HH24:MI'), ''), COALESCE(to_char(EndTime, 'HH24:MI'), ''),
+		COALESCE(to_char(StartDate, 'YYYY-MM-DD'), ''), COALESCE(to_char(EndDate, 'YYYY-MM-DD'), '')
+	from menu_item_schedules`
+
+// scanSchedule reads an availability window selected with querySchedules and the menu item it belongs to.
+func scanSchedule(rows *sql.Rows) (int, models.MenuItemSchedule, error) {
+	var menuID int
+	var schedule models.MenuItemSchedule
+	var days pq.Int64Array
+	if err := rows.Scan(&menuID, &days, &schedule.StartTime, &schedule.EndTime, &schedule.StartDate, &schedule.EndDate); err != nil {
+		return 0, schedule, err
 	}
-	return nil // Return nil if item and ingredients are added successfully
+	for _, day := range days {
+		schedule.Days = append(schedule.Days, int(day))
+	}
+	return menuID, schedule, nil
+}
+
+// saveSchedules writes the availability windows of a menu item.
+// When replace is true the existing windows are removed first.
+func saveSchedules(q queryer, menuItem models.MenuItem, replace bool) error {
+	if replace {
+		if _, err := q.Exec(`delete from menu_item_schedules where MenuID = $1`, menuItem.ID); err != nil {
+			return err
+		}
+	}
+
+	queryAddSchedule := `
+		insert into menu_item_schedules (MenuID, Days, StartTime, EndTime, StartDate, EndDate) values
+		($1, $2, NULLIF($3, '')::time, NULLIF($4, '')::time, NULLIF($5, '')::date, NULLIF($6, '')::date)
+	`
+	for _, v := range menuItem.Schedules {
+		var days interface{}
+		if len(v.Days) > 0 {
+			days = pq.Array(v.Days)
+		}
+		_, err := q.Exec(queryAddSchedule, menuItem.ID, days, v.StartTime, v.EndTime, v.StartDate, v.EndDate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetEightySix takes a menu item off sale with the given reason, or puts it back on sale when reason is empty.
+func (repo *MenuRepository) SetEightySix(menuItemID int, reason string) error {
+	query := `
+		update menu_items
+		set EightySixReason = NULLIF($1, ''),
+			EightySixedAt = CASE WHEN $1 = '' THEN NULL ELSE CURRENT_TIMESTAMP END
+		where ID = $2
+	`
+	result, err := repo.db.Exec(query, reason, menuItemID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.ErrMenuItemNotFound
+	}
+	return nil
+}
+
+// ImageUsedByOthers reports whether a menu item other than the given one shows the image.
+// Images are stored under content-hash names, so identical uploads share a file.
+func (repo *MenuRepository) ImageUsedByOthers(imagePath string, menuItemID int) (bool, error) {
+	var used bool
+	err := repo.db.QueryRow(`select exists (select 1 from menu_items where Image = $1 and ID <> $2)`, imagePath, menuItemID).Scan(&used)
+	return used, err
 }
 
 // MenuCheckByIDRepo checks if a menu item exists by its ID.
@@ -174,5 +642,6 @@ func (repo *MenuRepository) MenuCheckByIDRepo(ID int) bool {
 	if err != nil {
 		return false // Return false if query fails
 	}
+	defer rows.Close()
 	return rows.Next() // Return true if the menu item exists
 }
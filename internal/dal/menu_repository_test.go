@@ -0,0 +1,53 @@
+package dal
+
+import (
+	"testing"
+)
+
+func TestGetAvailability(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewMenuRepository(db)
+
+	plain := addTestMenuItem(t, db, 3)
+	archived := addTestMenuItem(t, db, 3)
+	eightySixed := addTestMenuItem(t, db, 3)
+	scheduled := addTestMenuItem(t, db, 3)
+	bundle := addTestMenuItem(t, db, 5)
+	setup := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE menu_items SET ArchivedAt = CURRENT_TIMESTAMP WHERE ID = $1`, []interface{}{archived}},
+		{`UPDATE menu_items SET EightySixReason = 'out of oat milk', EightySixedAt = CURRENT_TIMESTAMP WHERE ID = $1`, []interface{}{eightySixed}},
+		{`INSERT INTO menu_item_schedules (MenuID, StartTime, EndTime) VALUES ($1, '06:00', '11:00')`, []interface{}{scheduled}},
+		{`INSERT INTO menu_item_bundle_slots (BundleID, ProductID, Quantity) VALUES ($1, $2, 1)`, []interface{}{bundle, eightySixed}},
+	}
+	for _, step := range setup {
+		if _, err := db.Exec(step.query, step.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := repo.GetAvailability([]int{plain, archived, scheduled, bundle, -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 5 {
+		t.Fatalf("got %d items, want the 4 requested that exist and the bundle's product", len(items))
+	}
+	if items[archived].ArchivedAt == nil {
+		t.Error("archived item has no ArchivedAt")
+	}
+	if items[eightySixed].EightySix == nil || items[eightySixed].EightySix.Reason != "out of oat milk" {
+		t.Errorf("86'd item = %+v", items[eightySixed].EightySix)
+	}
+	if s := items[scheduled].Schedules; len(s) != 1 || s[0].StartTime != "06:00" || s[0].EndTime != "11:00" {
+		t.Errorf("schedules = %+v", s)
+	}
+	if b := items[bundle].Bundle; len(b) != 1 || b[0].ProductID != eightySixed {
+		t.Errorf("bundle slots = %+v", b)
+	}
+	if p := items[plain]; p.ArchivedAt != nil || p.EightySix != nil || len(p.Schedules) != 0 {
+		t.Errorf("plain item = %+v", p)
+	}
+}
@@ -0,0 +1,178 @@
+package dal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"hot-coffee/models"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx, so helpers can run inside or outside a transaction.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// orderSnapshot is the recorded state of an order at one point in time.
+type orderSnapshot struct {
+	CustomerName string                 `json:"customer_name"`
+	Status       string                 `json:"status"`
+	Channel      string                 `json:"channel"`
+	TableID      *int                   `json:"table_id"`
+	Notes        map[string]interface{} `json:"notes"`
+	Items        []models.OrderItem     `json:"items"`
+	Checks       []snapshotCheck        `json:"checks"`
+}
+
+type snapshotCheck struct {
+	ID     int     `json:"check_id"`
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+	Status string  `json:"status"`
+}
+
+// GetHistory retrieves the change history of an order, oldest first.
+func (repo *OrderRepository) GetHistory(orderID int) ([]models.OrderChange, error) {
+	query := `
+		SELECT ID, OrderID, Action, Actor, COALESCE(Reason, ''), Before, After, ChangedAt
+		FROM order_history
+		WHERE OrderID = $1
+		ORDER BY ChangedAt, ID
+	`
+	rows, err := repo.db.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for order_history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.OrderChange{}
+	for rows.Next() {
+		var change models.OrderChange
+		var before, after []byte
+		if err := rows.Scan(&change.ID, &change.OrderID, &change.Action, &change.Actor, &change.Reason, &before, &after, &change.ChangedAt); err != nil {
+			return nil, fmt.Errorf("error scanning row in order_history: %w", err)
+		}
+		json.Unmarshal(before, &change.Before)
+		json.Unmarshal(after, &change.After)
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// snapshotOrder reads the current state of an order as a generic map, or nil if the order does not exist.
+func snapshotOrder(q queryer, orderID int) (map[string]interface{}, error) {
+	var snapshot orderSnapshot
+	var notes []byte
+	var tableID sql.NullInt64
+	queryOrder := `
+		SELECT CustomerName, Status, Notes, Channel, TableID FROM orders WHERE ID = $1
+	`
+	err := q.QueryRow(queryOrder, orderID).Scan(&snapshot.CustomerName, &snapshot.Status, &notes, &snapshot.Channel, &tableID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	json.Unmarshal(notes, &snapshot.Notes)
+	if tableID.Valid {
+		id := int(tableID.Int64)
+		snapshot.TableID = &id
+	}
+
+	rows, err := q.Query(`
+		SELECT ID, ProductID, Seat, Quantity, COALESCE(Price, 0), Status FROM order_items
+		WHERE OrderID = $1 ORDER BY Seat, ProductID
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.LineID, &item.ProductID, &item.Seat, &item.Quantity, &item.Price, &item.Status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		snapshot.Items = append(snapshot.Items, item)
+	}
+	rows.Close()
+
+	rows, err = q.Query(`
+		SELECT ID, Label, Amount, Status FROM order_checks WHERE OrderID = $1 ORDER BY ID
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var check snapshotCheck
+		if err := rows.Scan(&check.ID, &check.Label, &check.Amount, &check.Status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		snapshot.Checks = append(snapshot.Checks, check)
+	}
+	rows.Close()
+
+	// Round-trip through JSON so snapshots compare the same way they are stored
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// recordOrderChange stores the fields that differ between two snapshots of an order.
+// Nothing is recorded when an update did not change anything.
+func recordOrderChange(q queryer, orderID int, action string, change models.ChangeInfo, before, after map[string]interface{}) error {
+	changedBefore, changedAfter := diffSnapshots(before, after)
+	if changedBefore == nil && changedAfter == nil {
+		return nil
+	}
+
+	beforeJSON, err := json.Marshal(changedBefore)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := json.Marshal(changedAfter)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO order_history (OrderID, Action, Actor, Reason, Before, After)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+	`
+	_, err = q.Exec(query, orderID, action, change.Actor, change.Reason, beforeJSON, afterJSON)
+	if err != nil {
+		return fmt.Errorf("failed to record order history: %w", err)
+	}
+	return nil
+}
+
+// diffSnapshots returns only the fields whose values differ. A missing snapshot
+// (order created or deleted) keeps the other side whole.
+func diffSnapshots(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if before == nil || after == nil {
+		return before, after
+	}
+
+	changedBefore := make(map[string]interface{})
+	changedAfter := make(map[string]interface{})
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			changedBefore[key] = before[key]
+			changedAfter[key] = value
+		}
+	}
+	if len(changedAfter) == 0 {
+		return nil, nil
+	}
+	return changedBefore, changedAfter
+}
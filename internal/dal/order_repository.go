@@ -4,10 +4,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"hot-coffee/models"
+
+	"github.com/lib/pq"
 )
 
 type OrderRepository struct {
@@ -18,7 +22,7 @@ func NewOrderRepository(db *sql.DB) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
-func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []models.BatchOrderInventoryUpdate, error) {
+func (repo *OrderRepository) Add(order models.Order, change models.ChangeInfo) (models.BatchOrderInfo, []models.BatchOrderInventoryUpdate, error) {
 	processInfo := models.BatchOrderInfo{
 		CustomerName: order.CustomerName,
 		Status:       models.StatusOrderRejected,
@@ -29,15 +33,12 @@ func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []m
 		return processInfo, []models.BatchOrderInventoryUpdate{}, err
 	}
 
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	// Rolling back after a successful commit is a no-op
+	defer tx.Rollback()
 
 	queryOrder := `
-        INSERT INTO orders (CustomerName, Notes)
-        VALUES ($1, $2)
+        INSERT INTO orders (CustomerName, Notes, Channel)
+        VALUES ($1, $2, $3)
         RETURNING ID
     `
 
@@ -48,7 +49,7 @@ func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []m
 	}
 
 	var ID int
-	err = tx.QueryRow(queryOrder, order.CustomerName, notesJSON).Scan(&ID)
+	err = tx.QueryRow(queryOrder, order.CustomerName, notesJSON, order.Channel).Scan(&ID)
 	if err != nil {
 		processInfo.Reason = "internal server error. Failed to scan ID"
 		return processInfo, []models.BatchOrderInventoryUpdate{}, err
@@ -56,35 +57,48 @@ func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []m
 	processInfo.OrderID = ID
 
 	queryOrderItems := `
-		INSERT INTO order_items (ProductID, Quantity, OrderID) VALUES
-		($1, $2, $3)
-		ON CONFLICT (OrderID, ProductID)
-		DO UPDATE SET Quantity = order_items.Quantity + EXCLUDED.Quantity;
+		INSERT INTO order_items (ProductID, Quantity, OrderID, Price, Seat, RecipeVersionID)
+		SELECT $1, $2, $3, $4, $5, RecipeVersionID FROM menu_items WHERE ID = $1
+		ON CONFLICT (OrderID, ProductID, Seat)
+		DO UPDATE SET Quantity = order_items.Quantity + EXCLUDED.Quantity, Price = EXCLUDED.Price,
+			Status = 'queued',
+			StatusChangedAt = CASE WHEN order_items.Status = 'queued' THEN order_items.StatusChangedAt ELSE CURRENT_TIMESTAMP END
+		RETURNING ID;
 	`
 
+	// The channel price overrides the base menu price when one is set.
 	queryGetPrice := `
-		SELECT price FROM menu_items WHERE id = $1
+		SELECT COALESCE(cp.Price, mi.Price)
+		FROM menu_items mi
+		LEFT JOIN menu_item_channel_prices cp ON cp.MenuID = mi.ID AND cp.Channel = $2
+		WHERE mi.ID = $1
 	`
 
+	// Packaging is deducted together with the recipe when the order leaves the shop. Recipe
+	// quantities are converted into the stock unit, NULL meaning the unit no longer converts,
+	// and what the line uses is rounded up to the decimals kept for the stock unit.
 	queryGetIngredients := `
-		SELECT IngredientID, Quantity FROM menu_item_ingredients WHERE MenuID = $1
+		SELECT mii.IngredientID, round_to_unit(convert_to_stock_unit(mii.Quantity, mii.Unit, mii.IngredientID) * $3, inv.Unit), false
+		FROM menu_item_ingredients mii
+		JOIN inventory inv ON inv.IngredientID = mii.IngredientID
+		WHERE mii.MenuID = $1
+		UNION ALL
+		SELECT p.IngredientID, round_to_unit(p.Quantity * $3, inv.Unit), true
+		FROM menu_item_packaging p
+		JOIN inventory inv ON inv.IngredientID = p.IngredientID
+		WHERE p.MenuID = $1 AND $2
 	`
 
 	queryUpdateInventory := `
 		UPDATE inventory SET Quantity = Quantity - $1 WHERE IngredientID = $2 AND Quantity >= $1
+		RETURNING Quantity
 	`
 	inventoryInfo := []models.BatchOrderInventoryUpdate{}
+	packaged := NeedsPackaging(order.Channel)
 	for _, v := range order.Items {
 
-		_, err = tx.Exec(queryOrderItems, v.ProductID, v.Quantity, ID)
-		if err != nil {
-			processInfo.Reason = "internal server error. " + err.Error()
-			processInfo.Total = 0
-			return processInfo, []models.BatchOrderInventoryUpdate{}, err
-		}
-
 		var price float64
-		err = tx.QueryRow(queryGetPrice, v.ProductID).Scan(&price)
+		err = tx.QueryRow(queryGetPrice, v.ProductID, order.Channel).Scan(&price)
 		if err != nil {
 			processInfo.Reason = "internal server error." + err.Error()
 			processInfo.Total = 0
@@ -92,67 +106,146 @@ func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []m
 		}
 		processInfo.Total += float64(v.Quantity) * price
 
-		var ingredients []struct {
-			IngredientID     int
-			RequiredQuantity int
+		var lineID int
+		err = tx.QueryRow(queryOrderItems, v.ProductID, v.Quantity, ID, price, v.Seat).Scan(&lineID)
+		if err != nil {
+			processInfo.Reason = "internal server error. " + err.Error()
+			processInfo.Total = 0
+			return processInfo, []models.BatchOrderInventoryUpdate{}, err
 		}
 
-		rows, err := tx.Query(queryGetIngredients, v.ProductID)
+		// A bundle deducts the ingredients of the products it is made of
+		var components []bundleComponent
+		components, err = resolveBundle(tx, v.ProductID, v.Choices)
 		if err != nil {
-			processInfo.Reason = "internal server error. Failed to get ingredients."
+			processInfo.Reason = err.Error()
 			processInfo.Total = 0
 			return processInfo, []models.BatchOrderInventoryUpdate{}, err
 		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var ingredient struct {
-				IngredientID     int
-				RequiredQuantity int
-			}
-			if err := rows.Scan(&ingredient.IngredientID, &ingredient.RequiredQuantity); err != nil {
-				processInfo.Reason = "internal server error. Failed to scan ingredient."
+		deductions := []bundleComponent{{ProductID: v.ProductID, Quantity: v.Quantity}}
+		if components != nil {
+			if err = addBundleComponents(tx, lineID, components, v.Quantity, price*float64(v.Quantity)); err != nil {
+				processInfo.Reason = "internal server error. Failed to record bundle components."
 				processInfo.Total = 0
 				return processInfo, []models.BatchOrderInventoryUpdate{}, err
 			}
-			ingredients = append(ingredients, ingredient)
+			deductions = deductions[:0]
+			for _, c := range components {
+				deductions = append(deductions, bundleComponent{ProductID: c.ProductID, Quantity: c.Quantity * v.Quantity})
+			}
 		}
-		for _, ing := range ingredients {
-			totalRequired := ing.RequiredQuantity * v.Quantity
 
-			var availableQuantity int
-			var InvName string
+		for _, deduction := range deductions {
+			var ingredients []struct {
+				IngredientID     int
+				RequiredQuantity sql.NullFloat64
+				Packaging        bool
+			}
 
-			err = tx.QueryRow("SELECT quantity, name FROM inventory WHERE IngredientID = $1", ing.IngredientID).Scan(&availableQuantity, &InvName)
+			rows, err := tx.Query(queryGetIngredients, deduction.ProductID, packaged, deduction.Quantity)
 			if err != nil {
-				processInfo.Reason = fmt.Sprintf("internal server error. Failed to check inventory. ID=%d", ing.IngredientID)
+				processInfo.Reason = "internal server error. Failed to get ingredients."
 				processInfo.Total = 0
 				return processInfo, []models.BatchOrderInventoryUpdate{}, err
 			}
-
-			if availableQuantity < totalRequired {
-				processInfo.Reason = fmt.Sprintf("insufficient_inventory. IngredientID: %d. Required: %d, Available: %d", ing.IngredientID, totalRequired, availableQuantity)
-				processInfo.Total = 0
-				return processInfo, []models.BatchOrderInventoryUpdate{}, fmt.Errorf("%s", processInfo.Reason)
+			// The rows are closed before the next query runs on the transaction
+			for rows.Next() {
+				var ingredient struct {
+					IngredientID     int
+					RequiredQuantity sql.NullFloat64
+					Packaging        bool
+				}
+				if err := rows.Scan(&ingredient.IngredientID, &ingredient.RequiredQuantity, &ingredient.Packaging); err != nil {
+					rows.Close()
+					processInfo.Reason = "internal server error. Failed to scan ingredient."
+					processInfo.Total = 0
+					return processInfo, []models.BatchOrderInventoryUpdate{}, err
+				}
+				ingredients = append(ingredients, ingredient)
 			}
-
-			_, err = tx.Exec(queryUpdateInventory, totalRequired, ing.IngredientID)
-			if err != nil {
-				processInfo.Reason = "internal server error. Failed to update inventory."
+			rows.Close()
+			if err = rows.Err(); err != nil {
+				processInfo.Reason = "internal server error. Failed to get ingredients."
 				processInfo.Total = 0
 				return processInfo, []models.BatchOrderInventoryUpdate{}, err
 			}
-
-			InvInfo := models.BatchOrderInventoryUpdate{
-				IngredientID:  ing.IngredientID,
-				Name:          InvName,
-				Quantity_used: totalRequired,
-				Remaining:     availableQuantity - totalRequired,
+			for _, ing := range ingredients {
+				if !ing.RequiredQuantity.Valid {
+					processInfo.Reason = fmt.Sprintf("the recipe unit of ingredient %d cannot be converted into its stock unit", ing.IngredientID)
+					processInfo.Total = 0
+					return processInfo, []models.BatchOrderInventoryUpdate{}, models.ErrIncompatibleUnit
+				}
+				totalRequired := ing.RequiredQuantity.Float64
+
+				var availableQuantity float64
+				var InvName string
+
+				err = tx.QueryRow("SELECT quantity, name FROM inventory WHERE IngredientID = $1", ing.IngredientID).Scan(&availableQuantity, &InvName)
+				if err != nil {
+					processInfo.Reason = fmt.Sprintf("internal server error. Failed to check inventory. ID=%d", ing.IngredientID)
+					processInfo.Total = 0
+					return processInfo, []models.BatchOrderInventoryUpdate{}, err
+				}
+
+				// A recipe ingredient that ran out is replaced by the first substitute with enough stock
+				if availableQuantity < totalRequired && !ing.Packaging {
+					substitution, substituteAvailable, found, err := findSubstitute(tx, deduction.ProductID, ing.IngredientID, totalRequired)
+					if err != nil {
+						processInfo.Reason = fmt.Sprintf("internal server error. Failed to find a substitute. ID=%d", ing.IngredientID)
+						processInfo.Total = 0
+						return processInfo, []models.BatchOrderInventoryUpdate{}, err
+					}
+					if found {
+						substitution.Ingredient = InvName
+						if err = addOrderItemSubstitution(tx, lineID, substitution); err != nil {
+							processInfo.Reason = "internal server error. Failed to record substitution."
+							processInfo.Total = 0
+							return processInfo, []models.BatchOrderInventoryUpdate{}, err
+						}
+						substitution.ProductID = v.ProductID
+						processInfo.Substitutions = append(processInfo.Substitutions, substitution)
+						ing.IngredientID, InvName = substitution.SubstituteID, substitution.Substitute
+						totalRequired, availableQuantity = substitution.Quantity, substituteAvailable
+					}
+				}
+
+				if availableQuantity < totalRequired {
+					err = fmt.Errorf("%w. IngredientID: %d. Required: %s, Available: %s", models.ErrOutOfStock, ing.IngredientID,
+						strconv.FormatFloat(totalRequired, 'f', -1, 64), strconv.FormatFloat(availableQuantity, 'f', -1, 64))
+					processInfo.Reason = err.Error()
+					processInfo.Total = 0
+					return processInfo, []models.BatchOrderInventoryUpdate{}, err
+				}
+
+				var remaining float64
+				err = tx.QueryRow(queryUpdateInventory, totalRequired, ing.IngredientID).Scan(&remaining)
+				if err != nil {
+					processInfo.Reason = "internal server error. Failed to update inventory."
+					processInfo.Total = 0
+					return processInfo, []models.BatchOrderInventoryUpdate{}, err
+				}
+
+				InvInfo := models.BatchOrderInventoryUpdate{
+					IngredientID:  ing.IngredientID,
+					Name:          InvName,
+					Quantity_used: totalRequired,
+					Remaining:     remaining,
+				}
+				inventoryInfo = append(inventoryInfo, InvInfo)
 			}
-			inventoryInfo = append(inventoryInfo, InvInfo)
 		}
 	}
 
+	after, err := snapshotOrder(tx, ID)
+	if err == nil {
+		err = recordOrderChange(tx, ID, models.OrderActionCreated, change, nil, after)
+	}
+	if err != nil {
+		processInfo.Reason = "internal server error. Failed to record order history."
+		processInfo.Total = 0
+		return processInfo, []models.BatchOrderInventoryUpdate{}, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		processInfo.Reason = "Internal server error. Error commiting transaction."
@@ -166,7 +259,7 @@ func (repo *OrderRepository) Add(order models.Order) (models.BatchOrderInfo, []m
 
 func (repo *OrderRepository) GetAll() ([]models.Order, error) {
 	query := `
-	 SELECT ID, CustomerName, Status, Notes, CreatedAt
+	 SELECT ID, CustomerName, Status, Notes, Channel, TableID, CreatedAt, Version
 	 FROM orders`
 
 	rows, err := repo.db.Query(query)
@@ -180,9 +273,11 @@ func (repo *OrderRepository) GetAll() ([]models.Order, error) {
 	for rows.Next() {
 		var order models.Order
 		var notes []byte
-		if err := rows.Scan(&order.ID, &order.CustomerName, &order.Status, &notes, &order.CreatedAt); err != nil {
+		var tableID sql.NullInt64
+		if err := rows.Scan(&order.ID, &order.CustomerName, &order.Status, &notes, &order.Channel, &tableID, &order.CreatedAt, &order.Version); err != nil {
 			return nil, err
 		}
+		order.TableID = int(tableID.Int64)
 
 		json.Unmarshal(notes, &order.Notes)
 
@@ -200,18 +295,20 @@ func (repo *OrderRepository) GetAll() ([]models.Order, error) {
 
 func (repo *OrderRepository) GetOrderByID(id int) (models.Order, error) {
 	query := `
-		SELECT ID, CustomerName, Status, Notes, CreatedAt
+		SELECT ID, CustomerName, Status, Notes, Channel, TableID, CreatedAt, Version
 		FROM orders WHERE ID = $1`
 
 	var order models.Order
 	var notes []byte
-	err := repo.db.QueryRow(query, id).Scan(&order.ID, &order.CustomerName, &order.Status, &notes, &order.CreatedAt)
+	var tableID sql.NullInt64
+	err := repo.db.QueryRow(query, id).Scan(&order.ID, &order.CustomerName, &order.Status, &notes, &order.Channel, &tableID, &order.CreatedAt, &order.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.Order{}, models.ErrOrderNotFound
 		}
 		return models.Order{}, err
 	}
+	order.TableID = int(tableID.Int64)
 
 	json.Unmarshal(notes, &order.Notes)
 
@@ -223,47 +320,205 @@ func (repo *OrderRepository) GetOrderByID(id int) (models.Order, error) {
 		return models.Order{}, err
 	}
 	order.Items = items
+
+	checks, err := repo.GetChecks(id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	order.Checks = checks
 	return order, nil
 }
 
-func (repo *OrderRepository) SaveUpdatedOrder(updatedOrder models.Order, OrderID string) error {
+func (repo *OrderRepository) SaveUpdatedOrder(updatedOrder models.Order, OrderID string, change models.ChangeInfo) error {
+	id, err := strconv.Atoi(OrderID)
+	if err != nil {
+		return models.ErrOrderNotFound
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	queryCheckStatus := `
-	select Status from orders where ID = $1
+	select Status, Channel, Version from orders where ID = $1 for update
 	`
-	var Status string
-	err := repo.db.QueryRow(queryCheckStatus, OrderID).Scan(&Status)
+	var Status, Channel string
+	var version int
+	err = tx.QueryRow(queryCheckStatus, id).Scan(&Status, &Channel, &version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.ErrOrderNotFound
 		}
 		return err
 	}
+	// The row is locked, so the version cannot change before this update commits
+	if updatedOrder.Version != 0 && updatedOrder.Version != version {
+		return models.ErrVersionConflict
+	}
 
 	if Status == "closed" {
 		return models.ErrOrderClosed
 	}
+
+	before, err := snapshotOrder(tx, id)
+	if err != nil {
+		return err
+	}
+
+	// Notes are replaced as a whole, so an update without notes clears them
+	var notesJSON []byte
+	if updatedOrder.Notes != nil {
+		if notesJSON, err = json.Marshal(updatedOrder.Notes); err != nil {
+			return fmt.Errorf("failed to marshal notes: %w", err)
+		}
+	}
 	queryUpdateOrder := `
 	update orders 
-	set CustomerName = $1
+	set CustomerName = $1, Notes = $3::jsonb
 	where ID = $2
 	`
-	_, err = repo.db.Query(queryUpdateOrder, updatedOrder.CustomerName, OrderID)
+	_, err = tx.Exec(queryUpdateOrder, updatedOrder.CustomerName, id, notesJSON)
 	if err != nil {
 		return err
 	}
+
+	// Sum up repeated lines so each (product, seat) pair is written once
+	type lineKey struct{ productID, seat int }
+	quantities := make(map[lineKey]int)
+	var keys []lineKey
 	for _, v := range updatedOrder.Items {
-		queryUpdateOrderItems := `
-		update order_items set ProductID = $1, Quantity = $2 where OrderID = $3
-		`
-		_, err = repo.db.Query(queryUpdateOrderItems, v.ProductID, v.Quantity, OrderID)
+		key := lineKey{v.ProductID, v.Seat}
+		if _, ok := quantities[key]; !ok {
+			keys = append(keys, key)
+		}
+		quantities[key] += v.Quantity
+	}
+
+	// Remove the lines that are no longer on the order
+	var productIDs, seats []int
+	for _, key := range keys {
+		productIDs = append(productIDs, key.productID)
+		seats = append(seats, key.seat)
+	}
+	queryDeleteItems := `
+	delete from order_items oi
+	where oi.OrderID = $1
+	and (oi.ProductID, oi.Seat) not in (select * from unnest($2::int[], $3::int[]))
+	`
+	_, err = tx.Exec(queryDeleteItems, id, pq.Array(productIDs), pq.Array(seats))
+	if err != nil {
+		return err
+	}
+
+	// Bundle lines sent without choices keep the components they were made of while their
+	// quantity stays the same, so an order can be saved back as it was read
+	var keptProductIDs, keptSeats []int
+	kept := make(map[lineKey]bool)
+	for _, v := range updatedOrder.Items {
+		key := lineKey{v.ProductID, v.Seat}
+		if len(v.Choices) > 0 || kept[key] {
+			continue
+		}
+		var quantity int
+		err = tx.QueryRow(`
+		select oi.Quantity from order_items oi
+		where oi.OrderID = $1 and oi.ProductID = $2 and oi.Seat = $3
+		and exists (select 1 from order_item_components c where c.OrderItemID = oi.ID)
+		`, id, v.ProductID, v.Seat).Scan(&quantity)
+		if err == sql.ErrNoRows {
+			continue
+		}
 		if err != nil {
 			return err
 		}
+		if quantity == quantities[key] {
+			kept[key] = true
+			keptProductIDs = append(keptProductIDs, key.productID)
+			keptSeats = append(keptSeats, key.seat)
+		}
 	}
-	return nil
+
+	// Lines that stay on the order keep their price, fulfillment status and recipe version.
+	// A line whose quantity goes up has servings still to make, so it is queued again.
+	queryUpsertItem := `
+	insert into order_items (OrderID, ProductID, Seat, Quantity, Price, RecipeVersionID)
+	select $1, mi.ID, $3, $4, COALESCE(cp.Price, mi.Price), mi.RecipeVersionID
+	from menu_items mi
+	left join menu_item_channel_prices cp on cp.MenuID = mi.ID and cp.Channel = $5
+	where mi.ID = $2
+	on conflict (OrderID, ProductID, Seat)
+	do update set Quantity = EXCLUDED.Quantity,
+		Status = case when EXCLUDED.Quantity > order_items.Quantity then 'queued' else order_items.Status end,
+		StatusChangedAt = case when EXCLUDED.Quantity > order_items.Quantity and order_items.Status <> 'queued'
+			then CURRENT_TIMESTAMP else order_items.StatusChangedAt end
+	`
+	for _, key := range keys {
+		_, err = tx.Exec(queryUpsertItem, id, key.productID, key.seat, quantities[key], Channel)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Rebuild the components of bundle lines from the requested choices
+	queryDeleteComponents := `
+	delete from order_item_components
+	where OrderItemID in (
+		select ID from order_items
+		where OrderID = $1 and (ProductID, Seat) not in (select * from unnest($2::int[], $3::int[]))
+	)
+	`
+	if _, err = tx.Exec(queryDeleteComponents, id, pq.Array(keptProductIDs), pq.Array(keptSeats)); err != nil {
+		return err
+	}
+	for _, v := range updatedOrder.Items {
+		if kept[lineKey{v.ProductID, v.Seat}] {
+			continue
+		}
+		components, err := resolveBundle(tx, v.ProductID, v.Choices)
+		if err != nil {
+			return err
+		}
+		if components == nil {
+			continue
+		}
+		var lineID int
+		var price float64
+		err = tx.QueryRow(`select ID, COALESCE(Price, 0) from order_items where OrderID = $1 and ProductID = $2 and Seat = $3`,
+			id, v.ProductID, v.Seat).Scan(&lineID, &price)
+		if err != nil {
+			return err
+		}
+		if err = addBundleComponents(tx, lineID, components, v.Quantity, price*float64(v.Quantity)); err != nil {
+			return err
+		}
+	}
+
+	// Checks were cut from the old lines, so they no longer add up once the lines change
+	lines, err := snapshotOrder(tx, id)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(before["items"], lines["items"]) {
+		if err = invalidateChecks(tx, id); err != nil {
+			return err
+		}
+	}
+
+	after, err := snapshotOrder(tx, id)
+	if err != nil {
+		return err
+	}
+	if err = recordOrderChange(tx, id, models.OrderActionUpdated, change, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (repo *OrderRepository) DeleteOrder(OrderID int) error {
+// DeleteOrder deletes an order and its items. A non-zero version must match the order's
+// current version, otherwise models.ErrVersionConflict is returned.
+func (repo *OrderRepository) DeleteOrder(OrderID, version int, change models.ChangeInfo) error {
 	tx, err := repo.db.Begin()
 	if err != nil {
 		return err
@@ -275,17 +530,30 @@ func (repo *OrderRepository) DeleteOrder(OrderID int) error {
 		}
 	}()
 
-	var orderExists bool
-	queryCheckOrder := `SELECT EXISTS(SELECT 1 FROM orders WHERE ID = $1)`
-	err = tx.QueryRow(queryCheckOrder, OrderID).Scan(&orderExists)
+	var current int
+	err = tx.QueryRow(`SELECT Version FROM orders WHERE ID = $1 FOR UPDATE`, OrderID).Scan(&current)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.ErrOrderNotFound
+	}
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
+	if version != 0 && version != current {
+		tx.Rollback()
+		return models.ErrVersionConflict
+	}
 
-	if !orderExists {
+	before, err := snapshotOrder(tx, OrderID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if before == nil {
 		tx.Rollback()
-		return fmt.Errorf("order not found", OrderID)
+		return models.ErrOrderNotFound
 	}
 
 	queryDeleteOrderItems := `
@@ -308,15 +576,27 @@ func (repo *OrderRepository) DeleteOrder(OrderID int) error {
 		return fmt.Errorf("failed to delete order: %w", err)
 	}
 
+	err = recordOrderChange(tx, OrderID, models.OrderActionDeleted, change, before, nil)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func (repo *OrderRepository) CloseOrderRepo(id int) error {
+func (repo *OrderRepository) CloseOrderRepo(id int, change models.ChangeInfo) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	var status string
 	queryCheckStatus := `
-		SELECT status FROM orders WHERE ID = $1
+		SELECT status FROM orders WHERE ID = $1 FOR UPDATE
 	`
-	err := repo.db.QueryRow(queryCheckStatus, id).Scan(&status)
+	err = tx.QueryRow(queryCheckStatus, id).Scan(&status)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.ErrOrderNotFound
@@ -328,22 +608,53 @@ func (repo *OrderRepository) CloseOrderRepo(id int) error {
 		return models.ErrOrderClosed
 	}
 
+	// A split order is closed by paying its checks
+	var unpaid bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM order_checks WHERE OrderID = $1 AND Status <> 'paid')`, id).Scan(&unpaid)
+	if err != nil {
+		return err
+	}
+	if unpaid {
+		return models.ErrOrderUnpaidChecks
+	}
+
+	before, err := snapshotOrder(tx, id)
+	if err != nil {
+		return err
+	}
+
 	queryToClose := `
 		UPDATE orders SET status = 'closed' WHERE ID = $1
 	`
-	_, err = repo.db.Exec(queryToClose, id)
+	_, err = tx.Exec(queryToClose, id)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	after, err := snapshotOrder(tx, id)
+	if err != nil {
+		return err
+	}
+	if err = recordOrderChange(tx, id, models.OrderActionClosed, change, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// NeedsPackaging reports whether orders placed through the channel leave the shop and use packaging.
+func NeedsPackaging(channel string) bool {
+	return channel == models.ChannelTakeaway || channel == models.ChannelDelivery || channel == models.ChannelOnline
 }
 
 func getOrderItems(db *sql.DB, orderID int) ([]models.OrderItem, error) {
 	query := `
-	 SELECT ProductID, Quantity
-	 FROM order_items
-	 WHERE OrderID = $1`
+	 SELECT oi.ID, oi.ProductID, oi.Quantity, oi.Seat, COALESCE(oi.Price, mi.Price), oi.Status,
+	 	COALESCE(oi.RecipeVersionID, 0)
+	 FROM order_items oi
+	 JOIN menu_items mi ON mi.ID = oi.ProductID
+	 WHERE oi.OrderID = $1
+	 ORDER BY oi.Seat, oi.ProductID`
 
 	rows, err := db.Query(query, orderID)
 	if err != nil {
@@ -355,12 +666,24 @@ func getOrderItems(db *sql.DB, orderID int) ([]models.OrderItem, error) {
 
 	for rows.Next() {
 		var item models.OrderItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+		if err := rows.Scan(&item.LineID, &item.ProductID, &item.Quantity, &item.Seat, &item.Price, &item.Status, &item.RecipeVersionID); err != nil {
 			return nil, fmt.Errorf("error scanning row in order_items: %w", err)
 		}
 		items = append(items, item)
 	}
+	rows.Close()
 
+	// Bundle lines show what they were made of, and every line the substitutes it was made with
+	for i := range items {
+		items[i].Components, err = getOrderItemComponents(db, items[i].LineID)
+		if err != nil {
+			return nil, err
+		}
+		items[i].Substitutions, err = getOrderItemSubstitutions(db, items[i].LineID)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return items, nil
 }
 
@@ -406,6 +729,36 @@ func (repo *OrderRepository) GetNumberOfItems(startDate, endDate time.Time) (map
 	return result, nil
 }
 
+// GetSalesByCategory returns the quantity of items sold per menu category name.
+// An empty channel includes orders from every channel.
+func (repo *OrderRepository) GetSalesByCategory(channel string) (map[string]int, error) {
+	query := `
+		SELECT COALESCE(c.Name, 'Uncategorized'), SUM(oi.Quantity)
+		FROM order_items oi
+		JOIN orders o ON o.ID = oi.OrderID
+		JOIN menu_items m ON m.ID = oi.ProductID
+		LEFT JOIN menu_categories c ON c.ID = m.CategoryID
+		WHERE $1 = '' OR o.Channel::text = $1
+		GROUP BY c.Name
+	`
+	rows, err := repo.db.Query(query, channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var quantity int
+		if err := rows.Scan(&name, &quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		result[name] = quantity
+	}
+	return result, rows.Err()
+}
+
 func (repo *OrderRepository) GetEarliestDate() string {
 	query := `
 	SELECT min(CreatedAt) FROM orders
@@ -520,3 +873,267 @@ func (repo *OrderRepository) OrderedItemsByMonth(year int) (map[string]interface
 
 	return result, nil
 }
+
+// invalidateChecks removes the checks of orders whose lines changed, so they can be split again.
+// Nothing is removed and models.ErrOrderHasPaidChecks is returned if any of the checks is paid.
+func invalidateChecks(q queryer, orderIDs ...int) error {
+	var paid bool
+	err := q.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM order_checks WHERE OrderID = ANY($1) AND Status = 'paid')
+	`, pq.Array(orderIDs)).Scan(&paid)
+	if err != nil {
+		return err
+	}
+	if paid {
+		return models.ErrOrderHasPaidChecks
+	}
+	if _, err := q.Exec(`DELETE FROM order_checks WHERE OrderID = ANY($1)`, pq.Array(orderIDs)); err != nil {
+		return fmt.Errorf("failed to remove checks: %w", err)
+	}
+	return nil
+}
+
+// CreateChecks stores the checks an open order was split into.
+// The order items are left untouched, so inventory and revenue are not counted twice.
+func (repo *OrderRepository) CreateChecks(orderID int, checks []models.Check, change models.ChangeInfo) ([]models.Check, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var splitCount int
+	queryCheckOrder := `
+		SELECT o.Status, (SELECT COUNT(*) FROM order_checks c WHERE c.OrderID = o.ID)
+		FROM orders o WHERE o.ID = $1
+		FOR UPDATE
+	`
+	err = tx.QueryRow(queryCheckOrder, orderID).Scan(&status, &splitCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrOrderNotFound
+		}
+		return nil, err
+	}
+	if status == "closed" {
+		return nil, models.ErrOrderClosed
+	}
+	if splitCount > 0 {
+		return nil, models.ErrOrderSplit
+	}
+
+	before, err := snapshotOrder(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	queryAddCheck := `
+		INSERT INTO order_checks (OrderID, Label, Amount) VALUES ($1, $2, $3)
+		RETURNING ID, Status
+	`
+	queryAddCheckItem := `
+		INSERT INTO order_check_items (CheckID, ProductID, Seat, Quantity, Price) VALUES
+		($1, $2, $3, $4, $5)
+		ON CONFLICT (CheckID, ProductID, Seat)
+		DO UPDATE SET Quantity = order_check_items.Quantity + EXCLUDED.Quantity
+	`
+	for i := range checks {
+		checks[i].OrderID = orderID
+		err = tx.QueryRow(queryAddCheck, orderID, checks[i].Label, checks[i].Amount).Scan(&checks[i].ID, &checks[i].Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create check: %w", err)
+		}
+		for _, item := range checks[i].Items {
+			_, err = tx.Exec(queryAddCheckItem, checks[i].ID, item.ProductID, item.Seat, item.Quantity, item.Price)
+			if err != nil {
+				return nil, fmt.Errorf("failed to add check item: %w", err)
+			}
+		}
+	}
+
+	after, err := snapshotOrder(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err = recordOrderChange(tx, orderID, models.OrderActionSplit, change, before, after); err != nil {
+		return nil, err
+	}
+
+	return checks, tx.Commit()
+}
+
+// GetChecks retrieves the checks of an order together with their items.
+func (repo *OrderRepository) GetChecks(orderID int) ([]models.Check, error) {
+	query := `
+		SELECT ID, OrderID, Label, Amount, Status, COALESCE(TO_CHAR(PaidAt, 'YYYY-MM-DD"T"HH24:MI:SS'), '')
+		FROM order_checks
+		WHERE OrderID = $1
+		ORDER BY ID
+	`
+	rows, err := repo.db.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for order_checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []models.Check
+	for rows.Next() {
+		var check models.Check
+		if err := rows.Scan(&check.ID, &check.OrderID, &check.Label, &check.Amount, &check.Status, &check.PaidAt); err != nil {
+			return nil, fmt.Errorf("error scanning row in order_checks: %w", err)
+		}
+		checks = append(checks, check)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	queryItems := `
+		SELECT ProductID, Seat, Quantity, Price
+		FROM order_check_items
+		WHERE CheckID = $1
+		ORDER BY Seat, ProductID
+	`
+	for i := range checks {
+		itemRows, err := repo.db.Query(queryItems, checks[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed request for order_check_items: %w", err)
+		}
+		for itemRows.Next() {
+			var item models.OrderItem
+			if err := itemRows.Scan(&item.ProductID, &item.Seat, &item.Quantity, &item.Price); err != nil {
+				itemRows.Close()
+				return nil, fmt.Errorf("error scanning row in order_check_items: %w", err)
+			}
+			checks[i].Items = append(checks[i].Items, item)
+		}
+		itemRows.Close()
+	}
+	return checks, nil
+}
+
+// PayCheck marks a single check as paid. Once every check of the order is paid the order is closed.
+// It reports whether the order was closed.
+func (repo *OrderRepository) PayCheck(orderID, checkID int, change models.ChangeInfo) (bool, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT Status FROM order_checks WHERE ID = $1 AND OrderID = $2 FOR UPDATE`, checkID, orderID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, models.ErrCheckNotFound
+		}
+		return false, err
+	}
+	if status == models.StatusCheckPaid {
+		return false, models.ErrCheckPaid
+	}
+
+	before, err := snapshotOrder(tx, orderID)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`UPDATE order_checks SET Status = 'paid', PaidAt = CURRENT_TIMESTAMP WHERE ID = $1`, checkID)
+	if err != nil {
+		return false, err
+	}
+
+	var openChecks int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM order_checks WHERE OrderID = $1 AND Status = 'open'`, orderID).Scan(&openChecks)
+	if err != nil {
+		return false, err
+	}
+
+	closed := false
+	if openChecks == 0 {
+		res, err := tx.Exec(`UPDATE orders SET Status = 'closed' WHERE ID = $1 AND Status = 'open'`, orderID)
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		closed = n > 0
+	}
+
+	after, err := snapshotOrder(tx, orderID)
+	if err != nil {
+		return false, err
+	}
+	if err = recordOrderChange(tx, orderID, models.OrderActionCheckPaid, change, before, after); err != nil {
+		return false, err
+	}
+
+	return closed, tx.Commit()
+}
+
+// SetItemStatus changes the fulfillment status of a single order line. An empty status
+// advances the line to the next status. Lines of closed orders cannot change, so models.ErrOrderClosed
+// is returned for them.
+func (repo *OrderRepository) SetItemStatus(orderID, lineID int, status string, change models.ChangeInfo) (models.OrderItem, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return models.OrderItem{}, err
+	}
+	defer tx.Rollback()
+
+	var current, orderStatus string
+	queryCheckLine := `
+		SELECT oi.Status, o.Status FROM order_items oi
+		JOIN orders o ON o.ID = oi.OrderID
+		WHERE oi.ID = $1 AND oi.OrderID = $2
+		FOR UPDATE
+	`
+	err = tx.QueryRow(queryCheckLine, lineID, orderID).Scan(&current, &orderStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.OrderItem{}, models.ErrLineNotFound
+		}
+		return models.OrderItem{}, err
+	}
+	if orderStatus == "closed" {
+		return models.OrderItem{}, models.ErrOrderClosed
+	}
+
+	if status == "" {
+		if current == models.StatusItemServed {
+			return models.OrderItem{}, models.ErrLineServed
+		}
+		for i, v := range models.ItemStatuses {
+			if v == current {
+				status = models.ItemStatuses[i+1]
+				break
+			}
+		}
+	}
+
+	before, err := snapshotOrder(tx, orderID)
+	if err != nil {
+		return models.OrderItem{}, err
+	}
+
+	queryUpdateStatus := `
+		UPDATE order_items SET Status = $1, StatusChangedAt = CURRENT_TIMESTAMP
+		WHERE ID = $2
+		RETURNING ID, ProductID, Quantity, Seat, COALESCE(Price, 0), Status
+	`
+	var item models.OrderItem
+	err = tx.QueryRow(queryUpdateStatus, status, lineID).Scan(&item.LineID, &item.ProductID, &item.Quantity, &item.Seat, &item.Price, &item.Status)
+	if err != nil {
+		return models.OrderItem{}, err
+	}
+
+	after, err := snapshotOrder(tx, orderID)
+	if err != nil {
+		return models.OrderItem{}, err
+	}
+	if err = recordOrderChange(tx, orderID, models.OrderActionItemState, change, before, after); err != nil {
+		return models.OrderItem{}, err
+	}
+
+	return item, tx.Commit()
+}
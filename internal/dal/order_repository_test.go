@@ -0,0 +1,84 @@
+package dal
+
+import (
+	"strconv"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestNeedsPackaging(t *testing.T) {
+	tests := []struct {
+		channel string
+		want    bool
+	}{
+		{models.ChannelDineIn, false},
+		{models.ChannelTakeaway, true},
+		{models.ChannelDelivery, true},
+		{models.ChannelOnline, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			if got := NeedsPackaging(tt.channel); got != tt.want {
+				t.Errorf("NeedsPackaging(%q) = %v, want %v", tt.channel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHistoryWithoutChanges(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewOrderRepository(db)
+
+	history, err := repo.GetHistory(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history == nil || len(history) != 0 {
+		t.Errorf("got %#v, want an empty list", history)
+	}
+}
+
+func TestSaveUpdatedOrderRequeuesLines(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewOrderRepository(db)
+
+	more := addTestMenuItem(t, db, 5)
+	same := addTestMenuItem(t, db, 5)
+	fewer := addTestMenuItem(t, db, 5)
+	orderID := addTestOrder(t, db, 0)
+	addTestOrderItem(t, db, orderID, more, 1, models.StatusItemServed)
+	addTestOrderItem(t, db, orderID, same, 2, models.StatusItemServed)
+	addTestOrderItem(t, db, orderID, fewer, 3, models.StatusItemInProgress)
+
+	updated := models.Order{
+		CustomerName: "Test customer",
+		Items: []models.OrderItem{
+			{ProductID: more, Quantity: 2},
+			{ProductID: same, Quantity: 2},
+			{ProductID: fewer, Quantity: 1},
+		},
+	}
+	if err := repo.SaveUpdatedOrder(updated, strconv.Itoa(orderID), models.ChangeInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		productID int
+		quantity  int
+		status    string
+	}{
+		{"quantity went up", more, 2, models.StatusItemQueued},
+		{"quantity stayed", same, 2, models.StatusItemServed},
+		{"quantity went down", fewer, 1, models.StatusItemInProgress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quantity, status := lineStatus(t, db, orderID, tt.productID)
+			if quantity != tt.quantity || status != tt.status {
+				t.Errorf("got %d %s, want %d %s", quantity, status, tt.quantity, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+// PriceRepository reads the price log and manages scheduled price changes.
+type PriceRepository struct {
+	db *sql.DB
+}
+
+// NewPriceRepository creates and returns a new instance of PriceRepository.
+func NewPriceRepository(db *sql.DB) *PriceRepository {
+	return &PriceRepository{db: db}
+}
+
+// GetHistory retrieves price changes between from and to, newest first. A non-zero
+// menuItemID limits the log to one menu item.
+func (repo *PriceRepository) GetHistory(menuItemID int, from, to time.Time) ([]models.PriceChange, error) {
+	query := `
+		SELECT ph.HistoryID, ph.Menu_ItemID, mi.Name, ph.old_price, ph.new_price, ph.ChangedAt,
+			COALESCE(ph.ScheduledChangeID, 0)
+		FROM price_history ph
+		JOIN menu_items mi ON mi.ID = ph.Menu_ItemID
+		WHERE ($1 = 0 OR ph.Menu_ItemID = $1)
+		AND ph.ChangedAt BETWEEN $2 AND $3
+		ORDER BY ph.ChangedAt DESC, ph.HistoryID DESC
+	`
+	rows, err := repo.db.Query(query, menuItemID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for price_history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.PriceChange{}
+	for rows.Next() {
+		var change models.PriceChange
+		if err := rows.Scan(&change.ID, &change.ProductID, &change.Name, &change.OldPrice, &change.NewPrice, &change.ChangedAt, &change.ScheduledChangeID); err != nil {
+			return nil, fmt.Errorf("error scanning row in price_history: %w", err)
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// AddScheduledChange stores a new pending price change and returns its ID.
+func (repo *PriceRepository) AddScheduledChange(change models.ScheduledPriceChange) (int, error) {
+	query := `
+		INSERT INTO scheduled_price_changes (EffectiveAt, Mode, Value, CategoryID, ProductIDs, Note)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, NULLIF($6, ''))
+		RETURNING ID
+	`
+	var productIDs interface{}
+	if len(change.ProductIDs) > 0 {
+		productIDs = pq.Array(change.ProductIDs)
+	}
+	var id int
+	err := repo.db.QueryRow(query, change.EffectiveAt, change.Mode, change.Value, change.CategoryID, productIDs, change.Note).Scan(&id)
+	return id, err
+}
+
+// GetScheduledChanges retrieves scheduled price changes by effective time. An empty status returns all of them.
+func (repo *PriceRepository) GetScheduledChanges(status string) ([]models.ScheduledPriceChange, error) {
+	query := `
+		SELECT ID, EffectiveAt, Mode, Value, COALESCE(CategoryID, 0), ProductIDs, COALESCE(Note, ''),
+			Status, AppliedAt, COALESCE(Error, ''), CreatedAt
+		FROM scheduled_price_changes
+		WHERE $1 = '' OR Status::text = $1
+		ORDER BY EffectiveAt, ID
+	`
+	rows, err := repo.db.Query(query, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for scheduled_price_changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.ScheduledPriceChange{}
+	for rows.Next() {
+		var change models.ScheduledPriceChange
+		var productIDs pq.Int64Array
+		var appliedAt sql.NullTime
+		err := rows.Scan(&change.ID, &change.EffectiveAt, &change.Mode, &change.Value, &change.CategoryID, &productIDs,
+			&change.Note, &change.Status, &appliedAt, &change.Error, &change.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row in scheduled_price_changes: %w", err)
+		}
+		for _, id := range productIDs {
+			change.ProductIDs = append(change.ProductIDs, int(id))
+		}
+		if appliedAt.Valid {
+			change.AppliedAt = &appliedAt.Time
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// CancelScheduledChange cancels a price change that has not been applied yet.
+func (repo *PriceRepository) CancelScheduledChange(id int) error {
+	result, err := repo.db.Exec(`
+		UPDATE scheduled_price_changes SET Status = 'cancelled'
+		WHERE ID = $1 AND Status = 'pending'
+	`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := repo.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM scheduled_price_changes WHERE ID = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrPriceChangeNotFound
+	}
+	return models.ErrPriceChangeDone
+}
+
+// ApplyDueChanges applies every pending price change whose effective time has passed, each in
+// its own transaction. Prices are changed with a regular UPDATE so the price change trigger
+// logs them. Changes that cannot be applied are marked as failed. It returns the IDs of the
+// changes that were applied.
+func (repo *PriceRepository) ApplyDueChanges(now time.Time) ([]int, error) {
+	rows, err := repo.db.Query(`
+		SELECT ID FROM scheduled_price_changes
+		WHERE Status = 'pending' AND EffectiveAt <= $1
+		ORDER BY EffectiveAt, ID
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	var due []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var applied []int
+	for _, id := range due {
+		ok, err := repo.applyChange(id)
+		if err != nil {
+			// Record the failure so the change is not retried forever
+			_, markErr := repo.db.Exec(`
+				UPDATE scheduled_price_changes SET Status = 'failed', Error = $1
+				WHERE ID = $2 AND Status = 'pending'
+			`, err.Error(), id)
+			if markErr != nil {
+				return applied, markErr
+			}
+			continue
+		}
+		if ok {
+			applied = append(applied, id)
+		}
+	}
+	return applied, nil
+}
+
+// applyChange applies one scheduled price change. It returns false if another worker got to it first.
+func (repo *PriceRepository) applyChange(id int) (bool, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var mode string
+	var value float64
+	var categoryID int
+	var productIDs pq.Int64Array
+	err = tx.QueryRow(`
+		SELECT Mode, Value, COALESCE(CategoryID, 0), ProductIDs FROM scheduled_price_changes
+		WHERE ID = $1 AND Status = 'pending'
+		FOR UPDATE SKIP LOCKED
+	`, id).Scan(&mode, &value, &categoryID, &productIDs)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Let the price change trigger link the log entries to this change
+	if _, err := tx.Exec(`SELECT set_config('app.price_change_id', $1, true)`, fmt.Sprint(id)); err != nil {
+		return false, err
+	}
+
+	queryUpdatePrices := `
+		WITH RECURSIVE tree AS (
+			SELECT ID FROM menu_categories WHERE ID = $3::int
+			UNION ALL
+			SELECT c.ID FROM menu_categories c JOIN tree t ON c.ParentID = t.ID
+		)
+		UPDATE menu_items SET Price = CASE $1::price_change_mode
+			WHEN 'percent' THEN ROUND(Price * (1 + $2::numeric / 100), 2)
+			WHEN 'amount' THEN Price + $2::numeric
+			ELSE $2::numeric
+		END
+		WHERE ($3::int = 0 OR CategoryID IN (SELECT ID FROM tree))
+		AND ($4::int[] IS NULL OR ID = ANY($4))
+		AND ArchivedAt IS NULL
+	`
+	var ids interface{}
+	if len(productIDs) > 0 {
+		ids = productIDs
+	}
+	if _, err := tx.Exec(queryUpdatePrices, mode, value, categoryID, ids); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE scheduled_price_changes SET Status = 'applied', AppliedAt = CURRENT_TIMESTAMP, Error = NULL
+		WHERE ID = $1
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,56 @@
+package dal
+
+import (
+	"testing"
+	"time"
+
+	"hot-coffee/models"
+)
+
+func TestApplyDueChanges(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewPriceRepository(db)
+
+	tests := []struct {
+		name  string
+		mode  string
+		value float64
+		price float64
+		want  float64
+	}{
+		{"percent", models.PriceChangePercent, 10, 4.00, 4.40},
+		{"fractional percent", models.PriceChangePercent, 2.5, 4.00, 4.10},
+		{"negative percent", models.PriceChangePercent, -12.5, 8.00, 7.00},
+		{"amount", models.PriceChangeAmount, 0.35, 4.00, 4.35},
+		{"set", models.PriceChangeSet, 3.75, 4.00, 3.75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			itemID := addTestMenuItem(t, db, tt.price)
+			changeID, err := repo.AddScheduledChange(models.ScheduledPriceChange{
+				EffectiveAt: time.Now().Add(-time.Minute),
+				Mode:        tt.mode,
+				Value:       tt.value,
+				ProductIDs:  []int{itemID},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Exec(`DELETE FROM scheduled_price_changes WHERE ID = $1`, changeID) })
+
+			if _, err := repo.ApplyDueChanges(time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			var status string
+			var price float64
+			db.QueryRow(`SELECT Status FROM scheduled_price_changes WHERE ID = $1`, changeID).Scan(&status)
+			db.QueryRow(`SELECT Price FROM menu_items WHERE ID = $1`, itemID).Scan(&price)
+			if status != models.StatusPriceChangeApplied {
+				t.Fatalf("status = %q, want %q", status, models.StatusPriceChangeApplied)
+			}
+			if price != tt.want {
+				t.Errorf("price = %.2f, want %.2f", price, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"hot-coffee/models"
+)
+
+// queryCurrentRecipe finds the recipe version in effect for a menu item.
+const queryCurrentRecipe = `
+	SELECT ID FROM recipe_versions
+	WHERE MenuID = $1 AND EffectiveFrom <= CURRENT_TIMESTAMP
+	ORDER BY EffectiveFrom DESC, Version DESC
+	LIMIT 1
+`
+
+// GetRecipeVersions returns every version of a menu item's recipe, oldest first.
+func (repo *MenuRepository) GetRecipeVersions(menuItemID int) ([]models.RecipeVersion, error) {
+	query := `
+		SELECT rv.ID, rv.MenuID, rv.Version, rv.EffectiveFrom, COALESCE(rv.Note, ''), rv.CreatedAt,
+			rv.ID = COALESCE(mi.RecipeVersionID, 0)
+		FROM recipe_versions rv
+		JOIN menu_items mi ON mi.ID = rv.MenuID
+		WHERE rv.MenuID = $1
+		ORDER BY rv.Version
+	`
+	rows, err := repo.db.Query(query, menuItemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for recipe_versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.RecipeVersion{}
+	for rows.Next() {
+		var v models.RecipeVersion
+		if err := rows.Scan(&v.ID, &v.ProductID, &v.Version, &v.EffectiveFrom, &v.Note, &v.CreatedAt, &v.Current); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range versions {
+		versions[i].Ingredients, err = getRecipeIngredients(repo.db, versions[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// AddRecipeVersion stores a new version of a menu item's recipe and returns its version number.
+// A zero EffectiveFrom makes the version take effect right away.
+func (repo *MenuRepository) AddRecipeVersion(version models.RecipeVersion) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	number, err := addRecipeVersion(tx, version.ProductID, version.Ingredients, version.EffectiveFrom, version.Note)
+	if err != nil {
+		return 0, err
+	}
+	return number, tx.Commit()
+}
+
+// PromoteDueRecipes brings the recipes of menu items in line with the version in effect, which
+// changes when a version scheduled for a later date comes due. It returns the updated menu items.
+func (repo *MenuRepository) PromoteDueRecipes() ([]int, error) {
+	query := `
+		SELECT mi.ID FROM menu_items mi
+		JOIN LATERAL (
+			SELECT ID FROM recipe_versions rv
+			WHERE rv.MenuID = mi.ID AND rv.EffectiveFrom <= CURRENT_TIMESTAMP
+			ORDER BY rv.EffectiveFrom DESC, rv.Version DESC
+			LIMIT 1
+		) cur ON true
+		WHERE mi.RecipeVersionID IS DISTINCT FROM cur.ID
+	`
+	rows, err := repo.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	var due []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, id)
+	}
+	rows.Close()
+
+	var promoted []int
+	for _, id := range due {
+		tx, err := repo.db.Begin()
+		if err != nil {
+			return promoted, err
+		}
+		if err := syncRecipe(tx, id); err != nil {
+			tx.Rollback()
+			return promoted, err
+		}
+		if err := tx.Commit(); err != nil {
+			return promoted, err
+		}
+		promoted = append(promoted, id)
+	}
+	return promoted, nil
+}
+
+// addRecipeVersion inserts the next version of a recipe and mirrors it into menu_item_ingredients
+// if it is already in effect.
+func addRecipeVersion(q queryer, menuItemID int, ingredients []models.MenuItemIngredient, effectiveFrom time.Time, note string) (int, error) {
+	if err := checkRecipeUnits(q, ingredients); err != nil {
+		return 0, err
+	}
+	ingredients, err := withStockUnits(q, ingredients)
+	if err != nil {
+		return 0, err
+	}
+	var from interface{}
+	if !effectiveFrom.IsZero() {
+		from = effectiveFrom
+	}
+
+	queryAddVersion := `
+		INSERT INTO recipe_versions (MenuID, Version, EffectiveFrom, Note)
+		SELECT $1, COALESCE(MAX(Version), 0) + 1, COALESCE($2::timestamptz, CURRENT_TIMESTAMP), NULLIF($3, '')
+		FROM recipe_versions WHERE MenuID = $1
+		RETURNING ID, Version
+	`
+	var id, number int
+	if err := q.QueryRow(queryAddVersion, menuItemID, from, note).Scan(&id, &number); err != nil {
+		return 0, fmt.Errorf("failed to add recipe version: %w", err)
+	}
+
+	queryAddIngredient := `
+		INSERT INTO recipe_version_ingredients (VersionID, IngredientID, Quantity, Unit) VALUES ($1, $2, $3, $4)
+	`
+	for _, ingredient := range ingredients {
+		if _, err := q.Exec(queryAddIngredient, id, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit); err != nil {
+			return 0, fmt.Errorf("failed to add recipe ingredient: %w", err)
+		}
+	}
+	return number, syncRecipe(q, menuItemID)
+}
+
+// syncRecipe mirrors the recipe version in effect into menu_item_ingredients.
+func syncRecipe(q queryer, menuItemID int) error {
+	var versionID int
+	err := q.QueryRow(queryCurrentRecipe, menuItemID).Scan(&versionID)
+	if err == sql.ErrNoRows {
+		return nil // Every version is still in the future
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.Exec(`DELETE FROM menu_item_ingredients WHERE MenuID = $1`, menuItemID); err != nil {
+		return err
+	}
+	queryCopy := `
+		INSERT INTO menu_item_ingredients (MenuID, IngredientID, Quantity, Unit)
+		SELECT $1, IngredientID, Quantity, Unit FROM recipe_version_ingredients WHERE VersionID = $2
+	`
+	if _, err := q.Exec(queryCopy, menuItemID, versionID); err != nil {
+		return err
+	}
+	_, err = q.Exec(`UPDATE menu_items SET RecipeVersionID = $1 WHERE ID = $2`, versionID, menuItemID)
+	return err
+}
+
+// recipeChanged reports whether the ingredients differ from the recipe version in effect.
+func recipeChanged(q queryer, menuItemID int, ingredients []models.MenuItemIngredient) (bool, error) {
+	var versionID sql.NullInt64
+	if err := q.QueryRow(`SELECT RecipeVersionID FROM menu_items WHERE ID = $1`, menuItemID).Scan(&versionID); err != nil {
+		return false, err
+	}
+	if !versionID.Valid {
+		return len(ingredients) > 0, nil
+	}
+
+	current, err := getRecipeIngredients(q, int(versionID.Int64))
+	if err != nil {
+		return false, err
+	}
+	ingredients, err = withStockUnits(q, ingredients)
+	if err != nil {
+		return false, err
+	}
+	wanted := make(map[int]models.MenuItemIngredient)
+	for _, ingredient := range ingredients {
+		wanted[ingredient.IngredientID] = ingredient
+	}
+	if len(wanted) != len(ingredients) || len(wanted) != len(current) {
+		return true, nil
+	}
+	for _, ingredient := range current {
+		if other, ok := wanted[ingredient.IngredientID]; !ok || other.Quantity != ingredient.Quantity || other.Unit != ingredient.Unit {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getRecipeIngredients returns the ingredients of a recipe version. Ingredients whose unit no
+// longer converts into the stock unit have no StockQuantity.
+func getRecipeIngredients(q queryer, versionID int) ([]models.MenuItemIngredient, error) {
+	rows, err := q.Query(`
+		SELECT IngredientID, Quantity, COALESCE(Unit, ''),
+			COALESCE(convert_to_stock_unit(Quantity, Unit, IngredientID), 0)
+		FROM recipe_version_ingredients
+		WHERE VersionID = $1 ORDER BY IngredientID
+	`, versionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingredients := []models.MenuItemIngredient{}
+	for rows.Next() {
+		var ingredient models.MenuItemIngredient
+		if err := rows.Scan(&ingredient.IngredientID, &ingredient.Quantity, &ingredient.Unit, &ingredient.StockQuantity); err != nil {
+			return nil, err
+		}
+		ingredients = append(ingredients, ingredient)
+	}
+	return ingredients, rows.Err()
+}
@@ -0,0 +1,61 @@
+package dal
+
+import (
+	"fmt"
+
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+// rankColumns maps the rankings of recommendations to their column in menu_item_pairs.
+var rankColumns = map[string]string{
+	models.RankByLift:       "Lift",
+	models.RankByConfidence: "Confidence",
+}
+
+// GetRecommendations retrieves the menu items most often bought together with any of productIDs,
+// best first by rank. Each candidate is scored by its strongest pair with one of productIDs.
+// Pairs seen in fewer than minOrders orders, the given items themselves and items that are
+// archived or 86'd are left out.
+func (repo *MenuRepository) GetRecommendations(productIDs []int, rank string, minOrders, limit int) ([]models.Recommendation, error) {
+	column, ok := rankColumns[rank]
+	if !ok {
+		return nil, models.ErrInvalidRanking
+	}
+	query := fmt.Sprintf(`
+		SELECT p.PairedID, mi.Name, mi.Price, MAX(p.PairOrders), MAX(p.Confidence), MAX(p.Lift)
+		FROM menu_item_pairs p
+		JOIN menu_items mi ON mi.ID = p.PairedID
+		WHERE p.ProductID = ANY($1) AND NOT p.PairedID = ANY($1)
+			AND p.PairOrders >= $2
+			AND mi.ArchivedAt IS NULL AND mi.EightySixedAt IS NULL
+		GROUP BY p.PairedID, mi.Name, mi.Price
+		ORDER BY MAX(p.%s) DESC, MAX(p.PairOrders) DESC, p.PairedID
+		LIMIT $3
+	`, column)
+	rows, err := repo.db.Query(query, pq.Array(productIDs), minOrders, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for menu_item_pairs: %w", err)
+	}
+	defer rows.Close()
+
+	recommendations := []models.Recommendation{}
+	for rows.Next() {
+		var recommendation models.Recommendation
+		err := rows.Scan(&recommendation.ProductID, &recommendation.Name, &recommendation.Price,
+			&recommendation.Orders, &recommendation.Confidence, &recommendation.Lift)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row in menu_item_pairs: %w", err)
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+	return recommendations, rows.Err()
+}
+
+// RefreshRecommendations recomputes which menu items are bought together. Readers keep seeing
+// the previous figures until the refresh is done.
+func (repo *MenuRepository) RefreshRecommendations() error {
+	_, err := repo.db.Exec(`REFRESH MATERIALIZED VIEW CONCURRENTLY menu_item_pairs`)
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"time"
 
 	"hot-coffee/models"
 
@@ -12,9 +13,13 @@ import (
 
 // ReportRespository is the interface defining methods for fetching reports like popular menu items and search results for orders and menu items.
 type ReportRespository interface {
-	GetPopularMenuItems() ([]models.PopularItem, error)
+	GetPopularMenuItems(channel string, from, to time.Time) ([]models.PopularItem, error)
+	GetPopularCategories(channel string) ([]models.PopularCategory, error)
+	GetRevenueByItem(channel string) ([]models.ItemRevenue, error)
+	GetIngredientUsage(from, to time.Time) ([]models.IngredientUsage, error)
+	GetInventoryValuation(at time.Time) ([]models.IngredientValuation, error)
 	SearchOrders(searchQuery string) ([]models.SearchOrderResult, error)
-	SearchMenuItems(searchQuery string, minPrice, maxPrice int) ([]models.SearchMenuItem, error)
+	SearchMenuItems(searchQuery string, minPrice, maxPrice int, locale string) ([]models.SearchMenuItem, error)
 }
 
 // ReportRespositoryImpl is the concrete implementation of the ReportRespository interface.
@@ -28,17 +33,30 @@ func NewReportRespository(db *sql.DB) *ReportRespositoryImpl {
 }
 
 // GetPopularMenuItems retrieves the most popular menu items based on the total quantity sold.
-func (repo *ReportRespositoryImpl) GetPopularMenuItems() ([]models.PopularItem, error) {
+// An empty channel includes orders from every channel, and zero times leave the period open.
+func (repo *ReportRespositoryImpl) GetPopularMenuItems(channel string, from, to time.Time) ([]models.PopularItem, error) {
 	// SQL query to get the most popular menu items based on total quantity sold
 	query := `
-        SELECT oi.productid, mi.name, mi.description, SUM(quantity) as total, mi.image
+        SELECT oi.productid, mi.name, mi.description, SUM(oi.quantity) as total, mi.image, COALESCE(c.name, '')
         FROM order_items oi
         JOIN menu_items mi on oi.productid = mi.ID
-        GROUP BY oi.productid, mi.name, mi.description, mi.image
+        JOIN orders o on oi.orderid = o.ID
+        LEFT JOIN menu_categories c on c.ID = mi.categoryid
+        WHERE ($1 = '' OR o.channel::text = $1)
+        AND ($2::timestamptz IS NULL OR o.createdat >= $2)
+        AND ($3::timestamptz IS NULL OR o.createdat <= $3)
+        GROUP BY oi.productid, mi.name, mi.description, mi.image, c.name
         ORDER BY total DESC
     `
+	var fromArg, toArg interface{}
+	if !from.IsZero() {
+		fromArg = from
+	}
+	if !to.IsZero() {
+		toArg = to
+	}
 	// Execute the query
-	rows, err := repo.db.Query(query)
+	rows, err := repo.db.Query(query, channel, fromArg, toArg)
 	if err != nil {
 		return []models.PopularItem{}, fmt.Errorf("error getting popular items %v", err)
 	}
@@ -48,7 +66,7 @@ func (repo *ReportRespositoryImpl) GetPopularMenuItems() ([]models.PopularItem,
 	// Loop through the query results and map them to PopularItem structs
 	for rows.Next() {
 		var item models.PopularItem
-		if err := rows.Scan(&item.ProductID, &item.Name, &item.Description, &item.Quantity, &item.Image); err != nil {
+		if err := rows.Scan(&item.ProductID, &item.Name, &item.Description, &item.Quantity, &item.Image, &item.Category); err != nil {
 			return nil, err // Return error if scanning fails
 		}
 		result = append(result, item) // Append the item to the result
@@ -57,6 +75,183 @@ func (repo *ReportRespositoryImpl) GetPopularMenuItems() ([]models.PopularItem,
 	return result, nil
 }
 
+// GetPopularCategories retrieves the total quantity sold per menu category. Items without
+// a category are reported under category 0.
+func (repo *ReportRespositoryImpl) GetPopularCategories(channel string) ([]models.PopularCategory, error) {
+	query := `
+        SELECT COALESCE(c.ID, 0), COALESCE(c.Name, 'Uncategorized'), SUM(oi.quantity) as total
+        FROM order_items oi
+        JOIN menu_items mi on oi.productid = mi.ID
+        JOIN orders o on oi.orderid = o.ID
+        LEFT JOIN menu_categories c on c.ID = mi.categoryid
+        WHERE $1 = '' OR o.channel::text = $1
+        GROUP BY c.ID, c.Name
+        ORDER BY total DESC
+    `
+	rows, err := repo.db.Query(query, channel)
+	if err != nil {
+		return nil, fmt.Errorf("error getting popular categories %v", err)
+	}
+	defer rows.Close()
+
+	var result []models.PopularCategory
+	for rows.Next() {
+		var category models.PopularCategory
+		if err := rows.Scan(&category.CategoryID, &category.Name, &category.Quantity); err != nil {
+			return nil, err
+		}
+		result = append(result, category)
+	}
+	return result, rows.Err()
+}
+
+// GetRevenueByItem retrieves the revenue earned per menu item. Bundle lines are credited to
+// their components using the revenue split recorded when the order was placed.
+func (repo *ReportRespositoryImpl) GetRevenueByItem(channel string) ([]models.ItemRevenue, error) {
+	query := `
+        WITH sold AS (
+            SELECT oi.productid, oi.quantity, oi.quantity * COALESCE(oi.price, mi.price) AS revenue, 0 AS bundle_revenue
+            FROM order_items oi
+            JOIN orders o on oi.orderid = o.ID
+            JOIN menu_items mi on oi.productid = mi.ID
+            WHERE ($1 = '' OR o.channel::text = $1)
+            AND NOT EXISTS (SELECT 1 FROM order_item_components c WHERE c.orderitemid = oi.ID)
+            UNION ALL
+            SELECT c.productid, c.quantity, c.revenue, c.revenue
+            FROM order_item_components c
+            JOIN order_items oi on oi.ID = c.orderitemid
+            JOIN orders o on oi.orderid = o.ID
+            WHERE $1 = '' OR o.channel::text = $1
+        )
+        SELECT s.productid, mi.name, SUM(s.quantity), SUM(s.revenue), SUM(s.bundle_revenue)
+        FROM sold s
+        JOIN menu_items mi on mi.ID = s.productid
+        GROUP BY s.productid, mi.name
+        ORDER BY SUM(s.revenue) DESC
+    `
+	rows, err := repo.db.Query(query, channel)
+	if err != nil {
+		return nil, fmt.Errorf("error getting revenue by item %v", err)
+	}
+	defer rows.Close()
+
+	result := []models.ItemRevenue{}
+	for rows.Next() {
+		var item models.ItemRevenue
+		if err := rows.Scan(&item.ProductID, &item.Name, &item.Quantity, &item.Revenue, &item.BundleRevenue); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+// GetIngredientUsage retrieves the ingredients consumed by orders placed between from and to.
+// Each line uses the recipe version it was made with, bundle lines use their components and
+// substituted ingredients count as the substitute. A zero from or to leaves that side of the range open.
+func (repo *ReportRespositoryImpl) GetIngredientUsage(from, to time.Time) ([]models.IngredientUsage, error) {
+	query := `
+        WITH made AS (
+            -- Lines without a recorded recipe version are counted with the item's current recipe
+            SELECT COALESCE(oi.recipeversionid, mi.recipeversionid) AS recipeversionid, oi.quantity
+            FROM order_items oi
+            JOIN orders o on oi.orderid = o.ID
+            JOIN menu_items mi on mi.ID = oi.productid
+            WHERE ($1::timestamptz IS NULL OR o.createdat >= $1)
+            AND ($2::timestamptz IS NULL OR o.createdat <= $2)
+            AND NOT EXISTS (SELECT 1 FROM order_item_components c WHERE c.orderitemid = oi.ID)
+            UNION ALL
+            SELECT COALESCE(c.recipeversionid, mi.recipeversionid), c.quantity
+            FROM order_item_components c
+            JOIN order_items oi on oi.ID = c.orderitemid
+            JOIN orders o on oi.orderid = o.ID
+            JOIN menu_items mi on mi.ID = c.productid
+            WHERE ($1::timestamptz IS NULL OR o.createdat >= $1)
+            AND ($2::timestamptz IS NULL OR o.createdat <= $2)
+        ),
+        used AS (
+            -- Rounded up like the inventory deduction of each order line
+            SELECT rvi.ingredientid, round_to_unit(m.quantity * convert_to_stock_unit(rvi.quantity, rvi.unit, rvi.ingredientid), ri.unit) AS quantity
+            FROM made m
+            JOIN recipe_version_ingredients rvi on rvi.versionid = m.recipeversionid
+            JOIN inventory ri on ri.ingredientid = rvi.ingredientid
+            UNION ALL
+            -- Substituted ingredients were used instead of the ones in the recipe
+            SELECT x.ingredientid, x.quantity
+            FROM order_item_substitutions s
+            JOIN order_items oi on oi.ID = s.orderitemid
+            JOIN orders o on oi.orderid = o.ID
+            CROSS JOIN LATERAL (VALUES (s.ingredientid, -s.replacedquantity), (s.substituteid, s.quantity)) AS x(ingredientid, quantity)
+            WHERE ($1::timestamptz IS NULL OR o.createdat >= $1)
+            AND ($2::timestamptz IS NULL OR o.createdat <= $2)
+        )
+        SELECT inv.ingredientid, inv.name, inv.unit, SUM(u.quantity),
+            ROUND(SUM(u.quantity) * inv.unitcost, 2)
+        FROM used u
+        JOIN inventory inv on inv.ingredientid = u.ingredientid
+        GROUP BY inv.ingredientid, inv.name, inv.unit, inv.unitcost
+        HAVING SUM(u.quantity) <> 0
+        ORDER BY inv.name
+    `
+	var fromArg, toArg interface{}
+	if !from.IsZero() {
+		fromArg = from
+	}
+	if !to.IsZero() {
+		toArg = to
+	}
+	rows, err := repo.db.Query(query, fromArg, toArg)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ingredient usage %v", err)
+	}
+	defer rows.Close()
+
+	result := []models.IngredientUsage{}
+	for rows.Next() {
+		var usage models.IngredientUsage
+		if err := rows.Scan(&usage.IngredientID, &usage.Name, &usage.Unit, &usage.Quantity, &usage.Cost); err != nil {
+			return nil, err
+		}
+		result = append(result, usage)
+	}
+	return result, rows.Err()
+}
+
+// GetInventoryValuation values the stock of every ingredient as it was at the given time. The
+// quantity adds up the inventory transactions until then and is valued at the unit cost
+// recorded with the last of them.
+func (repo *ReportRespositoryImpl) GetInventoryValuation(at time.Time) ([]models.IngredientValuation, error) {
+	query := `
+        SELECT inv.ingredientid, inv.name, inv.unit, s.quantity, s.unitcost,
+            ROUND(s.quantity * s.unitcost, 2)
+        FROM inventory inv
+        CROSS JOIN LATERAL (
+            SELECT SUM(t.quantity_change) AS quantity,
+                (ARRAY_AGG(t.unit_cost ORDER BY t.created_at DESC, t.transactionid DESC))[1] AS unitcost
+            FROM inventory_transactions t
+            WHERE t.ingredientid = inv.ingredientid AND t.created_at <= $1
+        ) s
+        WHERE s.quantity IS NOT NULL
+        ORDER BY inv.name
+    `
+	rows, err := repo.db.Query(query, at)
+	if err != nil {
+		return nil, fmt.Errorf("error getting inventory valuation %v", err)
+	}
+	defer rows.Close()
+
+	result := []models.IngredientValuation{}
+	for rows.Next() {
+		var valuation models.IngredientValuation
+		if err := rows.Scan(&valuation.IngredientID, &valuation.Name, &valuation.Unit, &valuation.Quantity,
+			&valuation.UnitCost, &valuation.Value); err != nil {
+			return nil, err
+		}
+		result = append(result, valuation)
+	}
+	return result, rows.Err()
+}
+
 // SearchOrders performs a full-text search on orders based on the customer name and menu items.
 func (repo *ReportRespositoryImpl) SearchOrders(searchQuery string) ([]models.SearchOrderResult, error) {
 	// SQL query to search orders based on customer name and menu items, using full-text search for relevance
@@ -99,15 +294,34 @@ func (repo *ReportRespositoryImpl) SearchOrders(searchQuery string) ([]models.Se
 }
 
 // SearchMenuItems performs a full-text search on menu items based on the name and description, and supports filtering by price.
-func (repo *ReportRespositoryImpl) SearchMenuItems(searchQuery string, minPrice, maxPrice int) ([]models.SearchMenuItem, error) {
-	// SQL query to search menu items based on name and description, using full-text search for relevance
-	query := `
+// Outside the default locale, translated items are searched in their translation with the text search
+// configuration of the locale and the others in their default text. Archived items are left out.
+func (repo *ReportRespositoryImpl) SearchMenuItems(searchQuery string, minPrice, maxPrice int, locale string) ([]models.SearchMenuItem, error) {
+	// SQL query to search menu items based on name and description, using full-text search for relevance.
+	// The configurations are written into the query so the expressions match the search indexes.
+	query := fmt.Sprintf(`
 		SELECT 
 			id, name, description, price,
-			ts_rank(to_tsvector(name || ' ' || COALESCE(description, '')), websearch_to_tsquery($1)) as relevance
+			ts_rank(to_tsvector('%[1]s', name || ' ' || COALESCE(description, '')), websearch_to_tsquery('%[1]s', $1)) as relevance
 		FROM menu_items
-		WHERE to_tsvector(name || ' ' || COALESCE(description, '')) @@ websearch_to_tsquery($1)
-	`
+		WHERE ArchivedAt IS NULL
+		AND to_tsvector('%[1]s', name || ' ' || COALESCE(description, '')) @@ websearch_to_tsquery('%[1]s', $1)
+	`, models.SearchConfigs[models.DefaultLocale])
+	if config, ok := models.SearchConfigs[locale]; ok && locale != models.DefaultLocale {
+		query = fmt.Sprintf(`
+		SELECT
+			t.MenuID AS id, t.Name AS name, t.Description AS description, mi.Price AS price,
+			ts_rank(to_tsvector('%[1]s', t.Name || ' ' || t.Description), websearch_to_tsquery('%[1]s', $1)) AS relevance
+		FROM menu_item_translations t
+		JOIN menu_items mi ON mi.ID = t.MenuID
+		WHERE t.Locale = '%[2]s' AND mi.ArchivedAt IS NULL
+		AND to_tsvector('%[1]s', t.Name || ' ' || t.Description) @@ websearch_to_tsquery('%[1]s', $1)
+		UNION ALL
+		%[3]s
+		AND NOT EXISTS (SELECT 1 FROM menu_item_translations t WHERE t.MenuID = menu_items.ID AND t.Locale = '%[2]s')
+		`, config, locale, query)
+	}
+	query = "SELECT id, name, description, price, relevance FROM (" + query + ") found WHERE TRUE"
 	// Parameters for the query
 	args := []interface{}{searchQuery}
 	argIndex := 2
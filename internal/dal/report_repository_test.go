@@ -0,0 +1,36 @@
+package dal
+
+import (
+	"testing"
+)
+
+func TestSearchMenuItemsSkipsArchived(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewReportRespository(db)
+
+	onMenu := addTestMenuItem(t, db, 4)
+	archived := addTestMenuItem(t, db, 4)
+	for _, id := range []int{onMenu, archived} {
+		if _, err := db.Exec(`UPDATE menu_items SET Name = 'Quokkaccino' WHERE ID = $1`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE menu_items SET ArchivedAt = CURRENT_TIMESTAMP WHERE ID = $1`, archived); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO menu_item_translations (MenuID, Locale, Name, Description) VALUES ($1, 'de', 'Quokkaccino', 'Test')`, archived); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, locale := range []string{"en", "de"} {
+		t.Run(locale, func(t *testing.T) {
+			found, err := repo.SearchMenuItems("quokkaccino", -1, -1, locale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != 1 || found[0].ID != onMenu {
+				t.Errorf("found %+v, want only item %d", found, onMenu)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+// GetSubstitutes retrieves the substitutes of an ingredient in the order they are tried.
+func (repo *InventoryRepository) GetSubstitutes(ingredientID int) ([]models.IngredientSubstitute, error) {
+	rows, err := repo.db.Query(`
+		SELECT s.SubstituteID, inv.Name, inv.Unit, s.Ratio
+		FROM ingredient_substitutions s
+		JOIN inventory inv ON inv.IngredientID = s.SubstituteID
+		WHERE s.IngredientID = $1
+		ORDER BY s.Priority, s.SubstituteID
+	`, ingredientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for ingredient_substitutions: %w", err)
+	}
+	defer rows.Close()
+
+	substitutes := []models.IngredientSubstitute{}
+	for rows.Next() {
+		var substitute models.IngredientSubstitute
+		if err := rows.Scan(&substitute.SubstituteID, &substitute.Name, &substitute.Unit, &substitute.Ratio); err != nil {
+			return nil, fmt.Errorf("error scanning row in ingredient_substitutions: %w", err)
+		}
+		substitutes = append(substitutes, substitute)
+	}
+	return substitutes, rows.Err()
+}
+
+// GetAllSubstitutes retrieves the substitutes of every ingredient that has any, keyed by ingredient ID.
+func (repo *InventoryRepository) GetAllSubstitutes() (map[int][]models.IngredientSubstitute, error) {
+	rows, err := repo.db.Query(`
+		SELECT IngredientID, SubstituteID, Ratio FROM ingredient_substitutions
+		ORDER BY IngredientID, Priority, SubstituteID
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for ingredient_substitutions: %w", err)
+	}
+	defer rows.Close()
+
+	substitutes := make(map[int][]models.IngredientSubstitute)
+	for rows.Next() {
+		var ingredientID int
+		var substitute models.IngredientSubstitute
+		if err := rows.Scan(&ingredientID, &substitute.SubstituteID, &substitute.Ratio); err != nil {
+			return nil, fmt.Errorf("error scanning row in ingredient_substitutions: %w", err)
+		}
+		substitutes[ingredientID] = append(substitutes[ingredientID], substitute)
+	}
+	return substitutes, rows.Err()
+}
+
+// SaveSubstitutes replaces the substitutes of an ingredient. They are tried in the order given.
+func (repo *InventoryRepository) SaveSubstitutes(ingredientID int, substitutes []models.IngredientSubstitute) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM ingredient_substitutions WHERE IngredientID = $1`, ingredientID); err != nil {
+		return err
+	}
+	for i, substitute := range substitutes {
+		_, err = tx.Exec(`
+			INSERT INTO ingredient_substitutions (IngredientID, SubstituteID, Ratio, Priority)
+			VALUES ($1, $2, $3, $4)
+		`, ingredientID, substitute.SubstituteID, substitute.Ratio, i+1)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "23503" || pqErr.Code == "23505" || pqErr.Code == "23514") {
+				return models.ErrInvalidSubstitute
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// findSubstitute picks the first substitute, in priority order, with enough stock to replace
+// quantity of an ingredient in a menu item. found is false when the item opts out of substitutes
+// or none has enough stock. available is the stock of the substitute before it is used. The
+// substitute's quantity is rounded up to the decimals kept for its unit.
+func findSubstitute(q queryer, menuItemID, ingredientID int, quantity float64) (substitution models.Substitution, available float64, found bool, err error) {
+	err = q.QueryRow(`
+		SELECT s.SubstituteID, inv.Name, round_to_unit($3 * s.Ratio, inv.Unit), inv.Quantity
+		FROM ingredient_substitutions s
+		JOIN inventory inv ON inv.IngredientID = s.SubstituteID
+		WHERE s.IngredientID = $2
+		AND NOT EXISTS (SELECT 1 FROM menu_items mi WHERE mi.ID = $1 AND mi.NoSubstitutes)
+		AND inv.Quantity >= round_to_unit($3 * s.Ratio, inv.Unit)
+		ORDER BY s.Priority, s.SubstituteID
+		LIMIT 1
+	`, menuItemID, ingredientID, quantity).Scan(&substitution.SubstituteID, &substitution.Substitute, &substitution.Quantity, &available)
+	if err == sql.ErrNoRows {
+		return models.Substitution{}, 0, false, nil
+	}
+	if err != nil {
+		return models.Substitution{}, 0, false, err
+	}
+	substitution.IngredientID = ingredientID
+	substitution.ReplacedQuantity = quantity
+	return substitution, available, true, nil
+}
+
+// addOrderItemSubstitution records a substitution on an order line. Repeated substitutions of the
+// same ingredient on the line add up.
+func addOrderItemSubstitution(q queryer, lineID int, substitution models.Substitution) error {
+	_, err := q.Exec(`
+		INSERT INTO order_item_substitutions (OrderItemID, IngredientID, SubstituteID, ReplacedQuantity, Quantity)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (OrderItemID, IngredientID, SubstituteID)
+		DO UPDATE SET ReplacedQuantity = order_item_substitutions.ReplacedQuantity + EXCLUDED.ReplacedQuantity,
+			Quantity = order_item_substitutions.Quantity + EXCLUDED.Quantity
+	`, lineID, substitution.IngredientID, substitution.SubstituteID, substitution.ReplacedQuantity, substitution.Quantity)
+	return err
+}
+
+// getOrderItemSubstitutions returns the substitutions recorded for an order line.
+func getOrderItemSubstitutions(q queryer, lineID int) ([]models.Substitution, error) {
+	rows, err := q.Query(`
+		SELECT s.IngredientID, orig.Name, s.SubstituteID, sub.Name, s.ReplacedQuantity, s.Quantity
+		FROM order_item_substitutions s
+		JOIN inventory orig ON orig.IngredientID = s.IngredientID
+		JOIN inventory sub ON sub.IngredientID = s.SubstituteID
+		WHERE s.OrderItemID = $1
+		ORDER BY s.IngredientID, s.SubstituteID
+	`, lineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var substitutions []models.Substitution
+	for rows.Next() {
+		var substitution models.Substitution
+		err := rows.Scan(&substitution.IngredientID, &substitution.Ingredient, &substitution.SubstituteID,
+			&substitution.Substitute, &substitution.ReplacedQuantity, &substitution.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		substitutions = append(substitutions, substitution)
+	}
+	return substitutions, rows.Err()
+}
@@ -0,0 +1,101 @@
+package dal
+
+import (
+	"database/sql"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+// addTestIngredient adds an inventory item counted in millilitres and removes it when the test ends.
+func addTestIngredient(t *testing.T, db *sql.DB, name string, quantity float64) int {
+	t.Helper()
+	var id int
+	err := db.QueryRow(`INSERT INTO inventory (Name, Quantity, Unit) VALUES ($1, $2, 'ml') RETURNING IngredientID`, name, quantity).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM inventory WHERE IngredientID = $1`, id) })
+	return id
+}
+
+func TestOrderUsesSubstitutes(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewOrderRepository(db)
+
+	milk := addTestIngredient(t, db, "Test milk", 0)
+	oat := addTestIngredient(t, db, "Test oat milk", 50)
+	soy := addTestIngredient(t, db, "Test soy milk", 500)
+	latte := addTestMenuItem(t, db, 5)
+	strict := addTestMenuItem(t, db, 5)
+	setup := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO ingredient_substitutions (IngredientID, SubstituteID, Ratio, Priority) VALUES ($1, $2, 1, 1)`, []interface{}{milk, oat}},
+		{`INSERT INTO ingredient_substitutions (IngredientID, SubstituteID, Ratio, Priority) VALUES ($1, $2, 1, 2)`, []interface{}{milk, soy}},
+		{`INSERT INTO menu_item_ingredients (MenuID, IngredientID, Quantity, Unit) VALUES ($1, $2, 200, 'ml')`, []interface{}{latte, milk}},
+		{`INSERT INTO menu_item_ingredients (MenuID, IngredientID, Quantity, Unit) VALUES ($1, $2, 200, 'ml')`, []interface{}{strict, milk}},
+		{`UPDATE menu_items SET NoSubstitutes = true WHERE ID = $1`, []interface{}{strict}},
+	}
+	for _, step := range setup {
+		if _, err := db.Exec(step.query, step.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("falls through to the second substitute", func(t *testing.T) {
+		substitution, available, found, err := findSubstitute(db, latte, milk, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || substitution.SubstituteID != soy || substitution.Quantity != 200 || available != 500 {
+			t.Errorf("got %+v, %v available, found %v, want soy milk for 200 out of 500", substitution, available, found)
+		}
+	})
+
+	t.Run("menu item without substitutes", func(t *testing.T) {
+		if _, _, found, err := findSubstitute(db, strict, milk, 200); err != nil || found {
+			t.Errorf("got found %v, %v, want no substitute", found, err)
+		}
+	})
+
+	t.Run("order records the substitution", func(t *testing.T) {
+		order := models.Order{CustomerName: "Test customer", Channel: models.ChannelDineIn, Items: []models.OrderItem{{ProductID: latte, Quantity: 1}}}
+		info, _, err := repo.Add(order, models.ChangeInfo{})
+		if info.OrderID != 0 {
+			t.Cleanup(func() { db.Exec(`DELETE FROM orders WHERE ID = $1`, info.OrderID) })
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(info.Substitutions) != 1 || info.Substitutions[0].SubstituteID != soy {
+			t.Fatalf("got substitutions %+v, want soy milk", info.Substitutions)
+		}
+
+		var lineID int
+		if err := db.QueryRow(`SELECT ID FROM order_items WHERE OrderID = $1`, info.OrderID).Scan(&lineID); err != nil {
+			t.Fatal(err)
+		}
+		// Another substitution of the same ingredient adds up on the line
+		if err := addOrderItemSubstitution(db, lineID, info.Substitutions[0]); err != nil {
+			t.Fatal(err)
+		}
+		recorded, err := getOrderItemSubstitutions(db, lineID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recorded) != 1 || recorded[0].IngredientID != milk || recorded[0].SubstituteID != soy ||
+			recorded[0].ReplacedQuantity != 400 || recorded[0].Quantity != 400 {
+			t.Errorf("got %+v, want milk replaced by 400 of soy milk", recorded)
+		}
+
+		var left float64
+		if err := db.QueryRow(`SELECT Quantity FROM inventory WHERE IngredientID = $1`, soy).Scan(&left); err != nil {
+			t.Fatal(err)
+		}
+		if left != 300 {
+			t.Errorf("soy milk left %v, want 300", left)
+		}
+	})
+}
@@ -0,0 +1,344 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+// TableRepository is responsible for dining tables and the orders seated at them.
+type TableRepository struct {
+	db *sql.DB
+}
+
+// NewTableRepository creates and returns a new instance of TableRepository.
+func NewTableRepository(db *sql.DB) *TableRepository {
+	return &TableRepository{db: db}
+}
+
+// GetAll retrieves every table with its open orders and their running total.
+func (repo *TableRepository) GetAll() ([]models.Table, error) {
+	query := `
+		SELECT
+			t.ID,
+			t.Name,
+			t.Seats,
+			COALESCE(ARRAY_AGG(o.ID ORDER BY o.ID) FILTER (WHERE o.ID IS NOT NULL), '{}') AS open_orders,
+			COALESCE(SUM(totals.total), 0) AS running_total
+		FROM dining_tables t
+		LEFT JOIN orders o ON o.TableID = t.ID AND o.Status = 'open'
+		LEFT JOIN (
+			SELECT oi.OrderID, SUM(oi.Quantity * COALESCE(oi.Price, mi.Price)) AS total
+			FROM order_items oi
+			JOIN menu_items mi ON mi.ID = oi.ProductID
+			GROUP BY oi.OrderID
+		) totals ON totals.OrderID = o.ID
+		GROUP BY t.ID, t.Name, t.Seats
+		ORDER BY t.ID
+	`
+	rows, err := repo.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []models.Table{}
+	for rows.Next() {
+		var table models.Table
+		var openOrders pq.Int64Array
+		if err := rows.Scan(&table.ID, &table.Name, &table.Seats, &openOrders, &table.RunningTotal); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		table.OpenOrders = make([]int, 0, len(openOrders))
+		for _, id := range openOrders {
+			table.OpenOrders = append(table.OpenOrders, int(id))
+		}
+		table.Occupied = len(table.OpenOrders) > 0
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// Exists checks whether a table with the given ID exists.
+func (repo *TableRepository) Exists(id int) bool {
+	var exists bool
+	err := repo.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM dining_tables WHERE ID = $1)`, id).Scan(&exists)
+	return err == nil && exists
+}
+
+// Add inserts a new table.
+func (repo *TableRepository) Add(table models.Table) error {
+	query := `
+		INSERT INTO dining_tables (Name, Seats) VALUES ($1, $2)
+	`
+	_, err := repo.db.Exec(query, table.Name, table.Seats)
+	return err
+}
+
+// AssignOrder seats an open dine-in order at a table. Moving an order between tables is the same operation.
+func (repo *TableRepository) AssignOrder(orderID, tableID int, change models.ChangeInfo) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status, channel string
+	err = tx.QueryRow(`SELECT Status, Channel FROM orders WHERE ID = $1 FOR UPDATE`, orderID).Scan(&status, &channel)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrOrderNotFound
+		}
+		return err
+	}
+	if status == "closed" {
+		return models.ErrOrderClosed
+	}
+	if channel != models.ChannelDineIn {
+		return models.ErrOrderNotDineIn
+	}
+
+	before, err := snapshotOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE orders SET TableID = $1 WHERE ID = $2`, tableID, orderID); err != nil {
+		return err
+	}
+	after, err := snapshotOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if err = recordOrderChange(tx, orderID, models.OrderActionSeated, change, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Merge combines the open orders of both tables into a single check seated at targetID.
+// The oldest open order of the target table becomes the check; if the target table is empty
+// the oldest order of the source table is moved over instead. The lines of the other orders
+// are moved onto the check and those orders are closed empty, with the check they went into
+// in their notes. Only dine-in orders without paid checks are merged, and a line can only be
+// folded into a line of the same item and seat if both have the same price. A folded line
+// keeps its status only if both lines had it and is queued otherwise. It returns the ID of
+// the check.
+func (repo *TableRepository) Merge(targetID, sourceID int, change models.ChangeInfo) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT ID, Channel FROM orders
+		WHERE TableID IN ($1, $2) AND Status = 'open'
+		ORDER BY TableID = $1 DESC, ID
+		FOR UPDATE
+	`, targetID, sourceID)
+	if err != nil {
+		return 0, err
+	}
+	var orderIDs []int
+	for rows.Next() {
+		var id int
+		var channel string
+		if err := rows.Scan(&id, &channel); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if channel != models.ChannelDineIn {
+			rows.Close()
+			return 0, models.ErrOrderNotDineIn
+		}
+		orderIDs = append(orderIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(orderIDs) == 0 {
+		return 0, models.ErrTableNoOrders
+	}
+
+	checkID, merged := orderIDs[0], orderIDs[1:]
+
+	checkBefore, err := snapshotOrder(tx, checkID)
+	if err != nil {
+		return 0, err
+	}
+	// The check gets new lines, so none of the orders keeps the checks it was split into
+	if len(merged) > 0 {
+		if err := invalidateChecks(tx, orderIDs...); err != nil {
+			return 0, err
+		}
+	}
+
+	queryPriceConflict := `
+		SELECT EXISTS (
+			SELECT 1 FROM order_items src
+			JOIN order_items dst ON dst.OrderID = $1 AND dst.ProductID = src.ProductID AND dst.Seat = src.Seat
+			WHERE src.OrderID = $2 AND src.Price IS DISTINCT FROM dst.Price
+		)
+	`
+	queryMoveItems := `
+		INSERT INTO order_items (OrderID, ProductID, Seat, Quantity, Price, Status, RecipeVersionID)
+		SELECT $1, ProductID, Seat, Quantity, Price, Status, RecipeVersionID FROM order_items WHERE OrderID = $2
+		ON CONFLICT (OrderID, ProductID, Seat)
+		DO UPDATE SET Quantity = order_items.Quantity + EXCLUDED.Quantity,
+			Status = CASE WHEN order_items.Status = EXCLUDED.Status THEN order_items.Status ELSE 'queued' END,
+			StatusChangedAt = CASE WHEN order_items.Status IN (EXCLUDED.Status, 'queued') THEN order_items.StatusChangedAt ELSE CURRENT_TIMESTAMP END
+	`
+	queryMoveComponents := `
+		INSERT INTO order_item_components (OrderItemID, ProductID, Quantity, Revenue, RecipeVersionID)
+		SELECT dst.ID, c.ProductID, c.Quantity, c.Revenue, c.RecipeVersionID
+		FROM order_item_components c
+		JOIN order_items src ON src.ID = c.OrderItemID AND src.OrderID = $2
+		JOIN order_items dst ON dst.OrderID = $1 AND dst.ProductID = src.ProductID AND dst.Seat = src.Seat
+		ON CONFLICT (OrderItemID, ProductID)
+		DO UPDATE SET Quantity = order_item_components.Quantity + EXCLUDED.Quantity,
+			Revenue = order_item_components.Revenue + EXCLUDED.Revenue
+	`
+	queryMoveSubstitutions := `
+		INSERT INTO order_item_substitutions (OrderItemID, IngredientID, SubstituteID, ReplacedQuantity, Quantity)
+		SELECT dst.ID, s.IngredientID, s.SubstituteID, s.ReplacedQuantity, s.Quantity
+		FROM order_item_substitutions s
+		JOIN order_items src ON src.ID = s.OrderItemID AND src.OrderID = $2
+		JOIN order_items dst ON dst.OrderID = $1 AND dst.ProductID = src.ProductID AND dst.Seat = src.Seat
+		ON CONFLICT (OrderItemID, IngredientID, SubstituteID)
+		DO UPDATE SET ReplacedQuantity = order_item_substitutions.ReplacedQuantity + EXCLUDED.ReplacedQuantity,
+			Quantity = order_item_substitutions.Quantity + EXCLUDED.Quantity
+	`
+	// The merged order is kept for its history, closed and without lines so nothing is counted twice
+	queryCloseMerged := `
+		UPDATE orders
+		SET Status = 'closed',
+			Notes = COALESCE(Notes, '{}'::jsonb) || jsonb_build_object('merged_into', $1::int)
+		WHERE ID = $2
+	`
+	for _, id := range merged {
+		var conflict bool
+		if err := tx.QueryRow(queryPriceConflict, checkID, id).Scan(&conflict); err != nil {
+			return 0, err
+		}
+		if conflict {
+			return 0, models.ErrMergePriceConflict
+		}
+
+		before, err := snapshotOrder(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(queryMoveItems, checkID, id); err != nil {
+			return 0, fmt.Errorf("failed to move items of order %d: %w", id, err)
+		}
+		if _, err := tx.Exec(queryMoveComponents, checkID, id); err != nil {
+			return 0, fmt.Errorf("failed to move bundle components of order %d: %w", id, err)
+		}
+		if _, err := tx.Exec(queryMoveSubstitutions, checkID, id); err != nil {
+			return 0, fmt.Errorf("failed to move substitutions of order %d: %w", id, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM order_items WHERE OrderID = $1`, id); err != nil {
+			return 0, fmt.Errorf("failed to remove lines of merged order %d: %w", id, err)
+		}
+		if _, err := tx.Exec(queryCloseMerged, checkID, id); err != nil {
+			return 0, fmt.Errorf("failed to close merged order %d: %w", id, err)
+		}
+		after, err := snapshotOrder(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		merge := change
+		merge.Reason = strings.TrimSpace(fmt.Sprintf("merged into order %d. %s", checkID, change.Reason))
+		if err := recordOrderChange(tx, id, models.OrderActionMerged, merge, before, after); err != nil {
+			return 0, err
+		}
+	}
+
+	// Keep track of the orders that were folded into the check
+	queryUpdateCheck := `
+		UPDATE orders
+		SET TableID = $1,
+			Notes = COALESCE(Notes, '{}'::jsonb) || jsonb_build_object(
+				'merged_orders', COALESCE(Notes->'merged_orders', '[]'::jsonb) || to_jsonb($3::int[])
+			)
+		WHERE ID = $2
+	`
+	if _, err := tx.Exec(queryUpdateCheck, targetID, checkID, pq.Array(merged)); err != nil {
+		return 0, err
+	}
+
+	checkAfter, err := snapshotOrder(tx, checkID)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordOrderChange(tx, checkID, models.OrderActionMerged, change, checkBefore, checkAfter); err != nil {
+		return 0, err
+	}
+
+	return checkID, tx.Commit()
+}
+
+// CloseTable closes every open order seated at the table in one transaction and returns their IDs.
+func (repo *TableRepository) CloseTable(tableID int, change models.ChangeInfo) ([]int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// A split order is closed by paying its checks
+	var unpaid bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM order_checks c JOIN orders o ON o.ID = c.OrderID
+			WHERE o.TableID = $1 AND o.Status = 'open' AND c.Status <> 'paid'
+		)
+	`, tableID).Scan(&unpaid)
+	if err != nil {
+		return nil, err
+	}
+	if unpaid {
+		return nil, models.ErrOrderUnpaidChecks
+	}
+
+	rows, err := tx.Query(`
+		UPDATE orders SET Status = 'closed'
+		WHERE TableID = $1 AND Status = 'open'
+		RETURNING ID
+	`, tableID)
+	if err != nil {
+		return nil, err
+	}
+
+	var closed []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		closed = append(closed, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(closed) == 0 {
+		return nil, models.ErrTableNoOrders
+	}
+
+	// The update above only changed the status, so the history entry is known up front
+	for _, id := range closed {
+		before := map[string]interface{}{"status": "open"}
+		after := map[string]interface{}{"status": "closed"}
+		if err := recordOrderChange(tx, id, models.OrderActionClosed, change, before, after); err != nil {
+			return nil, err
+		}
+	}
+	return closed, tx.Commit()
+}
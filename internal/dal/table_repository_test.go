@@ -0,0 +1,59 @@
+package dal
+
+import (
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestMergeRequeuesFoldedLines(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewTableRepository(db)
+
+	var target, source int
+	for _, table := range []struct {
+		name string
+		id   *int
+	}{{"Test target", &target}, {"Test source", &source}} {
+		if err := db.QueryRow(`INSERT INTO dining_tables (Name, Seats) VALUES ($1, 4) RETURNING ID`, table.name).Scan(table.id); err != nil {
+			t.Fatal(err)
+		}
+		id := *table.id
+		t.Cleanup(func() { db.Exec(`DELETE FROM dining_tables WHERE ID = $1`, id) })
+	}
+
+	mixed := addTestMenuItem(t, db, 5)
+	alike := addTestMenuItem(t, db, 5)
+	checkID := addTestOrder(t, db, target)
+	mergedID := addTestOrder(t, db, source)
+	addTestOrderItem(t, db, checkID, mixed, 1, models.StatusItemServed)
+	addTestOrderItem(t, db, mergedID, mixed, 1, models.StatusItemQueued)
+	addTestOrderItem(t, db, checkID, alike, 1, models.StatusItemServed)
+	addTestOrderItem(t, db, mergedID, alike, 2, models.StatusItemServed)
+
+	got, err := repo.Merge(target, source, models.ChangeInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != checkID {
+		t.Fatalf("merged into order %d, want %d", got, checkID)
+	}
+
+	tests := []struct {
+		name      string
+		productID int
+		quantity  int
+		status    string
+	}{
+		{"different statuses", mixed, 2, models.StatusItemQueued},
+		{"same status", alike, 3, models.StatusItemServed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quantity, status := lineStatus(t, db, checkID, tt.productID)
+			if quantity != tt.quantity || status != tt.status {
+				t.Errorf("got %d %s, want %d %s", quantity, status, tt.quantity, tt.status)
+			}
+		})
+	}
+}
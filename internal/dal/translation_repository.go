@@ -0,0 +1,72 @@
+package dal
+
+import (
+	"fmt"
+
+	"hot-coffee/models"
+)
+
+// GetTranslations retrieves the translations of all menu items into a locale, keyed by menu item ID.
+func (repo *MenuRepository) GetTranslations(locale string) (map[int]models.MenuItemTranslation, error) {
+	rows, err := repo.db.Query(`
+		SELECT MenuID, Locale, Name, Description FROM menu_item_translations WHERE Locale = $1
+	`, locale)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for menu_item_translations: %w", err)
+	}
+	defer rows.Close()
+
+	translations := make(map[int]models.MenuItemTranslation)
+	for rows.Next() {
+		var translation models.MenuItemTranslation
+		if err := rows.Scan(&translation.ProductID, &translation.Locale, &translation.Name, &translation.Description); err != nil {
+			return nil, fmt.Errorf("error scanning row in menu_item_translations: %w", err)
+		}
+		translations[translation.ProductID] = translation
+	}
+	return translations, rows.Err()
+}
+
+// GetMenuItemTranslations retrieves every translation of a menu item ordered by locale.
+func (repo *MenuRepository) GetMenuItemTranslations(menuItemID int) ([]models.MenuItemTranslation, error) {
+	rows, err := repo.db.Query(`
+		SELECT MenuID, Locale, Name, Description FROM menu_item_translations
+		WHERE MenuID = $1 ORDER BY Locale
+	`, menuItemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for menu_item_translations: %w", err)
+	}
+	defer rows.Close()
+
+	translations := []models.MenuItemTranslation{}
+	for rows.Next() {
+		var translation models.MenuItemTranslation
+		if err := rows.Scan(&translation.ProductID, &translation.Locale, &translation.Name, &translation.Description); err != nil {
+			return nil, fmt.Errorf("error scanning row in menu_item_translations: %w", err)
+		}
+		translations = append(translations, translation)
+	}
+	return translations, rows.Err()
+}
+
+// SaveTranslation adds the translation of a menu item or replaces the existing one for its locale.
+func (repo *MenuRepository) SaveTranslation(translation models.MenuItemTranslation) error {
+	_, err := repo.db.Exec(`
+		INSERT INTO menu_item_translations (MenuID, Locale, Name, Description)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (MenuID, Locale) DO UPDATE SET Name = EXCLUDED.Name, Description = EXCLUDED.Description
+	`, translation.ProductID, translation.Locale, translation.Name, translation.Description)
+	return err
+}
+
+// DeleteTranslation removes the translation of a menu item into a locale.
+func (repo *MenuRepository) DeleteTranslation(menuItemID int, locale string) error {
+	result, err := repo.db.Exec(`DELETE FROM menu_item_translations WHERE MenuID = $1 AND Locale = $2`, menuItemID, locale)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.ErrTranslationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+// GetUnits retrieves every unit of measure, grouped by dimension.
+func (repo *InventoryRepository) GetUnits() ([]models.Unit, error) {
+	rows, err := repo.db.Query(`SELECT Code, Name, Dimension, Factor, Decimals FROM units ORDER BY Dimension, Factor, Code`)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for units: %w", err)
+	}
+	defer rows.Close()
+
+	units := []models.Unit{}
+	for rows.Next() {
+		var unit models.Unit
+		unit.Decimals = new(int)
+		if err := rows.Scan(&unit.Code, &unit.Name, &unit.Dimension, &unit.Factor, unit.Decimals); err != nil {
+			return nil, fmt.Errorf("error scanning row in units: %w", err)
+		}
+		units = append(units, unit)
+	}
+	return units, rows.Err()
+}
+
+// AddUnit adds a unit of measure. Codes already used by a unit or by a pack unit of any
+// ingredient are rejected with models.ErrUnitCodeTaken, so a code always means one thing.
+func (repo *InventoryRepository) AddUnit(unit models.Unit) error {
+	result, err := repo.db.Exec(`
+		INSERT INTO units (Code, Name, Dimension, Factor, Decimals)
+		SELECT $1, $2, $3, $4, COALESCE($5, 3)
+		WHERE NOT EXISTS (SELECT 1 FROM ingredient_units WHERE Code = $1)
+	`, unit.Code, unit.Name, unit.Dimension, unit.Factor, unit.Decimals)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return models.ErrUnitCodeTaken
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.ErrUnitCodeTaken
+	}
+	return nil
+}
+
+// UnitExists checks whether a unit of measure with the given code exists.
+func (repo *InventoryRepository) UnitExists(code string) bool {
+	var exists bool
+	repo.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM units WHERE Code = $1)`, code).Scan(&exists)
+	return exists
+}
+
+// GetPackUnits retrieves the pack units of an ingredient.
+func (repo *InventoryRepository) GetPackUnits(ingredientID int) ([]models.PackUnit, error) {
+	rows, err := repo.db.Query(`
+		SELECT Code, Quantity FROM ingredient_units WHERE IngredientID = $1 ORDER BY Quantity, Code
+	`, ingredientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed request for ingredient_units: %w", err)
+	}
+	defer rows.Close()
+
+	packs := []models.PackUnit{}
+	for rows.Next() {
+		var pack models.PackUnit
+		if err := rows.Scan(&pack.Code, &pack.Quantity); err != nil {
+			return nil, fmt.Errorf("error scanning row in ingredient_units: %w", err)
+		}
+		packs = append(packs, pack)
+	}
+	return packs, rows.Err()
+}
+
+// SavePackUnits replaces the pack units of an ingredient. Dropping a pack unit that recipes
+// still use is rejected with models.ErrIncompatibleUnit.
+func (repo *InventoryRepository) SavePackUnits(ingredientID int, packs []models.PackUnit) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM ingredient_units WHERE IngredientID = $1`, ingredientID); err != nil {
+		return err
+	}
+	for _, pack := range packs {
+		_, err = tx.Exec(`INSERT INTO ingredient_units (IngredientID, Code, Quantity) VALUES ($1, $2, $3)`,
+			ingredientID, pack.Code, pack.Quantity)
+		if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "23505" || pqErr.Code == "23514") {
+			return models.ErrInvalidPackUnit
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = checkIngredientRecipes(tx, ingredientID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkIngredientRecipes makes sure the recipes in effect or scheduled that use an ingredient
+// still convert their quantities into its stock unit.
+func checkIngredientRecipes(q queryer, ingredientID int) error {
+	var broken int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM recipe_version_ingredients rvi
+		JOIN recipe_versions rv ON rv.ID = rvi.VersionID
+		WHERE rvi.IngredientID = $1
+		AND (rv.EffectiveFrom > CURRENT_TIMESTAMP OR rv.ID IN (SELECT RecipeVersionID FROM menu_items))
+		AND convert_to_stock_unit(rvi.Quantity, rvi.Unit, rvi.IngredientID) IS NULL
+	`, ingredientID).Scan(&broken)
+	if err != nil {
+		return err
+	}
+	if broken > 0 {
+		return models.ErrIncompatibleUnit
+	}
+	return nil
+}
+
+// ConvertToStockUnit converts a quantity given in unit into the stock unit of an ingredient.
+// An empty unit is the stock unit itself.
+func (repo *InventoryRepository) ConvertToStockUnit(ingredientID int, quantity float64, unit string) (float64, error) {
+	return convertToStockUnit(repo.db, ingredientID, quantity, unit)
+}
+
+// convertToStockUnit converts a quantity into the stock unit of an ingredient, or returns
+// models.ErrIncompatibleUnit when there is no way to convert it.
+func convertToStockUnit(q queryer, ingredientID int, quantity float64, unit string) (float64, error) {
+	var converted sql.NullFloat64
+	err := q.QueryRow(`SELECT convert_to_stock_unit($1, NULLIF($2, ''), $3)`, quantity, unit, ingredientID).Scan(&converted)
+	if err != nil {
+		return 0, err
+	}
+	if !converted.Valid {
+		return 0, fmt.Errorf("%w: %q for ingredient %d", models.ErrIncompatibleUnit, unit, ingredientID)
+	}
+	return converted.Float64, nil
+}
+
+// checkRecipeUnits makes sure every ingredient of a recipe is given in a unit that converts
+// into its stock unit.
+func checkRecipeUnits(q queryer, ingredients []models.MenuItemIngredient) error {
+	for _, ingredient := range ingredients {
+		if _, err := convertToStockUnit(q, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withStockUnits returns the ingredients with an empty unit set to the ingredient's stock unit.
+// Recipes are stored with it, so a later change of the stock unit does not change what they mean.
+func withStockUnits(q queryer, ingredients []models.MenuItemIngredient) ([]models.MenuItemIngredient, error) {
+	resolved := make([]models.MenuItemIngredient, len(ingredients))
+	for i, ingredient := range ingredients {
+		if ingredient.Unit == "" {
+			err := q.QueryRow(`SELECT Unit FROM inventory WHERE IngredientID = $1`, ingredient.IngredientID).Scan(&ingredient.Unit)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+		}
+		resolved[i] = ingredient
+	}
+	return resolved, nil
+}
@@ -0,0 +1,84 @@
+package dal
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestConvertToStockUnit(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInventoryRepository(db)
+
+	var milkID, sugarID int
+	err := db.QueryRow(`INSERT INTO inventory (Name, Quantity, Unit, Density) VALUES ('Test milk', 10, 'l', 1.03) RETURNING IngredientID`).Scan(&milkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM inventory WHERE IngredientID = $1`, milkID) })
+	err = db.QueryRow(`INSERT INTO inventory (Name, Quantity, Unit) VALUES ('Test sugar', 1000, 'g') RETURNING IngredientID`).Scan(&sugarID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM inventory WHERE IngredientID = $1`, sugarID) })
+	if _, err := db.Exec(`INSERT INTO ingredient_units (IngredientID, Code, Quantity) VALUES ($1, 'test_sachet', 5)`, sugarID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		ingredientID int
+		quantity     float64
+		unit         string
+		want         float64
+		wantErr      error
+	}{
+		{"stock unit", milkID, 0.25, "", 0.25, nil},
+		{"same dimension", milkID, 250, "ml", 0.25, nil},
+		{"mass through density", milkID, 103, "g", 0.1, nil},
+		{"pack unit", sugarID, 3, "test_sachet", 15, nil},
+		{"larger unit", sugarID, 0.5, "kg", 500, nil},
+		{"volume without density", sugarID, 10, "ml", 0, models.ErrIncompatibleUnit},
+		{"count into mass", sugarID, 1, "pc", 0, models.ErrIncompatibleUnit},
+		{"unknown unit", sugarID, 1, "test_nope", 0, models.ErrIncompatibleUnit},
+		{"pack unit of another ingredient", milkID, 1, "test_sachet", 0, models.ErrIncompatibleUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.ConvertToStockUnit(tt.ingredientID, tt.quantity, tt.unit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddUnitRejectsPackUnitCodes(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInventoryRepository(db)
+
+	var id int
+	err := db.QueryRow(`INSERT INTO inventory (Name, Quantity, Unit) VALUES ('Test beans', 1000, 'g') RETURNING IngredientID`).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM inventory WHERE IngredientID = $1`, id) })
+	if _, err := db.Exec(`INSERT INTO ingredient_units (IngredientID, Code, Quantity) VALUES ($1, 'test_tin', 250)`, id); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM units WHERE Code = 'test_tin'`) })
+
+	err = repo.AddUnit(models.Unit{Code: "test_tin", Name: "tin", Dimension: models.UnitMass, Factor: 250})
+	if err != models.ErrUnitCodeTaken {
+		t.Errorf("err = %v, want %v", err, models.ErrUnitCodeTaken)
+	}
+	err = repo.AddUnit(models.Unit{Code: "g", Name: "gram", Dimension: models.UnitMass, Factor: 1})
+	if err != models.ErrUnitCodeTaken {
+		t.Errorf("err = %v, want %v", err, models.ErrUnitCodeTaken)
+	}
+}
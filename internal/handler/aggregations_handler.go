@@ -9,6 +9,7 @@ import (
 
 	"hot-coffee/internal/error_handler"
 	"hot-coffee/internal/service"
+	"hot-coffee/models"
 )
 
 // AggregationHandler handles aggregation-related HTTP requests such as sales, popular items, and search functionality.
@@ -32,9 +33,13 @@ func (h *AggregationHandler) TotalSalesHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	// Fetch the total sales data
-	totalSales, err := h.orderService.GetTotalSales()
+	totalSales, err := h.orderService.GetTotalSales(r.URL.Query().Get("channel"))
 	if err != nil {
 		h.logger.Error("Error getting data", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrInvalidChannel {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		error_handler.Error(w, "Error getting data", http.StatusInternalServerError)
 		return
 	}
@@ -49,9 +54,13 @@ func (h *AggregationHandler) TotalSalesHandler(w http.ResponseWriter, r *http.Re
 // PopularItemsHandler handles requests to retrieve the popular menu items.
 func (h *AggregationHandler) PopularItemsHandler(w http.ResponseWriter, r *http.Request) {
 	// Fetch the most popular menu items
-	popularItems, err := h.aggregationService.GetPopularMenuItems()
+	popularItems, err := h.aggregationService.GetPopularMenuItems(r.URL.Query().Get("channel"), r.URL.Query().Get("groupBy"))
 	if err != nil {
 		h.logger.Error("Error getting popular items", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrInvalidChannel || err == service.ErrWrongGroupBy {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		error_handler.Error(w, "Error getting popular items", http.StatusInternalServerError)
 		return
 	}
@@ -63,6 +72,114 @@ func (h *AggregationHandler) PopularItemsHandler(w http.ResponseWriter, r *http.
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 }
 
+// RevenueHandler handles requests for the revenue earned per menu item.
+func (h *AggregationHandler) RevenueHandler(w http.ResponseWriter, r *http.Request) {
+	revenue, err := h.aggregationService.GetRevenueByItem(r.URL.Query().Get("channel"))
+	if err != nil {
+		h.logger.Error("Error getting revenue", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrInvalidChannel {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		error_handler.Error(w, "Error getting revenue", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revenue)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// IngredientUsageHandler handles requests for the ingredients consumed by orders.
+// Accepts ?from= and ?to= as dates or RFC 3339 times.
+func (h *AggregationHandler) IngredientUsageHandler(w http.ResponseWriter, r *http.Request) {
+	from, err := parseDateParam(r.URL.Query().Get("from"), false)
+	if err != nil {
+		h.logger.Error("Invalid from value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "from must be a date like 2025-01-31", http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(r.URL.Query().Get("to"), true)
+	if err != nil {
+		h.logger.Error("Invalid to value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "to must be a date like 2025-01-31", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := h.aggregationService.GetIngredientUsage(from, to)
+	if err != nil {
+		h.logger.Error("Error getting ingredient usage", "error", err, "method", r.Method, "url", r.URL)
+		if err == service.ErrWrongPeriod {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		error_handler.Error(w, "Error getting ingredient usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// InventoryValuationHandler handles requests for the value of the stock held.
+// Accepts ?date= as a date, meaning the end of that day, or an RFC 3339 time. It defaults to now.
+func (h *AggregationHandler) InventoryValuationHandler(w http.ResponseWriter, r *http.Request) {
+	at, err := parseDateParam(r.URL.Query().Get("date"), true)
+	if err != nil {
+		h.logger.Error("Invalid date value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "date must be a date like 2025-01-31", http.StatusBadRequest)
+		return
+	}
+
+	valuation, err := h.aggregationService.GetInventoryValuation(at)
+	if err != nil {
+		h.logger.Error("Error getting inventory valuation", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Error getting inventory valuation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(valuation)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// MenuEngineeringHandler handles requests for the menu engineering matrix.
+// Accepts ?from= and ?to= as dates or RFC 3339 times and ?channel=.
+func (h *AggregationHandler) MenuEngineeringHandler(w http.ResponseWriter, r *http.Request) {
+	from, err := parseDateParam(r.URL.Query().Get("from"), false)
+	if err != nil {
+		h.logger.Error("Invalid from value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "from must be a date like 2025-01-31", http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(r.URL.Query().Get("to"), true)
+	if err != nil {
+		h.logger.Error("Invalid to value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "to must be a date like 2025-01-31", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.aggregationService.GetMenuEngineering(r.URL.Query().Get("channel"), from, to)
+	if err != nil {
+		h.logger.Error("Error getting menu engineering report", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrInvalidChannel || err == service.ErrWrongPeriod {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		error_handler.Error(w, "Error getting menu engineering report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
 // SearchHandler handles search requests based on query parameters (search, filter, price range).
 func (h *AggregationHandler) SearchHandler(w http.ResponseWriter, r *http.Request) {
 	// Retrieve query parameters from the URL
@@ -109,8 +226,16 @@ func (h *AggregationHandler) SearchHandler(w http.ResponseWriter, r *http.Reques
 		MaxPrice = -1 // Default value if maxPrice is not specified
 	}
 
+	// Menu items are searched in the requested language
+	locale, err := negotiateLocale(r)
+	if err != nil {
+		h.logger.Error("Invalid lang value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Perform the search with the given parameters
-	searchResult, err := h.aggregationService.Search(searchQuery, MinPrice, MaxPrice, filter)
+	searchResult, err := h.aggregationService.Search(searchQuery, MinPrice, MaxPrice, filter, locale)
 	if err != nil {
 		h.logger.Error("Error searching", "method", r.Method, "url", r.URL, "err", err.Error())
 		if err == service.ErrSearchRequired || err == service.ErrWrongFilterOptions || err == service.ErrPriceNotPositive {
@@ -124,6 +249,7 @@ func (h *AggregationHandler) SearchHandler(w http.ResponseWriter, r *http.Reques
 
 	// Return search results as JSON response
 	w.Header().Set("Content-Type", "application/json")
+	setLocaleHeaders(w, locale)
 	if err = json.NewEncoder(w).Encode(searchResult); err != nil {
 		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
 		error_handler.Error(w, "Could not encode request json data", http.StatusInternalServerError)
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/models"
+)
+
+// GetMenuItemCosting retrieves the cost, margin and suggested price of a menu item.
+// Accepts ?targetMargin= as a percentage.
+func (h *MenuHandler) GetMenuItemCosting(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+	targetMargin, err := percentParam(r, "targetMargin")
+	if err != nil {
+		h.logger.Error("Invalid targetMargin value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "targetMargin must be a number", http.StatusBadRequest)
+		return
+	}
+
+	costing, err := h.menuService.GetMenuItemCosting(id, targetMargin)
+	if err != nil {
+		h.logger.Error("Could not get menu item costing", "error", err, "method", r.Method, "url", r.URL)
+		switch err {
+		case models.ErrMenuItemNotFound:
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case models.ErrInvalidMargin:
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not get menu item costing", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(costing); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetMarginReport retrieves the margin of every menu item and flags the ones below a threshold.
+// Accepts ?threshold= as a percentage.
+func (h *MenuHandler) GetMarginReport(w http.ResponseWriter, r *http.Request) {
+	threshold, err := percentParam(r, "threshold")
+	if err != nil {
+		h.logger.Error("Invalid threshold value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "threshold must be a number", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.menuService.GetMarginReport(threshold)
+	if err != nil {
+		h.logger.Error("Could not get margin report", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrInvalidMargin {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		error_handler.Error(w, "Could not get margin report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// percentParam reads an optional percentage from the query string. A missing value gives 0.
+func percentParam(r *http.Request, key string) (float64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
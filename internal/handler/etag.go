@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/models"
+)
+
+// versionETag formats the row version of a resource as a strong ETag. Representations in a
+// language other than the default one get a tag of their own.
+func versionETag(version int, locale string) string {
+	if locale == "" || locale == models.DefaultLocale {
+		return fmt.Sprintf(`"%d"`, version)
+	}
+	return fmt.Sprintf(`"%d-%s"`, version, locale)
+}
+
+// menuItemETag tags a menu item with its row version and the stock it was read with. Available
+// servings and sold out follow the inventory, which changes without bumping the item's version,
+// so they go into the tag as well and a cached copy does not outlive the stock it showed.
+func menuItemETag(item models.MenuItem, locale string) string {
+	servings := "-"
+	if item.AvailableServings != nil {
+		servings = strconv.Itoa(*item.AvailableServings)
+	}
+	stock := fnv.New32a()
+	fmt.Fprintf(stock, "%s/%t", servings, item.SoldOut)
+
+	tag := strings.TrimSuffix(versionETag(item.Version, locale), `"`)
+	return fmt.Sprintf(`%s-s%08x"`, tag, stock.Sum32())
+}
+
+// setETag sets the ETag of a GET response. It answers 304 Not Modified and returns true when
+// the client's If-None-Match already holds that tag.
+func setETag(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the row version a PUT or DELETE was based on, read from If-Match.
+// A missing header or "*" gives 0, which accepts any version. Tags that cannot match a version,
+// such as weak ones, are answered with 412 Precondition Failed and ok is false. A write is based
+// on a single version, so a list of several tags is answered with 412 as well.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (version int, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	// Tags are "<version>", "<version>-<locale>" or, for menu items, end in a stock fingerprint;
+	// only the version matters for writes
+	tag := strings.Trim(header, `"`)
+	tag, _, _ = strings.Cut(tag, "-")
+	version, err := strconv.Atoi(tag)
+	if err != nil || !strings.HasPrefix(header, `"`) || version < 1 {
+		error_handler.Error(w, models.ErrVersionConflict.Error(), http.StatusPreconditionFailed)
+		return 0, false
+	}
+	return version, true
+}
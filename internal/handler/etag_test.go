@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestSetETag(t *testing.T) {
+	tests := []struct {
+		name        string
+		etag        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"no header", `"3"`, "", false},
+		{"same tag", `"3"`, `"3"`, true},
+		{"weak tag", `"3"`, `W/"3"`, true},
+		{"other version", `"3"`, `"2"`, false},
+		{"localized tag", `"3-ru"`, `"3-ru"`, true},
+		{"other locale", `"3-ru"`, `"3"`, false},
+		{"wildcard", `"3"`, `*`, true},
+		{"list holding the tag", `"3"`, `"1", W/"2", "3"`, true},
+		{"list without the tag", `"3"`, `"1", "2"`, false},
+		{"unquoted", `"3"`, `3`, false},
+		{"garbage", `"3"`, `"3`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/menu/1", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			got := setETag(w, r, tt.etag)
+			if got != tt.want {
+				t.Fatalf("setETag = %v, want %v", got, tt.want)
+			}
+			if w.Header().Get("ETag") != tt.etag {
+				t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), tt.etag)
+			}
+			if tt.want && w.Code != http.StatusNotModified {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNotModified)
+			}
+		})
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		version int
+		ok      bool
+	}{
+		{"no header", "", 0, true},
+		{"wildcard", "*", 0, true},
+		{"version", `"3"`, 3, true},
+		{"localized tag", `"3-ru"`, 3, true},
+		{"menu item tag", `"3-ru-s0a1b2c3d"`, 3, true},
+		{"weak tag", `W/"3"`, 0, false},
+		{"several tags", `"3", "4"`, 0, false},
+		{"unquoted", `3`, 0, false},
+		{"zero", `"0"`, 0, false},
+		{"garbage", `"latest"`, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/menu/1", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			version, ok := ifMatchVersion(w, r)
+			if version != tt.version || ok != tt.ok {
+				t.Fatalf("got %d, %v, want %d, %v", version, ok, tt.version, tt.ok)
+			}
+			if !ok && w.Code != http.StatusPreconditionFailed {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusPreconditionFailed)
+			}
+		})
+	}
+}
+
+func TestMenuItemETag(t *testing.T) {
+	servings := func(n int) *int { return &n }
+	item := models.MenuItem{ID: 1, Version: 3, AvailableServings: servings(12)}
+	base := menuItemETag(item, "")
+
+	if again := menuItemETag(item, ""); again != base {
+		t.Errorf("same item and stock got %s and %s", base, again)
+	}
+	changes := []struct {
+		name string
+		item models.MenuItem
+	}{
+		{"fewer servings", models.MenuItem{ID: 1, Version: 3, AvailableServings: servings(11)}},
+		{"sold out", models.MenuItem{ID: 1, Version: 3, AvailableServings: servings(0), SoldOut: true}},
+		{"no ingredients left", models.MenuItem{ID: 1, Version: 3}},
+		{"new version", models.MenuItem{ID: 1, Version: 4, AvailableServings: servings(12)}},
+	}
+	for _, change := range changes {
+		t.Run(change.name, func(t *testing.T) {
+			if tag := menuItemETag(change.item, ""); tag == base {
+				t.Errorf("ETag stayed %s", tag)
+			}
+		})
+	}
+	if tag := menuItemETag(item, "ru"); tag == base {
+		t.Errorf("localized ETag is the same as the default one: %s", tag)
+	}
+}
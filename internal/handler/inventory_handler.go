@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -34,8 +35,8 @@ func (h *InventoryHandler) PostInventory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Validate required fields (Name, Unit, Quantity)
-	if newItem.Name == "" || newItem.Unit == "" || newItem.Quantity <= 0 {
+	// Validate required fields (Name, Unit, Quantity), the unit cost and the density
+	if newItem.Name == "" || newItem.Unit == "" || newItem.Quantity <= 0 || newItem.UnitCost < 0 || newItem.Density < 0 {
 		h.logger.Error("Some fields are empty, equal or less than zero", "method", r.Method, "url", r.URL)
 		error_handler.Error(w, "Some fields are empty, equal or less than zero", http.StatusBadRequest)
 		return
@@ -45,6 +46,10 @@ func (h *InventoryHandler) PostInventory(w http.ResponseWriter, r *http.Request)
 	err = h.inventoryService.AddInventoryItem(newItem)
 	if err != nil {
 		h.logger.Error("Could not add new inventory item", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrUnknownUnit {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		error_handler.Error(w, "Could not add new inventory item Error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -114,6 +119,9 @@ func (h *InventoryHandler) GetInventoryItem(w http.ResponseWriter, r *http.Reque
 	}
 	// Respond with the inventory item
 	w.Header().Set("Content-Type", "application/json")
+	if setETag(w, r, versionETag(inventoryItem.Version, "")) {
+		return
+	}
 	w.Write(jsonData)
 
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
@@ -130,13 +138,6 @@ func (h *InventoryHandler) PutInventoryItem(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Validate required fields
-	if newItem.Name == "" || newItem.Unit == "" || newItem.Quantity <= 0 {
-		h.logger.Error("Some fields are empty", "method", r.Method, "url", r.URL)
-		error_handler.Error(w, "Some fields are empty, equal or less than zero", http.StatusBadRequest)
-		return
-	}
-
 	// Get the inventory item ID from the URL path
 	idStr := r.PathValue("id")
 
@@ -155,10 +156,82 @@ func (h *InventoryHandler) PutInventoryItem(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// The edit only applies to the version named in If-Match
+	var ok bool
+	if newItem.Version, ok = ifMatchVersion(w, r); !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+
+	h.saveInventoryItem(w, r, id, newItem)
+}
+
+// PatchInventoryItem changes the fields of an inventory item named in a JSON Merge Patch. The
+// merged item is validated like in PutInventoryItem and saved only if the item has not changed
+// since it was read.
+func (h *InventoryHandler) PatchInventoryItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Inventory id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Inventory id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	// Check if the inventory item exists
+	if !h.inventoryService.Exists(id) {
+		h.logger.Error("Inventory item does not exists", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Inventory item does not exists", http.StatusNotFound)
+		return
+	}
+
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		h.logger.Error("Could not read merge patch", "method", r.Method, "url", r.URL)
+		return
+	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+
+	newItem, err := h.inventoryService.PatchItem(id, patch, version)
+	if err != nil {
+		h.logger.Error("Could not apply merge patch", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case err == models.ErrVersionConflict:
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, models.ErrInvalidPatch):
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not apply merge patch", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.saveInventoryItem(w, r, id, newItem)
+}
+
+// saveInventoryItem validates an edited inventory item and saves it.
+func (h *InventoryHandler) saveInventoryItem(w http.ResponseWriter, r *http.Request, id int, newItem models.InventoryItem) {
+	// Validate required fields
+	if newItem.Name == "" || newItem.Unit == "" || newItem.Quantity <= 0 || newItem.UnitCost < 0 || newItem.Density < 0 {
+		h.logger.Error("Some fields are empty", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Some fields are empty, equal or less than zero", http.StatusBadRequest)
+		return
+	}
+
 	// Update the inventory item
-	err = h.inventoryService.UpdateItem(id, newItem)
+	err := h.inventoryService.UpdateItem(id, newItem)
 	if err != nil {
 		h.logger.Error("Error updating inventory item", "error", err, "method", r.Method, "url", r.URL)
+		switch err {
+		case models.ErrVersionConflict:
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		case models.ErrUnknownUnit, models.ErrIncompatibleUnit:
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		error_handler.Error(w, "Error updating inventory item Error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -166,6 +239,116 @@ func (h *InventoryHandler) PutInventoryItem(w http.ResponseWriter, r *http.Reque
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 }
 
+// RestockInventoryItem adds a delivery to the stock of an inventory item and responds with the
+// restocked item.
+func (h *InventoryHandler) RestockInventoryItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Inventory id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Inventory id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var restock models.Restock
+	if err := json.NewDecoder(r.Body).Decode(&restock); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+
+	item, err := h.inventoryService.RestockItem(id, restock, version)
+	if err != nil {
+		h.logger.Error("Could not restock inventory item", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case err.Error() == "inventory item does not exist":
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case err == models.ErrVersionConflict:
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case err == models.ErrInvalidRestock, errors.Is(err, models.ErrIncompatibleUnit):
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not restock inventory item", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", versionETag(item.Version, ""))
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetInventorySubstitutes retrieves the substitutes of an inventory item in the order they are tried.
+func (h *InventoryHandler) GetInventorySubstitutes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Inventory id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Inventory id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	substitutes, err := h.inventoryService.GetSubstitutes(id)
+	if err != nil {
+		h.logger.Error("Could not get substitutes", "error", err, "method", r.Method, "url", r.URL)
+		if err.Error() == "inventory item does not exist" {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not get substitutes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(substitutes); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PutInventorySubstitutes replaces the substitutes of an inventory item with the list in the body.
+func (h *InventoryHandler) PutInventorySubstitutes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Inventory id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Inventory id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var substitutes []models.IngredientSubstitute
+	if err := json.NewDecoder(r.Body).Decode(&substitutes); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.inventoryService.SaveSubstitutes(id, substitutes); err != nil {
+		h.logger.Error("Could not save substitutes", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case err.Error() == "inventory item does not exist":
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case err == models.ErrInvalidSubstitute:
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not save substitutes", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
 // DeleteInventoryItem deletes an inventory item by ID.
 func (h *InventoryHandler) DeleteInventoryItem(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
@@ -185,10 +368,24 @@ func (h *InventoryHandler) DeleteInventoryItem(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+
 	// Delete the inventory item
-	err = h.inventoryService.DeleteItem(id)
+	err = h.inventoryService.DeleteItem(id, version)
 	if err != nil {
 		h.logger.Error("Could not delete inventory item", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrVersionConflict {
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		if err == models.ErrIngredientInUse {
+			error_handler.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		error_handler.Error(w, "Could not delete inventory item", http.StatusInternalServerError)
 		return
 	}
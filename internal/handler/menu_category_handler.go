@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/models"
+)
+
+// GetCategories retrieves all menu categories in display order.
+func (h *MenuHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.menuService.GetCategories()
+	if err != nil {
+		h.logger.Error("Could not get menu categories", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not get menu categories", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(categories); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostCategory creates a new menu category.
+func (h *MenuHandler) PostCategory(w http.ResponseWriter, r *http.Request) {
+	var category models.MenuCategory
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+	category.ID = 0
+
+	id, err := h.menuService.AddCategory(category)
+	if err != nil {
+		h.logger.Error("Could not add menu category", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"category_id": id})
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PutCategory updates the name, parent and display order of a menu category.
+func (h *MenuHandler) PutCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Category id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Category id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var category models.MenuCategory
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+	category.ID = id
+
+	if err := h.menuService.UpdateCategory(category); err != nil {
+		h.logger.Error("Could not update menu category", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrCategoryNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// DeleteCategory removes a menu category, leaving its items without a category.
+func (h *MenuHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Category id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Category id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.menuService.DeleteCategory(id); err != nil {
+		h.logger.Error("Could not delete menu category", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrCategoryNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not delete menu category", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -1,18 +1,20 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 
 	"hot-coffee/internal/error_handler"
 	"hot-coffee/internal/service"
+	"hot-coffee/internal/storage"
 	"hot-coffee/models"
 )
 
@@ -30,7 +32,7 @@ func NewMenuHandler(menuService *service.MenuService, logger *slog.Logger) *Menu
 // PostMenu adds a new menu item with an optional image uploaded as part of a multipart form.
 func (h *MenuHandler) PostMenu(w http.ResponseWriter, r *http.Request) {
 	var newItem models.MenuItem
-	imagePath := "uploads/default.jpg" // Default image path
+	imagePath := service.DefaultImage // Default image path
 	contentType := r.Header.Get("Content-Type")
 
 	// Check if the request content is JSON or multipart (form data)
@@ -43,27 +45,18 @@ func (h *MenuHandler) PostMenu(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// If multipart, handle file upload and form fields
-		err := r.ParseMultipartForm(10 << 20) // 10MB limit for file size
-		if err != nil {
-			error_handler.Error(w, "Invalid form data", http.StatusBadRequest)
+		if !h.parseUploadForm(w, r) {
 			return
 		}
 
 		// Handle image file upload
-		file, handler, err := r.FormFile("image")
-		if err == nil {
-			defer file.Close()
-			ext := filepath.Ext(handler.Filename)
-			imagePath = fmt.Sprintf("uploads/menu-%d%s", time.Now().UnixNano(), ext)
-
-			// Save the image file to disk
-			outFile, err := os.Create(imagePath)
-			if err != nil {
-				error_handler.Error(w, "Could not save image", http.StatusInternalServerError)
-				return
-			}
-			defer outFile.Close()
-			io.Copy(outFile, file)
+		uploaded, err := h.saveUploadedImage(r)
+		if err != nil {
+			h.uploadError(w, r, err)
+			return
+		}
+		if uploaded != "" {
+			imagePath = uploaded
 		}
 
 		// Parse the rest of the form data
@@ -71,6 +64,15 @@ func (h *MenuHandler) PostMenu(w http.ResponseWriter, r *http.Request) {
 		newItem.Description = r.FormValue("description")
 		newItem.Price, _ = strconv.ParseFloat(r.FormValue("price"), 64)
 		json.Unmarshal([]byte(r.FormValue("ingredients")), &newItem.Ingredients)
+		newItem.CategoryID, _ = strconv.Atoi(r.FormValue("category_id"))
+		newItem.Position, _ = strconv.Atoi(r.FormValue("position"))
+		if schedules := r.FormValue("schedules"); schedules != "" {
+			if err := json.Unmarshal([]byte(schedules), &newItem.Schedules); err != nil {
+				h.logger.Error("Invalid schedules", "error", err, "method", r.Method, "url", r.URL)
+				error_handler.Error(w, "Invalid JSON format of schedules", http.StatusBadRequest)
+				return
+			}
+		}
 
 		// Validate that all required fields are provided
 		if newItem.Name == "" || newItem.Description == "" || newItem.Price == 0 {
@@ -98,6 +100,10 @@ func (h *MenuHandler) PostMenu(w http.ResponseWriter, r *http.Request) {
 
 	// Add the menu item
 	if err := h.menuService.AddMenuItem(newItem); err != nil {
+		if errors.Is(err, models.ErrIncompatibleUnit) {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		error_handler.Error(w, "Could not add menu item", http.StatusInternalServerError)
 		return
 	}
@@ -117,8 +123,7 @@ func (h *MenuHandler) DeleteMenuItemImage(w http.ResponseWriter, r *http.Request
 	}
 
 	// Update image to default
-	defaultImage := "uploads/default.jpg"
-	err = h.menuService.UpdateMenuItemImage(id, defaultImage)
+	err = h.menuService.UpdateMenuItemImage(id, service.DefaultImage)
 	if err != nil {
 		error_handler.Error(w, "Could not reset image", http.StatusInternalServerError)
 		return
@@ -130,9 +135,62 @@ func (h *MenuHandler) DeleteMenuItemImage(w http.ResponseWriter, r *http.Request
 }
 
 // GetMenu retrieves all menu items and returns them as a JSON array.
+// ?category= limits the menu to one category and its subcategories, and
+// ?groupBy=category returns the menu as nested category sections. Items outside their
+// availability windows are left out unless ?all=true is given.
 func (h *MenuHandler) GetMenu(w http.ResponseWriter, r *http.Request) {
-	MenuItems, err := h.menuService.GetMenuItems()
+	categoryID := 0
+	if category := r.URL.Query().Get("category"); category != "" {
+		var err error
+		categoryID, err = strconv.Atoi(category)
+		if err != nil {
+			h.logger.Error("Category id must be integer", "method", r.Method, "url", r.URL)
+			error_handler.Error(w, "Category id must be integer", http.StatusBadRequest)
+			return
+		}
+	}
+	// Only items available now (or at ?at=) are listed unless ?all=true is given
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		var err error
+		at, err = parseMenuTime(value)
+		if err != nil {
+			h.logger.Error("Invalid at value", "error", err, "method", r.Method, "url", r.URL)
+			error_handler.Error(w, "at must be a time like 2025-01-31T09:30", http.StatusBadRequest)
+			return
+		}
+	}
+	if r.URL.Query().Get("all") == "true" {
+		at = time.Time{}
+	}
+	groupBy := r.URL.Query().Get("groupBy")
+	if groupBy != "" && groupBy != "category" {
+		h.logger.Error("Invalid groupBy value", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, service.ErrWrongGroupBy.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := negotiateLocale(r)
 	if err != nil {
+		h.logger.Error("Invalid lang value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var MenuItems interface{}
+	switch {
+	case groupBy == "category":
+		MenuItems, err = h.menuService.GetMenuSections(categoryID, at, locale)
+	case categoryID != 0:
+		MenuItems, err = h.menuService.GetMenuItemsByCategory(categoryID, at, locale)
+	default:
+		MenuItems, err = h.menuService.GetMenuItems(at, locale)
+	}
+	if err != nil {
+		if err == models.ErrCategoryNotFound {
+			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		h.logger.Error("Could not read menu database", "error", err, "method", r.Method, "url", r.URL)
 		error_handler.Error(w, "Could not read menu database", http.StatusInternalServerError)
 		return
@@ -145,6 +203,7 @@ func (h *MenuHandler) GetMenu(w http.ResponseWriter, r *http.Request) {
 	}
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 	w.Header().Set("Content-Type", "application/json")
+	setLocaleHeaders(w, locale)
 	w.Write(jsonData)
 }
 
@@ -159,11 +218,18 @@ func (h *MenuHandler) GetMenuItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Retrieve the menu item by ID
-	MenuItem, err := h.menuService.GetMenuItem(id)
+	locale, err := negotiateLocale(r)
+	if err != nil {
+		h.logger.Error("Invalid lang value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Retrieve the menu item by ID in the requested language
+	MenuItem, err := h.menuService.GetTranslatedMenuItem(id, locale)
 	if err != nil {
 		// Handle item not found case
-		if err.Error() == "could not find menu item by the given id" {
+		if err == models.ErrMenuItemNotFound {
 			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 			error_handler.Error(w, err.Error(), http.StatusNotFound)
 			return
@@ -179,6 +245,10 @@ func (h *MenuHandler) GetMenuItem(w http.ResponseWriter, r *http.Request) {
 		error_handler.Error(w, "Could not send menu item", http.StatusInternalServerError)
 	}
 	w.Header().Set("Content-Type", "application/json")
+	setLocaleHeaders(w, locale)
+	if setETag(w, r, menuItemETag(MenuItem, locale)) {
+		return
+	}
 	w.Write(jsonData)
 
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
@@ -204,22 +274,74 @@ func (h *MenuHandler) PutMenuItem(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	requestedMenuItem.ID = id
+	// The edit only applies to the version named in If-Match
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+	requestedMenuItem.Version = version
 
+	h.saveMenuItem(w, r, requestedMenuItem)
+}
+
+// PatchMenuItem changes the fields of a menu item named in a JSON Merge Patch. The merged
+// item goes through the same checks as PutMenuItem and is saved only if the item has not
+// changed since it was read.
+func (h *MenuHandler) PatchMenuItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		h.logger.Error("Could not read merge patch", "method", r.Method, "url", r.URL)
+		return
+	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+
+	menuItem, err := h.menuService.PatchMenuItem(id, patch, version)
+	if err != nil {
+		h.logger.Error("Could not apply merge patch", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case err == models.ErrMenuItemNotFound:
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case err == models.ErrVersionConflict:
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, models.ErrInvalidPatch):
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not apply merge patch", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.saveMenuItem(w, r, menuItem)
+}
+
+// saveMenuItem validates an edited menu item and saves it.
+func (h *MenuHandler) saveMenuItem(w http.ResponseWriter, r *http.Request, requestedMenuItem models.MenuItem) {
 	// Check if the menu item exists and validate
-	if err = h.menuService.MenuCheckByID(id, true); err != nil {
+	if err := h.menuService.MenuCheckByID(requestedMenuItem.ID, true); err != nil {
 		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 		error_handler.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Validate and check ingredients for the new item
-	if err = h.menuService.CheckNewMenu(requestedMenuItem); err != nil {
+	if err := h.menuService.CheckNewMenu(requestedMenuItem); err != nil {
 		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 		error_handler.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err = h.menuService.IngredientsCheckForNewItem(requestedMenuItem); err != nil {
+	if err := h.menuService.IngredientsCheckForNewItem(requestedMenuItem); err != nil {
 		h.logger.Error(err.Error(), "method", r.Method, "url", r.URL)
 		error_handler.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -227,12 +349,20 @@ func (h *MenuHandler) PutMenuItem(w http.ResponseWriter, r *http.Request) {
 
 	// Use default image if no image path is provided
 	if requestedMenuItem.Image == "" {
-		requestedMenuItem.Image = "uploads/default.jpg"
+		requestedMenuItem.Image = service.DefaultImage
 	}
 
 	// Update the menu item in the service/database
-	if err = h.menuService.UpdateMenuItem(requestedMenuItem); err != nil {
+	if err := h.menuService.UpdateMenuItem(requestedMenuItem); err != nil {
 		h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrVersionConflict {
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		if errors.Is(err, models.ErrIncompatibleUnit) {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		error_handler.Error(w, "Could not update menu database", http.StatusInternalServerError)
 		return
 	}
@@ -252,38 +382,18 @@ func (h *MenuHandler) PutMenuItemImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse form data and handle file upload
-	err = r.ParseMultipartForm(10 << 20) // 10MB limit for file size
-	if err != nil {
-		error_handler.Error(w, "Invalid form data", http.StatusBadRequest)
-		return
-	}
-
-	file, handler, err := r.FormFile("image")
-	if err != nil {
-		error_handler.Error(w, "No file uploaded", http.StatusBadRequest)
-		return
-	}
-	defer file.Close()
-
-	// Validate the file format
-	ext := filepath.Ext(handler.Filename)
-	if ext != ".jpg" && ext != ".png" && ext != ".jpeg" {
-		error_handler.Error(w, "Invalid image format", http.StatusBadRequest)
+	if !h.parseUploadForm(w, r) {
 		return
 	}
 
-	// Save the image file
-	imagePath := fmt.Sprintf("uploads/menu-%d%s", id, ext)
-
-	outFile, err := os.Create(imagePath)
+	// The upload is checked by content and stored in every rendition
+	imagePath, err := h.saveUploadedImage(r)
 	if err != nil {
-		error_handler.Error(w, "Could not save image", http.StatusInternalServerError)
+		h.uploadError(w, r, err)
 		return
 	}
-	defer outFile.Close()
-	_, err = io.Copy(outFile, file)
-	if err != nil {
-		error_handler.Error(w, "Failed to save image", http.StatusInternalServerError)
+	if imagePath == "" {
+		error_handler.Error(w, "No file uploaded", http.StatusBadRequest)
 		return
 	}
 
@@ -298,7 +408,8 @@ func (h *MenuHandler) PutMenuItemImage(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// DeleteMenuItem deletes a menu item by its ID.
+// DeleteMenuItem archives a menu item by its ID. With ?purge=true the item is deleted
+// permanently instead, which is only allowed while no order refers to it.
 func (h *MenuHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
@@ -308,11 +419,29 @@ func (h *MenuHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete the menu item from the database
-	err = h.menuService.DeleteMenuItem(id)
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+
+	if r.URL.Query().Get("purge") == "true" {
+		err = h.menuService.PurgeMenuItem(id, version)
+	} else {
+		err = h.menuService.DeleteMenuItem(id, version)
+	}
 	if err != nil {
 		h.logger.Error("Could not delete menu item", "error", err, "method", r.Method, "url", r.URL)
-		error_handler.Error(w, "Could not delete menu item", http.StatusInternalServerError)
+		switch err {
+		case models.ErrMenuItemNotFound:
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case models.ErrMenuItemInUse:
+			error_handler.Error(w, err.Error(), http.StatusConflict)
+		case models.ErrVersionConflict:
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+		default:
+			error_handler.Error(w, "Could not delete menu item", http.StatusInternalServerError)
+		}
 		return
 	}
 	// Respond with no content (204)
@@ -320,7 +449,49 @@ func (h *MenuHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 }
 
-// GetMenuItemImage serves the image for a specific menu item.
+// RestoreMenuItem puts an archived menu item back on the menu.
+func (h *MenuHandler) RestoreMenuItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.menuService.RestoreMenuItem(id); err != nil {
+		h.logger.Error("Could not restore menu item", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not restore menu item", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetArchivedMenu retrieves the menu items that were archived.
+func (h *MenuHandler) GetArchivedMenu(w http.ResponseWriter, r *http.Request) {
+	MenuItems, err := h.menuService.GetArchivedMenuItems()
+	if err != nil {
+		h.logger.Error("Could not get archived menu items", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not read menu database", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MenuItems); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetMenuItemImage serves the image for a specific menu item. ?size= picks the thumb, medium or
+// large rendition. Adding ?v= with the content hash from the image name makes the response
+// cacheable for good, otherwise clients revalidate with the ETag.
 func (h *MenuHandler) GetMenuItemImage(w http.ResponseWriter, r *http.Request) {
 	// Extract the ID from URL
 	idStr := r.PathValue("id")
@@ -335,5 +506,176 @@ func (h *MenuHandler) GetMenuItemImage(w http.ResponseWriter, r *http.Request) {
 		error_handler.Error(w, "Menu item not found", http.StatusNotFound)
 		return
 	}
-	http.ServeFile(w, r, menuItem.Image)
+
+	object, path, err := h.menuService.GetMenuImage(menuItem.Image, r.URL.Query().Get("size"))
+	if err != nil {
+		h.logger.Error("Could not get image", "error", err, "method", r.Method, "url", r.URL)
+		switch err {
+		case models.ErrInvalidImageSize:
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		case storage.ErrNotFound:
+			error_handler.Error(w, "Image not found", http.StatusNotFound)
+		default:
+			error_handler.Error(w, "Could not get image", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Content-hash names never change their content, so a URL naming the hash can be cached for good.
+	// Without it the image of the item may change, so caches keep it for a short while and then
+	// revalidate it with the ETag.
+	version := service.ImageVersion(menuItem.Image)
+	if version != "" {
+		w.Header().Set("ETag", fmt.Sprintf("%q", version+"-"+filepath.Base(path)))
+	}
+	if version != "" && r.URL.Query().Get("v") == version {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", imageMaxAge))
+	}
+	serveObject(w, r, path, object)
+}
+
+// GetUpload serves an uploaded file from the image storage.
+func (h *MenuHandler) GetUpload(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	object, err := h.menuService.GetUpload(name)
+	if err != nil {
+		if err == storage.ErrNotFound || err == storage.ErrInvalidKey {
+			http.NotFound(w, r)
+			return
+		}
+		h.logger.Error("Could not get upload", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not get upload", http.StatusInternalServerError)
+		return
+	}
+	if service.ImageVersion(name) != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	serveObject(w, r, name, object)
+}
+
+// imageMaxAge is how many seconds an image URL that does not name the image's hash may be cached.
+const imageMaxAge = 300
+
+// serveObject writes a stored object, answering conditional and range requests.
+func serveObject(w http.ResponseWriter, r *http.Request, name string, object storage.Object) {
+	if object.ContentType != "" {
+		w.Header().Set("Content-Type", object.ContentType)
+	}
+	http.ServeContent(w, r, name, object.ModTime, bytes.NewReader(object.Data))
+}
+
+// maxUploadBody caps multipart requests carrying an image, leaving room for the other form fields.
+const maxUploadBody = service.MaxImageSize + 1<<20
+
+// saveUploadedImage stores the "image" file of a parsed multipart form through the image pipeline.
+// It returns "" when no file was uploaded.
+func (h *MenuHandler) saveUploadedImage(r *http.Request) (string, error) {
+	file, header, err := r.FormFile("image")
+	if err == http.ErrMissingFile {
+		return "", nil
+	}
+	if err != nil {
+		return "", models.ErrInvalidImage
+	}
+	defer file.Close()
+
+	if header.Size > service.MaxImageSize {
+		return "", models.ErrImageTooLarge
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return h.menuService.SaveMenuImage(data)
+}
+
+// parseUploadForm parses a multipart form carrying an image and responds with an error if it cannot.
+func (h *MenuHandler) parseUploadForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBody)
+	err := r.ParseMultipartForm(maxUploadBody)
+	if err == nil {
+		return true
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		h.uploadError(w, r, models.ErrImageTooLarge)
+		return false
+	}
+	h.logger.Error("Invalid form data", "error", err, "method", r.Method, "url", r.URL)
+	error_handler.Error(w, "Invalid form data", http.StatusBadRequest)
+	return false
+}
+
+// uploadError responds to a failed image upload.
+func (h *MenuHandler) uploadError(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.Error("Could not save image", "error", err, "method", r.Method, "url", r.URL)
+	switch err {
+	case models.ErrImageTooLarge:
+		error_handler.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case models.ErrInvalidImage:
+		error_handler.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	default:
+		error_handler.Error(w, "Could not save image", http.StatusInternalServerError)
+	}
+}
+
+// EightySixMenuItem takes a menu item off sale with a reason, regardless of stock.
+func (h *MenuHandler) EightySixMenuItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var override models.EightySix
+	if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.menuService.EightySixMenuItem(id, override.Reason); err != nil {
+		h.logger.Error("Could not 86 menu item", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// LiftEightySix puts a menu item that was 86'd back on sale.
+func (h *MenuHandler) LiftEightySix(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.menuService.LiftEightySix(id); err != nil {
+		h.logger.Error("Could not lift 86 of menu item", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not lift 86 of menu item", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// parseMenuTime parses an RFC 3339 time or a local time without a zone, e.g. 2025-01-31T09:30.
+// Schedules are in the shop's local time, so zoned times are converted to it.
+func parseMenuTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(time.Local), nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04", value, time.Local)
 }
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+// maxImportSize limits the size of an uploaded menu import file.
+const maxImportSize = 5 << 20
+
+// GetMenuExport exports the menu as JSON or, with ?format=csv or an Accept: text/csv header, as CSV.
+func (h *MenuHandler) GetMenuExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = "csv"
+	}
+	if format != "" && format != "csv" && format != "json" {
+		h.logger.Error("Invalid export format", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.menuService.ExportMenu()
+	if err != nil {
+		h.logger.Error("Could not export menu", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not export menu", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="menu.csv"`)
+		if err := service.WriteMenuCSV(w, items); err != nil {
+			h.logger.Error("Could not write csv data", "error", err, "method", r.Method, "url", r.URL)
+			return
+		}
+		h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="menu.json"`)
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostMenuImport creates and updates menu items from a CSV (Content-Type: text/csv) or JSON file.
+// With ?dry_run=true the file is only validated. Responds with 422 and the row errors if any row
+// is invalid, in which case nothing is changed.
+func (h *MenuHandler) PostMenuImport(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var result models.MenuImportResult
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		result, err = h.menuService.ImportMenuCSV(r.Body, dryRun)
+	} else {
+		var rows []models.MenuImportItem
+		if err := json.NewDecoder(r.Body).Decode(&rows); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				h.logger.Error("Import file is too large", "method", r.Method, "url", r.URL)
+				error_handler.Error(w, "Import file is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			h.logger.Error("Invalid JSON format", "error", err, "method", r.Method, "url", r.URL)
+			error_handler.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		result, err = h.menuService.ImportMenu(rows, dryRun)
+	}
+	if err != nil {
+		h.logger.Error("Could not import menu", "error", err, "method", r.Method, "url", r.URL)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			error_handler.Error(w, "Import file is too large", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, models.ErrInvalidImportFile):
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not import menu", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(result.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"hot-coffee/internal/error_handler"
+)
+
+// maxPatchSize limits the body of a PATCH request.
+const maxPatchSize = 1 << 20
+
+// readMergePatch reads the JSON Merge Patch in the body of a PATCH request. Bodies that are not
+// application/merge-patch+json or application/json are answered with 415 and ok is false.
+func readMergePatch(w http.ResponseWriter, r *http.Request) (patch []byte, ok bool) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			error_handler.Error(w, "PATCH requests must be application/merge-patch+json", http.StatusUnsupportedMediaType)
+			return nil, false
+		}
+	}
+	defer r.Body.Close()
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			error_handler.Error(w, "The patch is too large", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		error_handler.Error(w, "Could not read request body", http.StatusBadRequest)
+		return nil, false
+	}
+	return patch, true
+}
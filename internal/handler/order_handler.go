@@ -2,10 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"hot-coffee/internal/error_handler"
@@ -45,7 +47,7 @@ func (h *OrderHandler) PostOrder(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		// Validate ingredient availability based on quantity.
-		if err = h.menuService.IngredientsCheckByID(OrderItem.ProductID, OrderItem.Quantity); err != nil {
+		if err = h.menuService.IngredientsCheckByID(OrderItem.ProductID, OrderItem.Quantity, OrderItem.Choices, NewOrder.Channel); err != nil {
 			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 			error_handler.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -53,9 +55,9 @@ func (h *OrderHandler) PostOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add the order using the order service.
-	_, _, err = h.orderService.AddOrder(NewOrder)
+	info, _, err := h.orderService.AddOrder(NewOrder, changeInfo(r))
 	if err != nil {
-		if err.Error() == "something wrong with your requested order" {
+		if err.Error() == "something wrong with your requested order" || err == models.ErrInvalidChannel || errors.Is(err, models.ErrItemUnavailable) || errors.Is(err, models.ErrBundleChoice) || errors.Is(err, models.ErrOutOfStock) || errors.Is(err, models.ErrIncompatibleUnit) {
 			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 			error_handler.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -66,8 +68,11 @@ func (h *OrderHandler) PostOrder(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	// The response names the new order and any ingredients that were substituted
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 }
 
 // GetOrders handles the retrieval of all orders via HTTP GET request.
@@ -119,6 +124,9 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	}
 	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
 	w.Header().Set("Content-Type", "application/json")
+	if setETag(w, r, versionETag(RequestedOrder.Version, "")) {
+		return
+	}
 	w.WriteHeader(200)
 	w.Write(jsonData)
 }
@@ -134,25 +142,86 @@ func (h *OrderHandler) PutOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The update only applies to the version named in If-Match
+	var ok bool
+	if RequestedOrder.Version, ok = ifMatchVersion(w, r); !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+	h.saveOrder(w, r, RequestedOrder)
+}
+
+// PatchOrder changes the customer name, notes or items of an order named in a JSON Merge Patch.
+// The merged order is validated like in PutOrder and saved only if the order has not changed
+// since it was read. Items are replaced as a whole, as merge patches do with arrays.
+func (h *OrderHandler) PatchOrder(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		error_handler.Error(w, "The id should be positive integer", http.StatusBadRequest)
+		h.logger.Error("The id should be positive integer", "method", r.Method, "url", r.URL)
+		return
+	}
+
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		h.logger.Error("Could not read merge patch", "method", r.Method, "url", r.URL)
+		return
+	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
+
+	RequestedOrder, err := h.orderService.PatchOrder(ID, patch, version)
+	if err != nil {
+		h.logger.Error("Could not apply merge patch", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case err == models.ErrOrderNotFound:
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case err == models.ErrVersionConflict:
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case errors.Is(err, models.ErrInvalidPatch):
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not apply merge patch", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.saveOrder(w, r, RequestedOrder)
+}
+
+// saveOrder validates the items of an edited order and saves it.
+func (h *OrderHandler) saveOrder(w http.ResponseWriter, r *http.Request, RequestedOrder models.Order) {
 	// Validate each item in the updated order.
 	for _, OrderItem := range RequestedOrder.Items {
 		// Check if the product exists in the menu.
-		if err = h.menuService.MenuCheckByID(OrderItem.ProductID, true); err != nil {
+		if err := h.menuService.MenuCheckByID(OrderItem.ProductID, true); err != nil {
 			h.logger.Error("Updated order item does not exist in menu", "error", err, "method", r.Method, "url", r.URL)
 			error_handler.Error(w, "Updated order item does not exist in menu", http.StatusBadRequest)
 			return
 		}
 		// Validate ingredient availability based on quantity.
-		if err = h.menuService.IngredientsCheckByID(OrderItem.ProductID, OrderItem.Quantity); err != nil {
+		if err := h.menuService.IngredientsCheckByID(OrderItem.ProductID, OrderItem.Quantity, OrderItem.Choices, RequestedOrder.Channel); err != nil {
 			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 			error_handler.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
 	// Update the order in the service.
-	err = h.orderService.UpdateOrder(RequestedOrder, r.PathValue("id"))
+	err := h.orderService.UpdateOrder(RequestedOrder, r.PathValue("id"), changeInfo(r))
 	if err != nil {
-		if err.Error() == "could not update the order because it is already closed" || err.Error() == "something wrong with your updated order" || err.Error() == "the requested order does not exist" {
+		if err == models.ErrVersionConflict {
+			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		if err == models.ErrOrderNotFound {
+			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err.Error() == "could not update the order because it is already closed" || err.Error() == "something wrong with your updated order" || err.Error() == "the requested order does not exist" || err == models.ErrOrderClosed || err == models.ErrOrderHasPaidChecks || errors.Is(err, models.ErrItemUnavailable) || errors.Is(err, models.ErrBundleChoice) {
 			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 			error_handler.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -174,9 +243,19 @@ func (h *OrderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("The id should be positive integer", "method", r.Method, "url", r.URL)
 		return
 	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		h.logger.Error("If-Match does not name a version", "method", r.Method, "url", r.URL)
+		return
+	}
 	// Delete the order by ID using the order service.
-	err = h.orderService.DeleteOrderByID(ID)
+	err = h.orderService.DeleteOrderByID(ID, version, changeInfo(r))
 	if err != nil {
+		if err == models.ErrVersionConflict {
+			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
+			error_handler.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
 		if err.Error() == "order not found" {
 			h.logger.Error(err.Error(), "error", err, "method", r.Method, "url", r.URL)
 			error_handler.Error(w, err.Error(), http.StatusNotFound)
@@ -202,7 +281,7 @@ func (h *OrderHandler) CloseOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Close the order using the order service.
-	err = h.orderService.CloseOrder(ID)
+	err = h.orderService.CloseOrder(ID, changeInfo(r))
 	if err != nil {
 		h.logger.Error("Error closing order", "error", err, "method", r.Method, "url", r.URL)
 		error_handler.Error(w, err.Error(), http.StatusBadRequest)
@@ -250,7 +329,7 @@ func (h *OrderHandler) BatchOrders(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process the batch of orders using the order service.
-	ordersReport, err := h.orderService.BulkOrders(request.Orders)
+	ordersReport, err := h.orderService.BulkOrders(request.Orders, changeInfo(r))
 	if err != nil {
 		h.logger.Error("Error processing orders", "error", err, "method", r.Method, "url", r.URL)
 		error_handler.Error(w, "Error processing orders. "+err.Error(), http.StatusBadRequest)
@@ -263,3 +342,187 @@ func (h *OrderHandler) BatchOrders(w http.ResponseWriter, r *http.Request) {
 		error_handler.Error(w, "Error encoding response", http.StatusInternalServerError)
 	}
 }
+
+// SplitOrder handles splitting an order into separate checks by item, by seat or evenly.
+func (h *OrderHandler) SplitOrder(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Order id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Order id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var request models.SplitRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error("Could not decode request json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	checks, err := h.orderService.SplitOrder(ID, request, changeInfo(r))
+	if err != nil {
+		h.logger.Error("Error splitting order", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrOrderNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(checks); err != nil {
+		h.logger.Error("Error encoding response", "error", err, "method", r.Method, "url", r.URL)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// CloseCheck handles paying and closing a single check of a split order.
+func (h *OrderHandler) CloseCheck(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Order id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Order id must be integer", http.StatusBadRequest)
+		return
+	}
+	CheckID, err := strconv.Atoi(r.PathValue("check_id"))
+	if err != nil {
+		h.logger.Error("Check id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Check id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	orderClosed, err := h.orderService.CloseCheck(ID, CheckID, changeInfo(r))
+	if err != nil {
+		h.logger.Error("Error closing check", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrCheckNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err == models.ErrCheckPaid {
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		error_handler.Error(w, "Error closing check", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"order_closed": orderClosed})
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetOrderHistory handles the retrieval of the change history of an order.
+func (h *OrderHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Order id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Order id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.orderService.GetOrderHistory(ID)
+	if err != nil {
+		h.logger.Error("Error getting order history", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrOrderNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Error getting order history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		h.logger.Error("Error encoding response", "error", err, "method", r.Method, "url", r.URL)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// AdvanceOrderItem moves a single order line to its next fulfillment status.
+func (h *OrderHandler) AdvanceOrderItem(w http.ResponseWriter, r *http.Request) {
+	ID, LineID, ok := h.orderLineIDs(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := h.orderService.AdvanceOrderItem(ID, LineID, changeInfo(r))
+	if err != nil {
+		h.logger.Error("Error advancing order item", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), orderItemErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// SetOrderItemStatus sets the fulfillment status of a single order line.
+func (h *OrderHandler) SetOrderItemStatus(w http.ResponseWriter, r *http.Request) {
+	ID, LineID, ok := h.orderLineIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var update models.ItemStatusUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.orderService.SetOrderItemStatus(ID, LineID, update.Status, changeInfo(r))
+	if err != nil {
+		h.logger.Error("Error updating order item status", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), orderItemErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// orderLineIDs parses the order and line IDs from the path, writing an error response if either is invalid.
+func (h *OrderHandler) orderLineIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	ID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Order id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Order id must be integer", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	LineID, err := strconv.Atoi(r.PathValue("line_id"))
+	if err != nil {
+		h.logger.Error("Line id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Line id must be integer", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return ID, LineID, true
+}
+
+// orderItemErrorStatus maps order line errors to HTTP status codes.
+func orderItemErrorStatus(err error) int {
+	switch err {
+	case models.ErrLineNotFound:
+		return http.StatusNotFound
+	case models.ErrLineServed, models.ErrInvalidStatus, models.ErrOrderClosed:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// changeInfo reads who is making a change and why from the X-Actor and X-Change-Reason headers.
+func changeInfo(r *http.Request) models.ChangeInfo {
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if actor == "" {
+		actor = "unknown"
+	}
+	return models.ChangeInfo{
+		Actor:  actor,
+		Reason: strings.TrimSpace(r.Header.Get("X-Change-Reason")),
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+// PriceHandler handles HTTP requests for the price log and scheduled price changes.
+type PriceHandler struct {
+	priceService *service.PriceService
+	logger       *slog.Logger
+}
+
+// NewPriceHandler creates a new PriceHandler instance.
+func NewPriceHandler(priceService *service.PriceService, logger *slog.Logger) *PriceHandler {
+	return &PriceHandler{priceService: priceService, logger: logger}
+}
+
+// GetItemPriceHistory retrieves the price changes of a single menu item.
+func (h *PriceHandler) GetItemPriceHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.priceService.GetItemPriceHistory(id)
+	if err != nil {
+		h.logger.Error("Could not get price history", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not get price history", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, r, history)
+}
+
+// GetPriceHistory retrieves the store-wide price change log. Accepts ?from= and ?to= as dates or RFC 3339 times.
+func (h *PriceHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	from, err := parseDateParam(r.URL.Query().Get("from"), false)
+	if err != nil {
+		h.logger.Error("Invalid from value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "from must be a date like 2025-01-31", http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(r.URL.Query().Get("to"), true)
+	if err != nil {
+		h.logger.Error("Invalid to value", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "to must be a date like 2025-01-31", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.priceService.GetPriceHistory(from, to)
+	if err != nil {
+		h.logger.Error("Could not get price history", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeJSON(w, r, history)
+}
+
+// PostPriceChange schedules a price change for a future date.
+func (h *PriceHandler) PostPriceChange(w http.ResponseWriter, r *http.Request) {
+	var change models.ScheduledPriceChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.priceService.SchedulePriceChange(change)
+	if err != nil {
+		h.logger.Error("Could not schedule price change", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.OrderID{ID: id})
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetPriceChanges retrieves scheduled price changes. Accepts ?status=.
+func (h *PriceHandler) GetPriceChanges(w http.ResponseWriter, r *http.Request) {
+	changes, err := h.priceService.GetScheduledPriceChanges(r.URL.Query().Get("status"))
+	if err != nil {
+		h.logger.Error("Could not get scheduled price changes", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeJSON(w, r, changes)
+}
+
+// DeletePriceChange cancels a scheduled price change that has not been applied yet.
+func (h *PriceHandler) DeletePriceChange(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Price change id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Price change id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.priceService.CancelScheduledPriceChange(id); err != nil {
+		h.logger.Error("Could not cancel price change", "error", err, "method", r.Method, "url", r.URL)
+		switch err {
+		case models.ErrPriceChangeNotFound:
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case models.ErrPriceChangeDone:
+			error_handler.Error(w, err.Error(), http.StatusConflict)
+		default:
+			error_handler.Error(w, "Could not cancel price change", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+func (h *PriceHandler) writeJSON(w http.ResponseWriter, r *http.Request, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// parseDateParam parses a YYYY-MM-DD date or an RFC 3339 time. An empty value gives the zero time.
+// With endOfDay a plain date covers the whole day.
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/models"
+)
+
+// GetRecipeVersions retrieves every version of a menu item's recipe.
+func (h *MenuHandler) GetRecipeVersions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := h.menuService.GetRecipeVersions(id)
+	if err != nil {
+		h.logger.Error("Could not get recipe versions", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not get recipe versions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(versions); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostRecipeVersion adds a new version of a menu item's recipe, optionally effective from a later date.
+func (h *MenuHandler) PostRecipeVersion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var version models.RecipeVersion
+	if err := json.NewDecoder(r.Body).Decode(&version); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+	version.ProductID = id
+
+	number, err := h.menuService.AddRecipeVersion(version)
+	if err != nil {
+		h.logger.Error("Could not add recipe version", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"version": number})
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// CompareRecipeVersions shows the ingredient differences between two recipe versions.
+// Accepts ?from= and ?to= version numbers; by default the version in effect is compared to the one before it.
+func (h *MenuHandler) CompareRecipeVersions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	versions := make(map[string]int)
+	for _, key := range []string{"from", "to"} {
+		value := r.URL.Query().Get(key)
+		if value == "" {
+			continue
+		}
+		if versions[key], err = strconv.Atoi(value); err != nil {
+			h.logger.Error("Recipe version must be integer", "error", err, "method", r.Method, "url", r.URL)
+			error_handler.Error(w, key+" must be a version number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	comparison, err := h.menuService.CompareRecipeVersions(id, versions["from"], versions["to"])
+	if err != nil {
+		h.logger.Error("Could not compare recipe versions", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound || err == models.ErrRecipeNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not compare recipe versions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(comparison); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/models"
+)
+
+// Defaults and bounds of the recommendation query parameters.
+const (
+	defaultRecommendationLimit = 5
+	maxRecommendationLimit     = 50
+)
+
+// GetMenuItemRecommendations retrieves the menu items most often bought together with a menu item.
+// Accepts ?rank=lift|confidence, ?min_orders= and ?limit=.
+func (h *MenuHandler) GetMenuItemRecommendations(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+	rank, minOrders, limit, err := recommendationParams(r)
+	if err != nil {
+		h.logger.Error("Invalid recommendation parameters", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := negotiateLocale(r)
+	if err != nil {
+		h.logger.Error("Unsupported locale", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recommendations, err := h.menuService.GetRecommendations(id, rank, minOrders, limit, locale)
+	h.writeRecommendations(w, r, recommendations, locale, err)
+}
+
+// PostBasketRecommendations retrieves the menu items most often bought together with the items
+// of a cart. Takes the same query parameters as GetMenuItemRecommendations.
+func (h *MenuHandler) PostBasketRecommendations(w http.ResponseWriter, r *http.Request) {
+	var basket models.BasketRecommendationRequest
+	if err := json.NewDecoder(r.Body).Decode(&basket); err != nil {
+		h.logger.Error("Invalid JSON format", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	rank, minOrders, limit, err := recommendationParams(r)
+	if err != nil {
+		h.logger.Error("Invalid recommendation parameters", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := negotiateLocale(r)
+	if err != nil {
+		h.logger.Error("Unsupported locale", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recommendations, err := h.menuService.GetBasketRecommendations(basket.Items, rank, minOrders, limit, locale)
+	h.writeRecommendations(w, r, recommendations, locale, err)
+}
+
+// writeRecommendations answers a recommendation request with its result or error.
+func (h *MenuHandler) writeRecommendations(w http.ResponseWriter, r *http.Request, recommendations []models.Recommendation, locale string, err error) {
+	if err != nil {
+		h.logger.Error("Could not get recommendations", "error", err, "method", r.Method, "url", r.URL)
+		switch err {
+		case models.ErrMenuItemNotFound:
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case models.ErrInvalidRanking, models.ErrEmptyBasket:
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not get recommendations", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	setLocaleHeaders(w, locale)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(recommendations); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// recommendationParams reads the ranking, minimum number of shared orders and limit of a
+// recommendation request, falling back to ranking by lift, one order and five items.
+func recommendationParams(r *http.Request) (rank string, minOrders, limit int, err error) {
+	query := r.URL.Query()
+	rank = query.Get("rank")
+	if rank == "" {
+		rank = models.RankByLift
+	}
+	if rank != models.RankByLift && rank != models.RankByConfidence {
+		return "", 0, 0, models.ErrInvalidRanking
+	}
+
+	minOrders, limit = 1, defaultRecommendationLimit
+	if value := query.Get("min_orders"); value != "" {
+		if minOrders, err = strconv.Atoi(value); err != nil || minOrders < 1 {
+			return "", 0, 0, errors.New("min_orders must be a positive integer")
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxRecommendationLimit {
+			return "", 0, 0, errors.New("limit must be an integer between 1 and 50")
+		}
+	}
+	return rank, minOrders, limit, nil
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestRecommendationParams(t *testing.T) {
+	tests := []struct {
+		query     string
+		rank      string
+		minOrders int
+		limit     int
+		wantErr   bool
+	}{
+		{"", models.RankByLift, 1, defaultRecommendationLimit, false},
+		{"rank=confidence", models.RankByConfidence, 1, defaultRecommendationLimit, false},
+		{"rank=lift&min_orders=3&limit=10", models.RankByLift, 3, 10, false},
+		{"limit=50", models.RankByLift, 1, 50, false},
+		{"rank=support", "", 0, 0, true},
+		{"min_orders=0", "", 0, 0, true},
+		{"min_orders=two", "", 0, 0, true},
+		{"limit=0", "", 0, 0, true},
+		{"limit=51", "", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/menu/1/recommendations?"+tt.query, nil)
+			rank, minOrders, limit, err := recommendationParams(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if rank != tt.rank || minOrders != tt.minOrders || limit != tt.limit {
+				t.Errorf("got %q, %d, %d, want %q, %d, %d", rank, minOrders, limit, tt.rank, tt.minOrders, tt.limit)
+			}
+		})
+	}
+}
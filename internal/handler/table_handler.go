@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+// TableHandler handles HTTP requests related to dine-in tables.
+type TableHandler struct {
+	tableService *service.TableService
+	logger       *slog.Logger
+}
+
+// NewTableHandler creates a new TableHandler instance.
+func NewTableHandler(tableService *service.TableService, logger *slog.Logger) *TableHandler {
+	return &TableHandler{tableService: tableService, logger: logger}
+}
+
+// PostTable handles creating a new table.
+func (h *TableHandler) PostTable(w http.ResponseWriter, r *http.Request) {
+	var newTable models.Table
+	if err := json.NewDecoder(r.Body).Decode(&newTable); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tableService.AddTable(newTable); err != nil {
+		h.logger.Error("Could not add new table", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// GetTables retrieves all tables with their occupancy and running totals.
+func (h *TableHandler) GetTables(w http.ResponseWriter, r *http.Request) {
+	tables, err := h.tableService.GetTables()
+	if err != nil {
+		h.logger.Error("Could not get tables", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not get tables", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tables); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// AssignOrder seats an order at a table or moves it to another one.
+func (h *TableHandler) AssignOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Order id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Order id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var assignment models.TableAssignment
+	if err := json.NewDecoder(r.Body).Decode(&assignment); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tableService.AssignOrder(orderID, assignment.TableID, changeInfo(r)); err != nil {
+		h.logger.Error("Could not assign order to table", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), tableErrorStatus(err))
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// MergeTables merges the open orders of another table into a single check at this table.
+func (h *TableHandler) MergeTables(w http.ResponseWriter, r *http.Request) {
+	tableID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Table id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Table id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var source models.TableAssignment
+	if err := json.NewDecoder(r.Body).Decode(&source); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	checkID, err := h.tableService.MergeTables(tableID, source.TableID, changeInfo(r))
+	if err != nil {
+		h.logger.Error("Could not merge tables", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), tableErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.OrderID{ID: checkID})
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// CloseTable closes all open orders of a table together.
+func (h *TableHandler) CloseTable(w http.ResponseWriter, r *http.Request) {
+	tableID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Table id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Table id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	closed, err := h.tableService.CloseTable(tableID, changeInfo(r))
+	if err != nil {
+		h.logger.Error("Could not close table", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, err.Error(), tableErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]int{"closed_orders": closed})
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// tableErrorStatus maps table errors to HTTP status codes.
+func tableErrorStatus(err error) int {
+	switch err {
+	case models.ErrTableNotFound, models.ErrOrderNotFound:
+		return http.StatusNotFound
+	case models.ErrOrderClosed, models.ErrTableNoOrders, models.ErrOrderNotDineIn,
+		models.ErrOrderHasPaidChecks, models.ErrOrderUnpaidChecks, models.ErrMergePriceConflict:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
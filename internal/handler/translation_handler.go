@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+// GetMenuItemTranslations retrieves every translation of a menu item.
+func (h *MenuHandler) GetMenuItemTranslations(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	translations, err := h.menuService.GetMenuItemTranslations(id)
+	if err != nil {
+		h.logger.Error("Could not get translations", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not get translations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(translations); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PutMenuItemTranslation adds or replaces the name and description of a menu item in a locale.
+func (h *MenuHandler) PutMenuItemTranslation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var translation models.MenuItemTranslation
+	if err := json.NewDecoder(r.Body).Decode(&translation); err != nil {
+		h.logger.Error("Invalid JSON format", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	translation.ProductID = id
+	translation.Locale = strings.ToLower(r.PathValue("locale"))
+
+	if err := h.menuService.SaveTranslation(translation); err != nil {
+		h.logger.Error("Could not save translation", "error", err, "method", r.Method, "url", r.URL)
+		if err == models.ErrMenuItemNotFound {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// DeleteMenuItemTranslation removes the translation of a menu item into a locale.
+func (h *MenuHandler) DeleteMenuItemTranslation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Menu id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Menu id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.menuService.DeleteTranslation(id, strings.ToLower(r.PathValue("locale"))); err != nil {
+		h.logger.Error("Could not delete translation", "error", err, "method", r.Method, "url", r.URL)
+		switch err {
+		case models.ErrMenuItemNotFound, models.ErrTranslationNotFound:
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			error_handler.Error(w, "Could not delete translation", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// negotiateLocale picks the language of menu content: ?lang= when given, otherwise the supported
+// language the Accept-Language header prefers most, otherwise the default locale.
+func negotiateLocale(r *http.Request) (string, error) {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		lang = strings.ToLower(lang)
+		if !service.IsSupportedLocale(lang) {
+			return "", models.ErrUnsupportedLocale
+		}
+		return lang, nil
+	}
+
+	best, bestWeight := models.DefaultLocale, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		// Regional variants such as de-AT fall back to their language
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if weight > bestWeight && service.IsSupportedLocale(lang) {
+			best, bestWeight = lang, weight
+		}
+	}
+	return best, nil
+}
+
+// setLocaleHeaders tells clients and caches which language a response is in.
+func setLocaleHeaders(w http.ResponseWriter, locale string) {
+	w.Header().Set("Content-Language", locale)
+	w.Header().Add("Vary", "Accept-Language")
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"hot-coffee/internal/error_handler"
+	"hot-coffee/models"
+)
+
+// GetUnits lists every unit of measure inventory items and recipes can be counted in.
+func (h *InventoryHandler) GetUnits(w http.ResponseWriter, r *http.Request) {
+	units, err := h.inventoryService.GetUnits()
+	if err != nil {
+		h.logger.Error("Could not get units", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not get units", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(units); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PostUnit adds a unit of measure.
+func (h *InventoryHandler) PostUnit(w http.ResponseWriter, r *http.Request) {
+	var unit models.Unit
+	if err := json.NewDecoder(r.Body).Decode(&unit); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.inventoryService.AddUnit(unit); err != nil {
+		h.logger.Error("Could not add unit", "error", err, "method", r.Method, "url", r.URL)
+		switch err {
+		case models.ErrInvalidUnit:
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		case models.ErrUnitCodeTaken:
+			error_handler.Error(w, err.Error(), http.StatusConflict)
+		default:
+			error_handler.Error(w, "Could not add unit", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// GetInventoryPackUnits lists the pack units of an inventory item.
+func (h *InventoryHandler) GetInventoryPackUnits(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Inventory id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Inventory id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	packs, err := h.inventoryService.GetPackUnits(id)
+	if err != nil {
+		h.logger.Error("Could not get pack units", "error", err, "method", r.Method, "url", r.URL)
+		if err.Error() == "inventory item does not exist" {
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		error_handler.Error(w, "Could not get pack units", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(packs); err != nil {
+		h.logger.Error("Could not encode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not encode json data", http.StatusInternalServerError)
+		return
+	}
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
+
+// PutInventoryPackUnits replaces the pack units of an inventory item with the list in the body.
+func (h *InventoryHandler) PutInventoryPackUnits(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.logger.Error("Inventory id must be integer", "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Inventory id must be integer", http.StatusBadRequest)
+		return
+	}
+
+	var packs []models.PackUnit
+	if err := json.NewDecoder(r.Body).Decode(&packs); err != nil {
+		h.logger.Error("Could not decode json data", "error", err, "method", r.Method, "url", r.URL)
+		error_handler.Error(w, "Could not decode request json data", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.inventoryService.SavePackUnits(id, packs); err != nil {
+		h.logger.Error("Could not save pack units", "error", err, "method", r.Method, "url", r.URL)
+		switch {
+		case err.Error() == "inventory item does not exist":
+			error_handler.Error(w, err.Error(), http.StatusNotFound)
+		case err == models.ErrInvalidPackUnit, err == models.ErrIncompatibleUnit:
+			error_handler.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			error_handler.Error(w, "Could not save pack units", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	h.logger.Info("Request handled successfully.", "method", r.Method, "url", r.URL)
+}
@@ -6,20 +6,20 @@ import (
 	"hot-coffee/internal/handler"
 	"hot-coffee/internal/html_handler"
 	"hot-coffee/internal/service"
+	"hot-coffee/internal/storage"
 	"log"
 	"log/slog"
 	"net/http"
+	"time"
 )
 
-func ServerLaunch(db *sql.DB, logger *slog.Logger) {
+func ServerLaunch(db *sql.DB, images storage.Storage, logger *slog.Logger) {
 	mux := http.NewServeMux()
 
 	fs := http.FileServer(http.Dir("./web"))
-	fs2 := http.FileServer(http.Dir("./uploads"))
 
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 	mux.Handle("/pictures", http.StripPrefix("/pictures", fs))
-	mux.Handle("/uploads/", http.StripPrefix("/uploads/", fs2))
 	mux.HandleFunc("/", html_handler.ServeHome)
 	mux.HandleFunc("/admin", html_handler.ServeAdmin)
 	mux.HandleFunc("/admin/menu", html_handler.ServeMenu)
@@ -36,23 +36,75 @@ func ServerLaunch(db *sql.DB, logger *slog.Logger) {
 	mux.HandleFunc("GET /inventory", inventoryHandler.GetInventory)
 	mux.HandleFunc("GET /inventory/{id}", inventoryHandler.GetInventoryItem)
 	mux.HandleFunc("PUT /inventory/{id}", inventoryHandler.PutInventoryItem)
+	mux.HandleFunc("PATCH /inventory/{id}", inventoryHandler.PatchInventoryItem)
+	mux.HandleFunc("POST /inventory/{id}/restock", inventoryHandler.RestockInventoryItem)
+	mux.HandleFunc("GET /inventory/{id}/substitutes", inventoryHandler.GetInventorySubstitutes)
+	mux.HandleFunc("PUT /inventory/{id}/substitutes", inventoryHandler.PutInventorySubstitutes)
+	mux.HandleFunc("GET /inventory/{id}/units", inventoryHandler.GetInventoryPackUnits)
+	mux.HandleFunc("PUT /inventory/{id}/units", inventoryHandler.PutInventoryPackUnits)
+	mux.HandleFunc("GET /units", inventoryHandler.GetUnits)
+	mux.HandleFunc("POST /units", inventoryHandler.PostUnit)
 	mux.HandleFunc("DELETE /inventory/{id}", inventoryHandler.DeleteInventoryItem)
 	mux.HandleFunc("GET /inventory/getLeftOvers", inventoryHandler.GetLeftOvers)
 
 	// - - - - - - - - - - - - - - MENU - - - - - - - - - - - - - -
 
 	menuRepo := dal.NewMenuRepository(db)
-	menuService := service.NewMenuService(*menuRepo, *inventoryRepo)
+	menuService := service.NewMenuService(*menuRepo, *inventoryRepo, images)
 	menuHandler := handler.NewMenuHandler(menuService, logger)
 
+	// Uploaded images are served from the image storage so every instance sees the same files
+	mux.HandleFunc("GET /uploads/{name}", menuHandler.GetUpload)
 	mux.HandleFunc("POST /menu", menuHandler.PostMenu)
 	mux.HandleFunc("GET /menu", menuHandler.GetMenu)
+	mux.HandleFunc("GET /menu/archived", menuHandler.GetArchivedMenu)
+	mux.HandleFunc("GET /menu/export", menuHandler.GetMenuExport)
+	mux.HandleFunc("POST /menu/import", menuHandler.PostMenuImport)
 	mux.HandleFunc("GET /menu/{id}", menuHandler.GetMenuItem)
 	mux.HandleFunc("GET /menu/{id}/image", menuHandler.GetMenuItemImage)
 	mux.HandleFunc("PUT /menu/{id}", menuHandler.PutMenuItem)
+	mux.HandleFunc("PATCH /menu/{id}", menuHandler.PatchMenuItem)
 	mux.HandleFunc("PUT /menu/{id}/image", menuHandler.PutMenuItemImage)
 	mux.HandleFunc("DELETE /menu/{id}", menuHandler.DeleteMenuItem)
+	mux.HandleFunc("POST /menu/{id}/restore", menuHandler.RestoreMenuItem)
 	mux.HandleFunc("DELETE /menu/{id}/image", menuHandler.DeleteMenuItemImage)
+	mux.HandleFunc("POST /menu/{id}/86", menuHandler.EightySixMenuItem)
+	mux.HandleFunc("DELETE /menu/{id}/86", menuHandler.LiftEightySix)
+	mux.HandleFunc("GET /menu/{id}/translations", menuHandler.GetMenuItemTranslations)
+	mux.HandleFunc("PUT /menu/{id}/translations/{locale}", menuHandler.PutMenuItemTranslation)
+	mux.HandleFunc("DELETE /menu/{id}/translations/{locale}", menuHandler.DeleteMenuItemTranslation)
+	mux.HandleFunc("GET /menu/{id}/recipes", menuHandler.GetRecipeVersions)
+	mux.HandleFunc("POST /menu/{id}/recipes", menuHandler.PostRecipeVersion)
+	mux.HandleFunc("GET /menu/{id}/recipes/compare", menuHandler.CompareRecipeVersions)
+	mux.HandleFunc("GET /menu/{id}/costing", menuHandler.GetMenuItemCosting)
+	mux.HandleFunc("GET /menu/{id}/recommendations", menuHandler.GetMenuItemRecommendations)
+	mux.HandleFunc("POST /menu/recommendations", menuHandler.PostBasketRecommendations)
+	mux.HandleFunc("GET /reports/margins", menuHandler.GetMarginReport)
+	mux.HandleFunc("GET /categories", menuHandler.GetCategories)
+	mux.HandleFunc("POST /categories", menuHandler.PostCategory)
+	mux.HandleFunc("PUT /categories/{id}", menuHandler.PutCategory)
+	mux.HandleFunc("DELETE /categories/{id}", menuHandler.DeleteCategory)
+
+	// Recipe versions scheduled for a later date are put into effect by a background job
+	menuService.StartRecipeScheduler(time.Minute, logger)
+
+	// Recommendations are served from figures a background job recomputes every 15 minutes
+	menuService.StartRecommendationRefresher(15*time.Minute, logger)
+
+	// - - - - - - - - - - - - - - PRICES - - - - - - - - - - - - - -
+
+	priceRepo := dal.NewPriceRepository(db)
+	priceService := service.NewPriceService(*priceRepo, *menuRepo)
+	priceHandler := handler.NewPriceHandler(priceService, logger)
+
+	mux.HandleFunc("GET /menu/{id}/price-history", priceHandler.GetItemPriceHistory)
+	mux.HandleFunc("GET /price-history", priceHandler.GetPriceHistory)
+	mux.HandleFunc("POST /price-changes", priceHandler.PostPriceChange)
+	mux.HandleFunc("GET /price-changes", priceHandler.GetPriceChanges)
+	mux.HandleFunc("DELETE /price-changes/{id}", priceHandler.DeletePriceChange)
+
+	// Scheduled price changes are applied by a background job
+	priceService.StartPriceScheduler(time.Minute, logger)
 
 	// - - - - - - - - - - - - - - ORDER - - - - - - - - - - - - - -
 
@@ -64,18 +116,40 @@ func ServerLaunch(db *sql.DB, logger *slog.Logger) {
 	mux.HandleFunc("GET /orders", orderHandler.GetOrders)
 	mux.HandleFunc("GET /orders/{id}", orderHandler.GetOrder)
 	mux.HandleFunc("PUT /orders/{id}", orderHandler.PutOrder)
+	mux.HandleFunc("PATCH /orders/{id}", orderHandler.PatchOrder)
 	mux.HandleFunc("DELETE /orders/{id}", orderHandler.DeleteOrder)
 	mux.HandleFunc("POST /orders/{id}/close", orderHandler.CloseOrder)
 	mux.HandleFunc("GET /orders/numberOfOrderedItems", orderHandler.GetNumberOfOrdered)
 	mux.HandleFunc("POST /orders/batch-process", orderHandler.BatchOrders)
+	mux.HandleFunc("GET /orders/{id}/history", orderHandler.GetOrderHistory)
+	mux.HandleFunc("POST /orders/{id}/split", orderHandler.SplitOrder)
+	mux.HandleFunc("POST /orders/{id}/checks/{check_id}/close", orderHandler.CloseCheck)
+	mux.HandleFunc("POST /orders/{id}/items/{line_id}/advance", orderHandler.AdvanceOrderItem)
+	mux.HandleFunc("PUT /orders/{id}/items/{line_id}/status", orderHandler.SetOrderItemStatus)
+
+	// - - - - - - - - - - - - - - TABLES - - - - - - - - - - - - - -
+
+	tableRepo := dal.NewTableRepository(db)
+	tableService := service.NewTableService(*tableRepo)
+	tableHandler := handler.NewTableHandler(tableService, logger)
+
+	mux.HandleFunc("POST /tables", tableHandler.PostTable)
+	mux.HandleFunc("GET /tables", tableHandler.GetTables)
+	mux.HandleFunc("POST /tables/{id}/merge", tableHandler.MergeTables)
+	mux.HandleFunc("POST /tables/{id}/close", tableHandler.CloseTable)
+	mux.HandleFunc("PUT /orders/{id}/table", tableHandler.AssignOrder)
 
 	// - - - - - - - - - - - - - - REPORT - - - - - - - - - - - - - -
 	aggregationRepo := dal.NewReportRespository(db)
-	aggregationService := service.NewAggregationService(aggregationRepo)
+	aggregationService := service.NewAggregationService(aggregationRepo, menuService)
 	reportHandler := handler.NewAggregationHandler(orderService, aggregationService, logger)
 
 	mux.HandleFunc("GET /reports/total-sales", reportHandler.TotalSalesHandler)
 	mux.HandleFunc("GET /reports/popular-items", reportHandler.PopularItemsHandler)
+	mux.HandleFunc("GET /reports/revenue", reportHandler.RevenueHandler)
+	mux.HandleFunc("GET /reports/ingredient-usage", reportHandler.IngredientUsageHandler)
+	mux.HandleFunc("GET /reports/inventory-valuation", reportHandler.InventoryValuationHandler)
+	mux.HandleFunc("GET /reports/menu-engineering", reportHandler.MenuEngineeringHandler)
 	mux.HandleFunc("GET /reports/orderedItemsByPeriod", reportHandler.OrderByPeriod)
 	mux.HandleFunc("GET /reports/search", reportHandler.SearchHandler)
 
@@ -3,6 +3,7 @@ package service
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/models"
@@ -13,39 +14,132 @@ var (
 	ErrWrongFilterOptions = errors.New("no such filter. Available filters: orders, menu, all")
 	ErrSearchRequired     = errors.New("search query string is required")
 	ErrPriceNotPositive   = errors.New("minPrice and maxPrice must be positive")
+	ErrWrongGroupBy       = errors.New("no such grouping. Available groupings: category")
+	ErrWrongPeriod        = errors.New("to must not be before from")
 )
 
 // AggregationService defines the interface for aggregation-related operations.
 type AggregationService interface {
-	// GetPopularMenuItems retrieves popular menu items.
-	GetPopularMenuItems() (models.PopularItems, error)
-	// Search allows searching menu items, orders, or both with filters.
-	Search(searchQuery string, minPrice, maxPrice int, filter string) (models.SearchResult, error)
+	// GetPopularMenuItems retrieves popular menu items, optionally for a single channel
+	// and grouped by category.
+	GetPopularMenuItems(channel, groupBy string) (models.PopularItems, error)
+	// GetRevenueByItem retrieves the revenue per menu item, with bundle revenue split across components.
+	GetRevenueByItem(channel string) (models.RevenueReport, error)
+	// GetIngredientUsage retrieves the ingredients consumed by the orders placed in a period.
+	GetIngredientUsage(from, to time.Time) (models.IngredientUsageReport, error)
+	// GetInventoryValuation values the stock of every ingredient at a point in time.
+	GetInventoryValuation(at time.Time) (models.InventoryValuationReport, error)
+	// GetMenuEngineering classifies the menu items by popularity and contribution margin over a period.
+	GetMenuEngineering(channel string, from, to time.Time) (models.MenuEngineeringReport, error)
+	// Search allows searching menu items, orders, or both with filters. Menu items are searched in locale.
+	Search(searchQuery string, minPrice, maxPrice int, filter, locale string) (models.SearchResult, error)
 }
 
 // AggregationServiceImpl implements the AggregationService interface.
 type AggregationServiceImpl struct {
-	searchRepo dal.ReportRespository // Repository for accessing report-related data.
+	searchRepo  dal.ReportRespository // Repository for accessing report-related data.
+	menuService *MenuService          // Costs menu items for the menu engineering report.
 }
 
 // NewAggregationService creates and returns a new instance of AggregationServiceImpl.
-func NewAggregationService(searchRepo dal.ReportRespository) *AggregationServiceImpl {
-	return &AggregationServiceImpl{searchRepo: searchRepo}
+func NewAggregationService(searchRepo dal.ReportRespository, menuService *MenuService) *AggregationServiceImpl {
+	return &AggregationServiceImpl{searchRepo: searchRepo, menuService: menuService}
 }
 
 // GetPopularMenuItems retrieves the most popular menu items.
-func (s *AggregationServiceImpl) GetPopularMenuItems() (models.PopularItems, error) {
+func (s *AggregationServiceImpl) GetPopularMenuItems(channel, groupBy string) (models.PopularItems, error) {
+	// Validate the channel filter and grouping if they were given.
+	if channel != "" && !isValidChannel(channel) {
+		return models.PopularItems{}, models.ErrInvalidChannel
+	}
+	if groupBy != "" && groupBy != "category" {
+		return models.PopularItems{}, ErrWrongGroupBy
+	}
 	// Fetch popular menu items from the repository.
-	popItms, err := s.searchRepo.GetPopularMenuItems()
+	popItms, err := s.searchRepo.GetPopularMenuItems(channel, time.Time{}, time.Time{})
+	if err != nil {
+		return models.PopularItems{}, err
+	}
 	// Return a result struct with the popular items.
 	res := models.PopularItems{
-		Items: popItms,
+		Channel: channel,
+		Items:   popItms,
+	}
+	if groupBy == "category" {
+		res.Categories, err = s.searchRepo.GetPopularCategories(channel)
 	}
 	return res, err
 }
 
+// GetRevenueByItem retrieves the revenue earned per menu item, optionally for a single channel.
+func (s *AggregationServiceImpl) GetRevenueByItem(channel string) (models.RevenueReport, error) {
+	if channel != "" && !isValidChannel(channel) {
+		return models.RevenueReport{}, models.ErrInvalidChannel
+	}
+	items, err := s.searchRepo.GetRevenueByItem(channel)
+	if err != nil {
+		return models.RevenueReport{}, err
+	}
+
+	report := models.RevenueReport{Channel: channel, Items: items}
+	var totalCents int64
+	for _, item := range items {
+		totalCents += toCents(item.Revenue)
+	}
+	report.TotalRevenue = fromCents(totalCents)
+	return report, nil
+}
+
+// GetIngredientUsage retrieves the ingredients consumed by the orders placed between from and to,
+// using the recipe versions the orders were actually made with.
+func (s *AggregationServiceImpl) GetIngredientUsage(from, to time.Time) (models.IngredientUsageReport, error) {
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return models.IngredientUsageReport{}, ErrWrongPeriod
+	}
+	ingredients, err := s.searchRepo.GetIngredientUsage(from, to)
+	if err != nil {
+		return models.IngredientUsageReport{}, err
+	}
+
+	report := models.IngredientUsageReport{Ingredients: ingredients}
+	var totalCents int64
+	for _, ingredient := range ingredients {
+		totalCents += toCents(ingredient.Cost)
+	}
+	report.TotalCost = fromCents(totalCents)
+	if !from.IsZero() {
+		report.From = from.Format(time.DateOnly)
+	}
+	if !to.IsZero() {
+		report.To = to.Format(time.DateOnly)
+	}
+	return report, nil
+}
+
+// GetInventoryValuation values the stock of every ingredient and overall at the given time,
+// or now when at is zero.
+func (s *AggregationServiceImpl) GetInventoryValuation(at time.Time) (models.InventoryValuationReport, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	ingredients, err := s.searchRepo.GetInventoryValuation(at)
+	if err != nil {
+		return models.InventoryValuationReport{}, err
+	}
+
+	report := models.InventoryValuationReport{Date: at.Format(time.DateOnly), Ingredients: ingredients}
+	var totalCents int64
+	for _, ingredient := range ingredients {
+		totalCents += toCents(ingredient.Value)
+	}
+	report.TotalValue = fromCents(totalCents)
+	return report, nil
+}
+
 // Search performs a search for menu items and orders based on query and filter parameters.
-func (s *AggregationServiceImpl) Search(searchQuery string, minPrice, maxPrice int, filter string) (models.SearchResult, error) {
+// Menu items are matched against their translation into locale, or their default text when
+// they have none.
+func (s *AggregationServiceImpl) Search(searchQuery string, minPrice, maxPrice int, filter, locale string) (models.SearchResult, error) {
 	// Check if the search query is empty.
 	if searchQuery == "" {
 		return models.SearchResult{}, ErrSearchRequired // Return error if search query is missing.
@@ -65,7 +159,7 @@ func (s *AggregationServiceImpl) Search(searchQuery string, minPrice, maxPrice i
 	var menuItems []models.SearchMenuItem
 	if isMenu {
 		// Fetch menu items based on the search query and price range.
-		menuItems, err = s.searchRepo.SearchMenuItems(searchQuery, minPrice, maxPrice)
+		menuItems, err = s.searchRepo.SearchMenuItems(searchQuery, minPrice, maxPrice, locale)
 		if err != nil {
 			return models.SearchResult{}, err // Return error if fetching menu items fails.
 		}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"errors"
+	"math"
 	"strconv"
+	"strings"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/models"
@@ -62,14 +64,146 @@ func (s *InventoryService) UpdateItem(id int, newItem models.InventoryItem) erro
 	return s.inventoryRepo.UpdateItemRepo(id, newItem)
 }
 
-// DeleteItem deletes an inventory item by its ID.
-func (s *InventoryService) DeleteItem(id int) error {
+// PatchItem applies a JSON Merge Patch to an inventory item and returns the merged item for
+// validation and saving. A non-zero version must match the item's current version, and the
+// merged item keeps the version it was read at.
+func (s *InventoryService) PatchItem(id int, patch []byte, version int) (models.InventoryItem, error) {
+	current, err := s.GetItem(id)
+	if err != nil {
+		return models.InventoryItem{}, err
+	}
+	if version != 0 && version != current.Version {
+		return models.InventoryItem{}, models.ErrVersionConflict
+	}
+
+	var merged models.InventoryItem
+	if err := mergePatch(current, patch, &merged); err != nil {
+		return models.InventoryItem{}, err
+	}
+	merged.IngredientID, merged.Version = current.IngredientID, current.Version
+	return merged, nil
+}
+
+// DeleteItem deletes an inventory item by its ID. A non-zero version must match the item's current version.
+func (s *InventoryService) DeleteItem(id, version int) error {
 	// Check if the inventory item exists before deleting it
 	if !s.inventoryRepo.Exists(id) {
 		return errors.New("inventory item does not exist") // Return error if the item doesn't exist
 	}
 	// Delete the inventory item from the repository
-	return s.inventoryRepo.DeleteItemRepo(id)
+	return s.inventoryRepo.DeleteItemRepo(id, version)
+}
+
+// RestockItem adds a delivery to the stock of an inventory item and returns the restocked item.
+// The item's unit cost becomes the weighted average of the stock held and the delivery. A
+// non-zero version must match the item's current version.
+func (s *InventoryService) RestockItem(id int, restock models.Restock, version int) (models.InventoryItem, error) {
+	if !s.inventoryRepo.Exists(id) {
+		return models.InventoryItem{}, errors.New("inventory item does not exist")
+	}
+	if restock.Quantity <= 0 || restock.Cost < 0 {
+		return models.InventoryItem{}, models.ErrInvalidRestock
+	}
+	if err := s.inventoryRepo.RestockItemRepo(id, restock, version); err != nil {
+		return models.InventoryItem{}, err
+	}
+	return s.GetItem(id)
+}
+
+// GetSubstitutes retrieves the substitutes of an inventory item in the order they are tried.
+func (s *InventoryService) GetSubstitutes(id int) ([]models.IngredientSubstitute, error) {
+	if !s.inventoryRepo.Exists(id) {
+		return nil, errors.New("inventory item does not exist")
+	}
+	return s.inventoryRepo.GetSubstitutes(id)
+}
+
+// SaveSubstitutes replaces the substitutes of an inventory item. Orders try them in the order
+// given when the item runs out. A missing ratio replaces the item one for one.
+func (s *InventoryService) SaveSubstitutes(id int, substitutes []models.IngredientSubstitute) error {
+	// The list is checked on its own before anything is looked up
+	seen := make(map[int]bool, len(substitutes))
+	for i, substitute := range substitutes {
+		if substitute.Ratio == 0 {
+			substitutes[i].Ratio = 1
+		}
+		if substitute.SubstituteID == id || seen[substitute.SubstituteID] || substitutes[i].Ratio < 0 {
+			return models.ErrInvalidSubstitute
+		}
+		seen[substitute.SubstituteID] = true
+	}
+	if !s.inventoryRepo.Exists(id) {
+		return errors.New("inventory item does not exist")
+	}
+	for _, substitute := range substitutes {
+		if !s.inventoryRepo.Exists(substitute.SubstituteID) {
+			return models.ErrInvalidSubstitute
+		}
+	}
+	return s.inventoryRepo.SaveSubstitutes(id, substitutes)
+}
+
+// roundQuantity rounds a quantity to the three decimals stock is stored with, dropping the
+// noise floating point arithmetic leaves behind.
+func roundQuantity(quantity float64) float64 {
+	return math.Round(quantity*1000) / 1000
+}
+
+// roundToUnit rounds a quantity up to the decimals kept for a unit, like round_to_unit in the
+// database, so an order never uses less than its recipe asks for. Floating point noise far below
+// the last decimal is dropped first, so 0.1 * 3 stays 0.3.
+func roundToUnit(quantity float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Ceil(math.Round(quantity*scale*1e6)/1e6) / scale
+}
+
+// GetUnits retrieves every unit of measure.
+func (s *InventoryService) GetUnits() ([]models.Unit, error) {
+	return s.inventoryRepo.GetUnits()
+}
+
+// AddUnit adds a unit of measure that inventory items and recipes can be counted in.
+func (s *InventoryService) AddUnit(unit models.Unit) error {
+	unit.Code = strings.TrimSpace(unit.Code)
+	unit.Name = strings.TrimSpace(unit.Name)
+	if unit.Code == "" || len(unit.Code) > 20 || unit.Name == "" || unit.Factor <= 0 {
+		return models.ErrInvalidUnit
+	}
+	if unit.Decimals != nil && (*unit.Decimals < 0 || *unit.Decimals > 3) {
+		return models.ErrInvalidUnit
+	}
+	switch unit.Dimension {
+	case models.UnitMass, models.UnitVolume, models.UnitCount:
+	default:
+		return models.ErrInvalidUnit
+	}
+	return s.inventoryRepo.AddUnit(unit)
+}
+
+// GetPackUnits retrieves the pack units of an inventory item.
+func (s *InventoryService) GetPackUnits(id int) ([]models.PackUnit, error) {
+	if !s.inventoryRepo.Exists(id) {
+		return nil, errors.New("inventory item does not exist")
+	}
+	return s.inventoryRepo.GetPackUnits(id)
+}
+
+// SavePackUnits replaces the pack units of an inventory item. A pack unit cannot reuse the code
+// of a regular unit, so recipe quantities always mean the same thing.
+func (s *InventoryService) SavePackUnits(id int, packs []models.PackUnit) error {
+	if !s.inventoryRepo.Exists(id) {
+		return errors.New("inventory item does not exist")
+	}
+	seen := make(map[string]bool, len(packs))
+	for i, pack := range packs {
+		packs[i].Code = strings.TrimSpace(pack.Code)
+		code := packs[i].Code
+		if code == "" || len(code) > 20 || seen[code] || pack.Quantity <= 0 || s.inventoryRepo.UnitExists(code) {
+			return models.ErrInvalidPackUnit
+		}
+		seen[code] = true
+	}
+	return s.inventoryRepo.SavePackUnits(id, packs)
 }
 
 // Exists checks if an inventory item exists by its ID.
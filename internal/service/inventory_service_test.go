@@ -0,0 +1,77 @@
+package service
+
+import (
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestRoundToUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity float64
+		decimals int
+		want     float64
+	}{
+		{"already on the grid", 18, 0, 18},
+		{"whole pieces round up", 1.2, 0, 2},
+		{"tiny excess rounds up", 2.0004, 0, 3},
+		{"tenths of a gram", 7.25, 1, 7.3},
+		{"thousandths of a kilogram", 0.0001, 3, 0.001},
+		{"floating point noise is dropped", 0.1 * 3, 1, 0.3},
+		{"noise on whole units", 0.4 * 3 * 5, 0, 6},
+		{"zero", 0, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roundToUnit(tt.quantity, tt.decimals); got != tt.want {
+				t.Errorf("roundToUnit(%v, %d) = %v, want %v", tt.quantity, tt.decimals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddUnitValidation(t *testing.T) {
+	s := &InventoryService{}
+	four, negative := 4, -1
+
+	tests := []struct {
+		name string
+		unit models.Unit
+	}{
+		{"missing code", models.Unit{Name: "Cup", Dimension: models.UnitVolume, Factor: 240}},
+		{"blank name", models.Unit{Code: "cup", Name: "  ", Dimension: models.UnitVolume, Factor: 240}},
+		{"code too long", models.Unit{Code: "a_very_long_unit_code", Name: "Cup", Dimension: models.UnitVolume, Factor: 240}},
+		{"zero factor", models.Unit{Code: "cup", Name: "Cup", Dimension: models.UnitVolume}},
+		{"unknown dimension", models.Unit{Code: "cup", Name: "Cup", Dimension: "length", Factor: 240}},
+		{"too many decimals", models.Unit{Code: "cup", Name: "Cup", Dimension: models.UnitVolume, Factor: 240, Decimals: &four}},
+		{"negative decimals", models.Unit{Code: "cup", Name: "Cup", Dimension: models.UnitVolume, Factor: 240, Decimals: &negative}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.AddUnit(tt.unit); err != models.ErrInvalidUnit {
+				t.Errorf("got %v, want %v", err, models.ErrInvalidUnit)
+			}
+		})
+	}
+}
+
+func TestSaveSubstitutesValidation(t *testing.T) {
+	s := &InventoryService{}
+
+	tests := []struct {
+		name        string
+		substitutes []models.IngredientSubstitute
+	}{
+		{"itself", []models.IngredientSubstitute{{SubstituteID: 2, Ratio: 1}, {SubstituteID: 1, Ratio: 1}}},
+		{"listed twice", []models.IngredientSubstitute{{SubstituteID: 2, Ratio: 1}, {SubstituteID: 3}, {SubstituteID: 2, Ratio: 0.5}}},
+		{"negative ratio", []models.IngredientSubstitute{{SubstituteID: 2, Ratio: -0.5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.SaveSubstitutes(1, tt.substitutes); err != models.ErrInvalidSubstitute {
+				t.Errorf("got %v, want %v", err, models.ErrInvalidSubstitute)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"math"
+	"sort"
+
+	"hot-coffee/models"
+)
+
+const (
+	// DefaultTargetMargin is the gross margin suggested prices aim for when none is given.
+	DefaultTargetMargin = 70.0
+	// DefaultMarginThreshold is the margin below which the margin report flags an item.
+	DefaultMarginThreshold = 60.0
+)
+
+// GetMenuItemCosting works out what a menu item costs to make from the unit costs of its
+// ingredients, and suggests a price that reaches targetMargin. A zero targetMargin uses
+// DefaultTargetMargin.
+func (s *MenuService) GetMenuItemCosting(menuItemID int, targetMargin float64) (models.MenuItemCosting, error) {
+	if targetMargin == 0 {
+		targetMargin = DefaultTargetMargin
+	}
+	if targetMargin <= 0 || targetMargin >= 100 {
+		return models.MenuItemCosting{}, models.ErrInvalidMargin
+	}
+
+	costing, err := s.newCostCalculator()
+	if err != nil {
+		return models.MenuItemCosting{}, err
+	}
+	item, ok := costing.items[menuItemID]
+	if !ok {
+		return models.MenuItemCosting{}, models.ErrMenuItemNotFound
+	}
+
+	result := costing.cost(item)
+	result.TargetMargin = targetMargin
+	result.SuggestedPrice = math.Ceil(result.Cost/(1-targetMargin/100)*100) / 100
+	return result, nil
+}
+
+// GetMarginReport works out the margin of every menu item still on the menu and flags the ones
+// whose margin is below threshold. A zero threshold uses DefaultMarginThreshold.
+func (s *MenuService) GetMarginReport(threshold float64) (models.MarginReport, error) {
+	if threshold == 0 {
+		threshold = DefaultMarginThreshold
+	}
+	if threshold <= 0 || threshold >= 100 {
+		return models.MarginReport{}, models.ErrInvalidMargin
+	}
+
+	costing, err := s.newCostCalculator()
+	if err != nil {
+		return models.MarginReport{}, err
+	}
+
+	report := models.MarginReport{Threshold: threshold, Items: []models.MenuItemCosting{}}
+	for _, item := range costing.ordered {
+		if item.ArchivedAt != nil {
+			continue
+		}
+		result := costing.cost(item)
+		result.Ingredients = nil
+		if result.MarginPercent < threshold {
+			result.BelowThreshold = true
+			report.Flagged++
+		}
+		report.Items = append(report.Items, result)
+	}
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		return report.Items[i].MarginPercent < report.Items[j].MarginPercent
+	})
+	return report, nil
+}
+
+// costCalculator holds the menu and inventory needed to cost menu items.
+type costCalculator struct {
+	ordered    []models.MenuItem
+	items      map[int]models.MenuItem
+	inventory  map[int]models.InventoryItem
+	categories []models.MenuCategory
+}
+
+func (s *MenuService) newCostCalculator() (*costCalculator, error) {
+	menuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	inventoryItems, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.menuRepo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &costCalculator{
+		ordered:    menuItems,
+		items:      make(map[int]models.MenuItem, len(menuItems)),
+		inventory:  make(map[int]models.InventoryItem, len(inventoryItems)),
+		categories: categories,
+	}
+	for _, item := range menuItems {
+		c.items[item.ID] = item
+	}
+	for _, item := range inventoryItems {
+		c.inventory[item.IngredientID] = item
+	}
+	return c, nil
+}
+
+// cost returns the costing of a menu item at its base price.
+func (c *costCalculator) cost(item models.MenuItem) models.MenuItemCosting {
+	result := models.MenuItemCosting{ProductID: item.ID, Name: item.Name, Price: item.Price}
+	if len(item.Bundle) > 0 {
+		result.Cost = c.bundleCost(item)
+	} else {
+		result.Ingredients = c.ingredientCosts(item)
+		for _, ingredient := range result.Ingredients {
+			result.Cost += ingredient.Cost
+		}
+	}
+
+	result.Cost = fromCents(toCents(result.Cost))
+	result.GrossMargin = fromCents(toCents(item.Price) - toCents(result.Cost))
+	if item.Price > 0 {
+		result.FoodCostPercent = math.Round(result.Cost/item.Price*1000) / 10
+		result.MarginPercent = math.Round((100-result.Cost/item.Price*100)*10) / 10
+	}
+	return result
+}
+
+// ingredientCosts breaks down the recipe of a regular menu item by ingredient. Quantities are
+// given in the stock unit the unit cost refers to.
+func (c *costCalculator) ingredientCosts(item models.MenuItem) []models.IngredientCost {
+	costs := []models.IngredientCost{}
+	for _, ingredient := range item.Ingredients {
+		stock := c.inventory[ingredient.IngredientID]
+		costs = append(costs, models.IngredientCost{
+			IngredientID: ingredient.IngredientID,
+			Name:         stock.Name,
+			Unit:         stock.Unit,
+			Quantity:     ingredient.StockQuantity,
+			UnitCost:     stock.UnitCost,
+			Cost:         ingredient.StockQuantity * stock.UnitCost,
+		})
+	}
+	return costs
+}
+
+// recipeCost is the unrounded cost of one serving of a regular menu item.
+func (c *costCalculator) recipeCost(item models.MenuItem) float64 {
+	var total float64
+	for _, ingredient := range c.ingredientCosts(item) {
+		total += ingredient.Cost
+	}
+	return total
+}
+
+// bundleCost adds up the components of a bundle. A choice slot is costed at its most expensive
+// option so the margin is never overstated.
+func (c *costCalculator) bundleCost(bundle models.MenuItem) float64 {
+	var total float64
+	for _, slot := range bundle.Bundle {
+		var slotCost float64
+		if slot.ProductID != 0 {
+			slotCost = c.recipeCost(c.items[slot.ProductID])
+		} else {
+			included := categoryWithDescendants(c.categories, slot.CategoryID)
+			for _, item := range c.ordered {
+				if included[item.CategoryID] && len(item.Bundle) == 0 && item.ArchivedAt == nil {
+					slotCost = math.Max(slotCost, c.recipeCost(item))
+				}
+			}
+		}
+		total += slotCost * float64(slot.Quantity)
+	}
+	return total
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"hot-coffee/models"
+)
+
+// popularityFactor is the share of an even menu mix an item has to reach to count as popular.
+const popularityFactor = 0.7
+
+// menuClassOrder is the order the classes of the menu engineering matrix are listed in.
+var menuClassOrder = map[string]int{
+	models.MenuClassStar:      0,
+	models.MenuClassPlowhorse: 1,
+	models.MenuClassPuzzle:    2,
+	models.MenuClassDog:       3,
+}
+
+// GetMenuEngineering classifies every menu item still on the menu as a star, plowhorse, puzzle or
+// dog from the quantities sold between from and to and its contribution margin, the price less
+// the current recipe cost. Items that did not sell count with zero sales. Without sales in the
+// period the margin threshold is the plain average margin.
+func (s *AggregationServiceImpl) GetMenuEngineering(channel string, from, to time.Time) (models.MenuEngineeringReport, error) {
+	if channel != "" && !isValidChannel(channel) {
+		return models.MenuEngineeringReport{}, models.ErrInvalidChannel
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return models.MenuEngineeringReport{}, ErrWrongPeriod
+	}
+
+	popular, err := s.searchRepo.GetPopularMenuItems(channel, from, to)
+	if err != nil {
+		return models.MenuEngineeringReport{}, err
+	}
+	sold := make(map[int]int, len(popular))
+	for _, item := range popular {
+		sold[item.ProductID] = item.Quantity
+	}
+
+	costing, err := s.menuService.newCostCalculator()
+	if err != nil {
+		return models.MenuEngineeringReport{}, err
+	}
+	categoryNames := make(map[int]string, len(costing.categories))
+	for _, category := range costing.categories {
+		categoryNames[category.ID] = category.Name
+	}
+
+	report := models.MenuEngineeringReport{
+		Channel: channel,
+		Classes: map[string]int{
+			models.MenuClassStar:      0,
+			models.MenuClassPlowhorse: 0,
+			models.MenuClassPuzzle:    0,
+			models.MenuClassDog:       0,
+		},
+		Items: []models.MenuEngineeringItem{},
+	}
+	if !from.IsZero() {
+		report.From = from.Format(time.DateOnly)
+	}
+	if !to.IsZero() {
+		report.To = to.Format(time.DateOnly)
+	}
+
+	for _, item := range costing.ordered {
+		if item.ArchivedAt != nil {
+			continue
+		}
+		cost := costing.cost(item)
+		report.Items = append(report.Items, models.MenuEngineeringItem{
+			ProductID:          item.ID,
+			Name:               item.Name,
+			Category:           categoryNames[item.CategoryID],
+			Price:              item.Price,
+			Cost:               cost.Cost,
+			ContributionMargin: cost.GrossMargin,
+			Sold:               sold[item.ID],
+		})
+	}
+	classifyMenu(&report)
+	return report, nil
+}
+
+// classifyMenu works out the totals and thresholds of a report from the price, margin and sales
+// of its items, puts every item into its class and sorts the items by class, then by total margin.
+// An item is popular once its share of the items sold reaches popularityFactor of an even share,
+// and profitable once its margin reaches the average margin of the items sold.
+func classifyMenu(report *models.MenuEngineeringReport) {
+	var totalMarginCents, plainMarginCents int64
+	report.ItemsSold = 0
+	for i := range report.Items {
+		entry := &report.Items[i]
+		entry.TotalMargin = fromCents(toCents(entry.ContributionMargin) * int64(entry.Sold))
+		report.ItemsSold += entry.Sold
+		totalMarginCents += toCents(entry.TotalMargin)
+		plainMarginCents += toCents(entry.ContributionMargin)
+	}
+	if len(report.Items) == 0 {
+		return
+	}
+
+	report.TotalMargin = fromCents(totalMarginCents)
+	report.PopularityThreshold = math.Round(popularityFactor*100/float64(len(report.Items))*10) / 10
+	if report.ItemsSold > 0 {
+		report.MarginThreshold = fromCents(totalMarginCents / int64(report.ItemsSold))
+	} else {
+		report.MarginThreshold = fromCents(plainMarginCents / int64(len(report.Items)))
+	}
+
+	for i := range report.Items {
+		entry := &report.Items[i]
+		if report.ItemsSold > 0 {
+			entry.MenuMix = math.Round(float64(entry.Sold)/float64(report.ItemsSold)*1000) / 10
+		}
+		// Compare unrounded shares so an item exactly at the threshold counts as popular
+		entry.Popular = report.ItemsSold > 0 &&
+			float64(entry.Sold)*float64(len(report.Items)) >= popularityFactor*float64(report.ItemsSold)
+		entry.Profitable = entry.ContributionMargin >= report.MarginThreshold
+		switch {
+		case entry.Popular && entry.Profitable:
+			entry.Class = models.MenuClassStar
+		case entry.Popular:
+			entry.Class = models.MenuClassPlowhorse
+		case entry.Profitable:
+			entry.Class = models.MenuClassPuzzle
+		default:
+			entry.Class = models.MenuClassDog
+		}
+		report.Classes[entry.Class]++
+	}
+
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Class != b.Class {
+			return menuClassOrder[a.Class] < menuClassOrder[b.Class]
+		}
+		return a.TotalMargin > b.TotalMargin
+	})
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestClassifyMenu(t *testing.T) {
+	type item struct {
+		name   string
+		margin float64
+		sold   int
+	}
+	tests := []struct {
+		name            string
+		items           []item
+		want            map[string]string // name -> class
+		order           []string
+		marginThreshold float64
+	}{
+		{
+			name: "one item per class",
+			items: []item{
+				{"Tea", 1, 5},
+				{"Espresso", 1.5, 50},
+				{"Truffle cake", 5, 5},
+				{"Latte", 3, 40},
+			},
+			want: map[string]string{
+				"Latte":        models.MenuClassStar,
+				"Espresso":     models.MenuClassPlowhorse,
+				"Truffle cake": models.MenuClassPuzzle,
+				"Tea":          models.MenuClassDog,
+			},
+			order:           []string{"Latte", "Espresso", "Truffle cake", "Tea"},
+			marginThreshold: 2.25,
+		},
+		{
+			name: "no sales use the plain average margin",
+			items: []item{
+				{"Latte", 3, 0},
+				{"Tea", 1, 0},
+			},
+			want: map[string]string{
+				"Latte": models.MenuClassPuzzle,
+				"Tea":   models.MenuClassDog,
+			},
+			order:           []string{"Latte", "Tea"},
+			marginThreshold: 2,
+		},
+		{
+			name: "exactly at the popularity threshold",
+			items: []item{
+				{"Tea", 1, 7},
+				{"Latte", 1, 11},
+				{"Mocha", 1, 11},
+				{"Espresso", 1, 11},
+			},
+			want: map[string]string{
+				"Tea":      models.MenuClassStar,
+				"Latte":    models.MenuClassStar,
+				"Mocha":    models.MenuClassStar,
+				"Espresso": models.MenuClassStar,
+			},
+			order:           []string{"Latte", "Mocha", "Espresso", "Tea"},
+			marginThreshold: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := models.MenuEngineeringReport{Classes: map[string]int{}}
+			for _, it := range tt.items {
+				report.Items = append(report.Items, models.MenuEngineeringItem{Name: it.name, ContributionMargin: it.margin, Sold: it.sold})
+			}
+			classifyMenu(&report)
+
+			if report.MarginThreshold != tt.marginThreshold {
+				t.Errorf("margin threshold = %v, want %v", report.MarginThreshold, tt.marginThreshold)
+			}
+			for i, entry := range report.Items {
+				if entry.Class != tt.want[entry.Name] {
+					t.Errorf("%s is a %s, want %s", entry.Name, entry.Class, tt.want[entry.Name])
+				}
+				if entry.Name != tt.order[i] {
+					t.Errorf("item %d is %s, want %s", i, entry.Name, tt.order[i])
+				}
+			}
+		})
+	}
+}
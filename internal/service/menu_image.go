@@ -0,0 +1,189 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // PNG uploads are decoded and re-encoded as JPEG
+	"net/http"
+	"path"
+	"strings"
+
+	"hot-coffee/internal/storage"
+	"hot-coffee/models"
+)
+
+const (
+	// MaxImageSize is the largest image upload accepted, in bytes.
+	MaxImageSize = 10 << 20
+	// maxImagePixels guards against images that are small on disk but huge once decoded.
+	maxImagePixels = 40_000_000
+	imageQuality   = 85
+	// Menu items refer to images as uploads/<key>, the path they are served under.
+	imagePrefix  = "uploads/"
+	DefaultImage = "uploads/default.jpg"
+)
+
+// imageRenditions maps each rendition to the longest side it is scaled down to.
+var imageRenditions = map[string]int{
+	models.ImageSizeThumb:  150,
+	models.ImageSizeMedium: 400,
+	models.ImageSizeLarge:  1024,
+}
+
+// SaveMenuImage checks an uploaded image, re-encodes it into every rendition and stores them under
+// a name derived from the content, so the same upload is only stored once. It returns the path of
+// the large rendition, which is what menu items refer to.
+func (s *MenuService) SaveMenuImage(data []byte) (string, error) {
+	if len(data) > MaxImageSize {
+		return "", models.ErrImageTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return "", models.ErrInvalidImage
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", models.ErrInvalidImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return "", models.ErrImageTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", models.ErrInvalidImage
+	}
+
+	// Flatten transparency onto white, JPEG has no alpha channel
+	bounds := src.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+
+	sum := sha256.Sum256(data)
+	base := imagePrefix + hex.EncodeToString(sum[:16]) + ".jpg"
+	for size, longest := range imageRenditions {
+		key := imageKey(imageRenditionPath(base, size))
+		exists, err := s.images.Exists(key)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			continue // Already stored by an earlier upload of the same image
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scaleDown(flat, longest), &jpeg.Options{Quality: imageQuality}); err != nil {
+			return "", err
+		}
+		if err := s.images.Put(key, buf.Bytes(), "image/jpeg"); err != nil {
+			return "", err
+		}
+	}
+	return base, nil
+}
+
+// GetMenuImage returns the given rendition of a menu item image and the path it is stored under.
+// Images stored before renditions existed are served as they are for every size.
+func (s *MenuService) GetMenuImage(imagePath, size string) (storage.Object, string, error) {
+	if size == "" {
+		size = models.ImageSizeLarge
+	}
+	if _, ok := imageRenditions[size]; !ok {
+		return storage.Object{}, "", models.ErrInvalidImageSize
+	}
+	renditionPath := imageRenditionPath(imagePath, size)
+	object, err := s.images.Get(imageKey(renditionPath))
+	if err == storage.ErrNotFound && renditionPath != imagePath {
+		renditionPath = imagePath
+		object, err = s.images.Get(imageKey(imagePath))
+	}
+	return object, renditionPath, err
+}
+
+// GetUpload returns an uploaded file by the name it is served under in /uploads/.
+func (s *MenuService) GetUpload(name string) (storage.Object, error) {
+	return s.images.Get(name)
+}
+
+// removeImage deletes an image and its renditions unless it is the default image.
+func (s *MenuService) removeImage(imagePath string) error {
+	if imagePath == DefaultImage {
+		return nil
+	}
+	for size := range imageRenditions {
+		if err := s.images.Delete(imageKey(imageRenditionPath(imagePath, size))); err != nil && err != storage.ErrInvalidKey {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImageVersion returns the content hash in the name of a stored image, or "" for images that
+// were not stored under a content-hash name.
+func ImageVersion(imagePath string) string {
+	name := strings.TrimSuffix(path.Base(imagePath), path.Ext(imagePath))
+	if len(name) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(name); err != nil {
+		return ""
+	}
+	return name
+}
+
+// imageRenditionPath names a rendition after the large image: uploads/<hash>-thumb.jpg.
+func imageRenditionPath(imagePath, size string) string {
+	if size == models.ImageSizeLarge {
+		return imagePath
+	}
+	ext := path.Ext(imagePath)
+	return strings.TrimSuffix(imagePath, ext) + "-" + size + ext
+}
+
+// imageKey is the storage key of an image path.
+func imageKey(imagePath string) string {
+	return strings.TrimPrefix(imagePath, imagePrefix)
+}
+
+// scaleDown shrinks an image so its longest side is at most longest pixels, averaging the source
+// pixels that fall into each target pixel. Smaller images are returned unchanged.
+func scaleDown(src *image.RGBA, longest int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw <= longest && sh <= longest {
+		return src
+	}
+	dw, dh := longest, sh*longest/sw
+	if sh > sw {
+		dw, dh = sw*longest/sh, longest
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 255
+		}
+	}
+	return dst
+}
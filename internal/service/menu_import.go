@@ -0,0 +1,397 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"hot-coffee/models"
+)
+
+// menuCSVHeader is the header row of exported CSV files. Imports accept the columns in any
+// order; only name, description and price are required.
+var menuCSVHeader = []string{"name", "description", "price", "category", "position", "ingredients"}
+
+// ExportMenu returns every menu item still on the menu in the portable import format, ordered
+// like the menu. Bundles are left out because their slots refer to other items by ID.
+func (s *MenuService) ExportMenu() ([]models.MenuImportItem, error) {
+	MenuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.menuRepo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	paths := categoryPaths(categories)
+	ingredients := make(map[int]models.InventoryItem)
+	for _, ingredient := range inventory {
+		ingredients[ingredient.IngredientID] = ingredient
+	}
+
+	exported := []models.MenuImportItem{}
+	for _, item := range activeItems(MenuItems) {
+		if len(item.Bundle) > 0 {
+			continue
+		}
+		row := models.MenuImportItem{
+			Name:        item.Name,
+			Description: item.Description,
+			Price:       item.Price,
+			Category:    paths[item.CategoryID],
+			Position:    item.Position,
+			Ingredients: []models.MenuImportIngredient{},
+		}
+		for _, ingr := range item.Ingredients {
+			ingredient := ingredients[ingr.IngredientID]
+			unit := ingr.Unit
+			if unit == "" {
+				unit = ingredient.Unit
+			}
+			row.Ingredients = append(row.Ingredients, models.MenuImportIngredient{
+				Name:     ingredient.Name,
+				Unit:     unit,
+				Quantity: ingr.Quantity,
+			})
+		}
+		exported = append(exported, row)
+	}
+	return exported, nil
+}
+
+// WriteMenuCSV writes exported menu items as CSV. Ingredients share one column as
+// "name:unit:quantity" entries separated by ";".
+func WriteMenuCSV(w io.Writer, items []models.MenuImportItem) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(menuCSVHeader); err != nil {
+		return err
+	}
+	for _, item := range items {
+		ingredients := make([]string, 0, len(item.Ingredients))
+		for _, ingredient := range item.Ingredients {
+			ingredients = append(ingredients, fmt.Sprintf("%s:%s:%s", ingredient.Name, ingredient.Unit,
+				strconv.FormatFloat(ingredient.Quantity, 'f', -1, 64)))
+		}
+		record := []string{
+			item.Name,
+			item.Description,
+			strconv.FormatFloat(item.Price, 'f', 2, 64),
+			item.Category,
+			strconv.Itoa(item.Position),
+			strings.Join(ingredients, ";"),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ImportMenuCSV imports menu items from a CSV file in the format written by WriteMenuCSV.
+// Values that cannot be parsed are reported as row errors together with the other problems.
+func (s *MenuService) ImportMenuCSV(r io.Reader, dryRun bool) (models.MenuImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return models.MenuImportResult{}, fmt.Errorf("%w: could not read the CSV header: %w", models.ErrInvalidImportFile, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range menuCSVHeader[:3] {
+		if _, ok := columns[name]; !ok {
+			return models.MenuImportResult{}, fmt.Errorf("%w: missing column %q", models.ErrInvalidImportFile, name)
+		}
+	}
+
+	var rows []models.MenuImportItem
+	parseErrors := make(map[int][]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return models.MenuImportResult{}, fmt.Errorf("%w: %w", models.ErrInvalidImportFile, err)
+		}
+		row, problems := parseMenuRecord(record, columns)
+		rows = append(rows, row)
+		if len(problems) > 0 {
+			parseErrors[len(rows)] = problems
+		}
+	}
+	return s.importMenu(rows, parseErrors, dryRun)
+}
+
+// parseMenuRecord turns one CSV record into an import row, collecting the values it could not parse.
+func parseMenuRecord(record []string, columns map[string]int) (models.MenuImportItem, []string) {
+	value := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var problems []string
+	row := models.MenuImportItem{
+		Name:        value("name"),
+		Description: value("description"),
+		Category:    value("category"),
+	}
+	if price := value("price"); price != "" {
+		parsed, err := strconv.ParseFloat(price, 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("price %q is not a number", price))
+			// NaN fails no other price check, so the problem is reported once
+			parsed = math.NaN()
+		}
+		row.Price = parsed
+	}
+	if position := value("position"); position != "" {
+		parsed, err := strconv.Atoi(position)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("position %q is not a whole number", position))
+		}
+		row.Position = parsed
+	}
+	for _, entry := range strings.Split(value("ingredients"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// The ingredient name may itself contain ":", so split from the right
+		quantitySep := strings.LastIndex(entry, ":")
+		unitSep := -1
+		if quantitySep > 0 {
+			unitSep = strings.LastIndex(entry[:quantitySep], ":")
+		}
+		if unitSep <= 0 {
+			problems = append(problems, fmt.Sprintf("ingredient %q must be written as name:unit:quantity", entry))
+			continue
+		}
+		quantity, err := strconv.ParseFloat(strings.TrimSpace(entry[quantitySep+1:]), 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("ingredient %q has an invalid quantity", entry))
+			continue
+		}
+		row.Ingredients = append(row.Ingredients, models.MenuImportIngredient{
+			Name:     strings.TrimSpace(entry[:unitSep]),
+			Unit:     strings.TrimSpace(entry[unitSep+1 : quantitySep]),
+			Quantity: quantity,
+		})
+	}
+	return row, problems
+}
+
+// ImportMenu validates every row and, if none has errors, creates or updates the menu items in
+// one transaction. Rows are matched to menu items still on the menu by name, ignoring case.
+// A dry run only validates and reports what would change.
+func (s *MenuService) ImportMenu(rows []models.MenuImportItem, dryRun bool) (models.MenuImportResult, error) {
+	return s.importMenu(rows, nil, dryRun)
+}
+
+// importMenu validates the rows on top of the parse errors already found and applies them.
+func (s *MenuService) importMenu(rows []models.MenuImportItem, parseErrors map[int][]string, dryRun bool) (models.MenuImportResult, error) {
+	result := models.MenuImportResult{DryRun: dryRun, Rows: len(rows), Errors: []models.MenuImportError{}}
+	if len(rows) == 0 {
+		return result, fmt.Errorf("%w: the file has no menu items", models.ErrInvalidImportFile)
+	}
+
+	MenuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return result, err
+	}
+	categories, err := s.menuRepo.GetCategories()
+	if err != nil {
+		return result, err
+	}
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return result, err
+	}
+
+	existing := make(map[string]models.MenuItem)
+	for _, item := range activeItems(MenuItems) {
+		existing[strings.ToLower(item.Name)] = item
+	}
+	categoryIDs := categoryLookup(categories)
+	ingredientsByName := make(map[string][]models.InventoryItem)
+	for _, ingredient := range inventory {
+		key := strings.ToLower(strings.TrimSpace(ingredient.Name))
+		ingredientsByName[key] = append(ingredientsByName[key], ingredient)
+	}
+
+	seen := make(map[string]int)
+	var changes []models.MenuItem
+	for i, row := range rows {
+		number := i + 1
+		problems := append([]string{}, parseErrors[number]...)
+		item := models.MenuItem{Image: DefaultImage}
+
+		name := strings.TrimSpace(row.Name)
+		key := strings.ToLower(name)
+		if current, ok := existing[key]; ok {
+			item = current
+			if len(current.Bundle) > 0 {
+				problems = append(problems, "bundles cannot be imported")
+			}
+		}
+		item.Name = name
+		item.Description = strings.TrimSpace(row.Description)
+		item.Price = row.Price
+		item.Position = row.Position
+
+		switch {
+		case name == "":
+			problems = append(problems, "name is empty")
+		case len([]rune(name)) > 50:
+			problems = append(problems, "name is longer than 50 characters")
+		case seen[key] != 0:
+			problems = append(problems, fmt.Sprintf("duplicates row %d", seen[key]))
+		default:
+			seen[key] = number
+		}
+		if item.Description == "" {
+			problems = append(problems, "description is empty")
+		}
+		if row.Price <= 0 {
+			problems = append(problems, "price must be greater than zero")
+		}
+		if row.Position < 0 {
+			problems = append(problems, "position must not be negative")
+		}
+
+		item.CategoryID = 0
+		if category := strings.TrimSpace(row.Category); category != "" {
+			ids := categoryIDs[strings.ToLower(category)]
+			switch len(ids) {
+			case 0:
+				problems = append(problems, fmt.Sprintf("category %q does not exist", category))
+			case 1:
+				item.CategoryID = ids[0]
+			default:
+				problems = append(problems, fmt.Sprintf("category %q is ambiguous, use its full path", category))
+			}
+		}
+
+		// A row without ingredients keeps the recipe of the item it updates
+		if len(row.Ingredients) > 0 || item.ID == 0 {
+			item.Ingredients = []models.MenuItemIngredient{}
+		}
+		used := make(map[int]bool)
+		for _, ingredient := range row.Ingredients {
+			label := fmt.Sprintf("%s (%s)", ingredient.Name, ingredient.Unit)
+			ids := matchIngredient(ingredientsByName[strings.ToLower(strings.TrimSpace(ingredient.Name))], ingredient.Unit)
+			switch {
+			case len(ids) == 0:
+				problems = append(problems, fmt.Sprintf("ingredient %s does not exist in inventory", label))
+				continue
+			case len(ids) > 1:
+				problems = append(problems, fmt.Sprintf("ingredient %s matches several inventory items", label))
+				continue
+			case used[ids[0].IngredientID]:
+				problems = append(problems, fmt.Sprintf("ingredient %s is listed twice", label))
+				continue
+			}
+			// Quantities in another unit than the stock unit must convert into it
+			unit := strings.TrimSpace(ingredient.Unit)
+			if strings.EqualFold(unit, ids[0].Unit) {
+				unit = ""
+			}
+			if _, err := s.inventoryRepo.ConvertToStockUnit(ids[0].IngredientID, 1, unit); err != nil {
+				problems = append(problems, fmt.Sprintf("ingredient %s cannot be measured in %s", label, ingredient.Unit))
+				continue
+			}
+			if ingredient.Quantity <= 0 || ingredient.Quantity != roundQuantity(ingredient.Quantity) {
+				problems = append(problems, fmt.Sprintf("ingredient %s quantity must be greater than zero with at most three decimals", label))
+				continue
+			}
+			used[ids[0].IngredientID] = true
+			item.Ingredients = append(item.Ingredients, models.MenuItemIngredient{IngredientID: ids[0].IngredientID, Quantity: ingredient.Quantity, Unit: unit})
+		}
+
+		if len(problems) > 0 {
+			result.Errors = append(result.Errors, models.MenuImportError{Row: number, Name: name, Errors: problems})
+			continue
+		}
+		if item.ID == 0 {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		changes = append(changes, item)
+	}
+
+	if len(result.Errors) > 0 {
+		result.Created, result.Updated = 0, 0
+		return result, nil
+	}
+	if dryRun {
+		return result, nil
+	}
+	return result, s.menuRepo.ImportMenuItems(changes)
+}
+
+// categoryPaths maps category IDs to their full path, e.g. "Coffee/Espresso Drinks".
+func categoryPaths(categories []models.MenuCategory) map[int]string {
+	byID := make(map[int]models.MenuCategory)
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+	paths := make(map[int]string)
+	for _, category := range categories {
+		names := []string{category.Name}
+		// Guard against cycles so a broken tree cannot hang the export
+		for parent, depth := category.ParentID, 0; parent != 0 && depth < len(categories); depth++ {
+			names = append([]string{byID[parent].Name}, names...)
+			parent = byID[parent].ParentID
+		}
+		paths[category.ID] = strings.Join(names, "/")
+	}
+	return paths
+}
+
+// categoryLookup maps lower-cased category paths and plain names to category IDs. A plain name
+// maps to several IDs when categories in different places share it.
+func categoryLookup(categories []models.MenuCategory) map[string][]int {
+	lookup := make(map[string][]int)
+	for id, path := range categoryPaths(categories) {
+		lookup[strings.ToLower(path)] = append(lookup[strings.ToLower(path)], id)
+	}
+	for _, category := range categories {
+		if category.ParentID != 0 {
+			name := strings.ToLower(category.Name)
+			lookup[name] = append(lookup[name], category.ID)
+		}
+	}
+	return lookup
+}
+
+// matchIngredient picks the inventory items an imported recipe line refers to among the ones
+// sharing its name. When several share it, the one stocked in the line's unit wins.
+func matchIngredient(candidates []models.InventoryItem, unit string) []models.InventoryItem {
+	if len(candidates) <= 1 {
+		return candidates
+	}
+	var matches []models.InventoryItem
+	for _, candidate := range candidates {
+		if strings.EqualFold(candidate.Unit, strings.TrimSpace(unit)) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return candidates
+	}
+	return matches
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestParseMenuRecord(t *testing.T) {
+	columns := map[string]int{"name": 0, "description": 1, "price": 2, "ingredients": 3}
+
+	tests := []struct {
+		name        string
+		record      []string
+		problems    int
+		ingredients int
+	}{
+		{"valid", []string{"Latte", "Milk coffee", "4.50", "Milk:ml:200;Espresso Shot:shots:1"}, 0, 2},
+		{"no ingredients", []string{"Latte", "Milk coffee", "4.50", ""}, 0, 0},
+		{"price is not a number", []string{"Latte", "Milk coffee", "four", ""}, 1, 0},
+		{"name with a colon", []string{"Latte", "Milk coffee", "4.50", "Syrup: vanilla:ml:15"}, 0, 1},
+		{"ingredient without unit", []string{"Latte", "Milk coffee", "4.50", "Milk:200"}, 1, 0},
+		{"invalid quantity", []string{"Latte", "Milk coffee", "4.50", "Milk:ml:lots"}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, problems := parseMenuRecord(tt.record, columns)
+			if len(problems) != tt.problems {
+				t.Errorf("problems = %q, want %d", problems, tt.problems)
+			}
+			if len(row.Ingredients) != tt.ingredients {
+				t.Errorf("ingredients = %d, want %d", len(row.Ingredients), tt.ingredients)
+			}
+		})
+	}
+}
+
+func TestImportMenuPriceNotANumberReportedOnce(t *testing.T) {
+	row, problems := parseMenuRecord([]string{"Latte", "Milk coffee", "n/a"}, map[string]int{"name": 0, "description": 1, "price": 2})
+	if len(problems) != 1 {
+		t.Fatalf("problems = %q, want 1", problems)
+	}
+	// importMenu only adds its own price problem for prices that parsed
+	if row.Price <= 0 {
+		t.Errorf("price = %v, a price that did not parse must not fail the greater than zero check", row.Price)
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"hot-coffee/models"
+)
+
+// GetRecommendations returns the menu items most often bought together with a menu item,
+// with names in locale. rank is models.RankByLift or models.RankByConfidence, and pairs seen
+// in fewer than minOrders orders are ignored.
+func (s *MenuService) GetRecommendations(MenuItemID int, rank string, minOrders, limit int, locale string) ([]models.Recommendation, error) {
+	if !s.menuRepo.MenuCheckByIDRepo(MenuItemID) {
+		return nil, models.ErrMenuItemNotFound
+	}
+	return s.recommend([]int{MenuItemID}, rank, minOrders, limit, locale)
+}
+
+// GetBasketRecommendations returns the menu items most often bought together with the items
+// in a cart. Items already in the cart are not recommended.
+func (s *MenuService) GetBasketRecommendations(items []models.OrderItem, rank string, minOrders, limit int, locale string) ([]models.Recommendation, error) {
+	var productIDs []int
+	for _, item := range items {
+		if item.ProductID > 0 {
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	if len(productIDs) == 0 {
+		return nil, models.ErrEmptyBasket
+	}
+	return s.recommend(productIDs, rank, minOrders, limit, locale)
+}
+
+// recommend looks up the recommendations for productIDs and translates their names.
+func (s *MenuService) recommend(productIDs []int, rank string, minOrders, limit int, locale string) ([]models.Recommendation, error) {
+	recommendations, err := s.menuRepo.GetRecommendations(productIDs, rank, minOrders, limit)
+	if err != nil || locale == "" || locale == models.DefaultLocale {
+		return recommendations, err
+	}
+	translations, err := s.menuRepo.GetTranslations(locale)
+	if err != nil {
+		return nil, err
+	}
+	for i, recommendation := range recommendations {
+		if translation, ok := translations[recommendation.ProductID]; ok {
+			recommendations[i].Name = translation.Name
+		}
+	}
+	return recommendations, nil
+}
+
+// StartRecommendationRefresher recomputes which menu items are bought together right away and
+// then every interval in the background, so recommendations never scan the order history.
+func (s *MenuService) StartRecommendationRefresher(interval time.Duration, logger *slog.Logger) {
+	refresh := func() {
+		if err := s.menuRepo.RefreshRecommendations(); err != nil {
+			logger.Error("Could not refresh recommendations", "error", err)
+		}
+	}
+
+	go func() {
+		refresh()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			refresh()
+		}
+	}()
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"hot-coffee/models"
+)
+
+var weekdayNames = []string{"", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// validateSchedules checks that days, times and dates of availability windows are well formed.
+func validateSchedules(schedules []models.MenuItemSchedule) error {
+	for _, schedule := range schedules {
+		for _, day := range schedule.Days {
+			if day < 1 || day > 7 {
+				return errors.New("schedule days must be between 1 (Monday) and 7 (Sunday)")
+			}
+		}
+		if (schedule.StartTime == "") != (schedule.EndTime == "") {
+			return errors.New("schedule needs both start_time and end_time")
+		}
+		for _, clock := range []string{schedule.StartTime, schedule.EndTime} {
+			if _, err := time.Parse("15:04", clock); clock != "" && err != nil {
+				return errors.New("schedule times must be in HH:MM format")
+			}
+		}
+		// A window from a time to the same time would never cover anything
+		if schedule.StartTime != "" && schedule.StartTime == schedule.EndTime {
+			return errors.New("schedule start_time and end_time must differ")
+		}
+		var start, end time.Time
+		var err error
+		if schedule.StartDate != "" {
+			if start, err = time.Parse(time.DateOnly, schedule.StartDate); err != nil {
+				return errors.New("schedule dates must be in YYYY-MM-DD format")
+			}
+		}
+		if schedule.EndDate != "" {
+			if end, err = time.Parse(time.DateOnly, schedule.EndDate); err != nil {
+				return errors.New("schedule dates must be in YYYY-MM-DD format")
+			}
+		}
+		if !start.IsZero() && !end.IsZero() && end.Before(start) {
+			return errors.New("schedule end_date must not be before start_date")
+		}
+	}
+	return nil
+}
+
+// isAvailableAt reports whether any of the item's schedules covers the given moment.
+// Items without schedules are always available.
+func isAvailableAt(item models.MenuItem, at time.Time) bool {
+	if len(item.Schedules) == 0 {
+		return true
+	}
+	for _, schedule := range item.Schedules {
+		if scheduleCovers(schedule, at) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleCovers reports whether a single availability window covers the given moment.
+// The schedule is already validated, so parsing errors are not expected here.
+func scheduleCovers(schedule models.MenuItemSchedule, at time.Time) bool {
+	date := at.Format(time.DateOnly)
+	if schedule.StartDate != "" && date < schedule.StartDate {
+		return false
+	}
+	if schedule.EndDate != "" && date > schedule.EndDate {
+		return false
+	}
+
+	if len(schedule.Days) > 0 {
+		// time.Weekday counts from Sunday = 0, schedules use ISO numbering
+		weekday := int(at.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		found := false
+		for _, day := range schedule.Days {
+			if day == weekday {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if schedule.StartTime != "" && schedule.EndTime != "" {
+		clock := at.Format("15:04")
+		if schedule.StartTime <= schedule.EndTime {
+			return clock >= schedule.StartTime && clock < schedule.EndTime
+		}
+		// The window spans midnight, e.g. 22:00-02:00
+		return clock >= schedule.StartTime || clock < schedule.EndTime
+	}
+	return true
+}
+
+// filterAvailable keeps the items that are available at the given moment.
+func filterAvailable(items []models.MenuItem, at time.Time) []models.MenuItem {
+	available := []models.MenuItem{}
+	for _, item := range items {
+		if isAvailableAt(item, at) {
+			available = append(available, item)
+		}
+	}
+	return available
+}
+
+// describeSchedules renders availability windows for error messages, e.g. "Mon-Fri 07:00-11:00".
+func describeSchedules(schedules []models.MenuItemSchedule) string {
+	var parts []string
+	for _, schedule := range schedules {
+		var words []string
+		if len(schedule.Days) > 0 {
+			var days []string
+			for _, day := range schedule.Days {
+				days = append(days, weekdayNames[day])
+			}
+			words = append(words, strings.Join(days, ","))
+		}
+		if schedule.StartTime != "" {
+			words = append(words, fmt.Sprintf("%s-%s", schedule.StartTime, schedule.EndTime))
+		}
+		if schedule.StartDate != "" {
+			words = append(words, "from "+schedule.StartDate)
+		}
+		if schedule.EndDate != "" {
+			words = append(words, "until "+schedule.EndDate)
+		}
+		parts = append(parts, strings.Join(words, " "))
+	}
+	return strings.Join(parts, "; ")
+}
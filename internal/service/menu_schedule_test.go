@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"hot-coffee/models"
+)
+
+func TestValidateSchedules(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule models.MenuItemSchedule
+		wantErr  bool
+	}{
+		{"weekday mornings", models.MenuItemSchedule{Days: []int{1, 2, 3, 4, 5}, StartTime: "07:00", EndTime: "11:00"}, false},
+		{"past midnight", models.MenuItemSchedule{StartTime: "22:00", EndTime: "02:00"}, false},
+		{"date range only", models.MenuItemSchedule{StartDate: "2026-12-01", EndDate: "2026-12-31"}, false},
+		{"single day", models.MenuItemSchedule{StartDate: "2026-12-24", EndDate: "2026-12-24"}, false},
+		{"day out of range", models.MenuItemSchedule{Days: []int{0}}, true},
+		{"start time without end", models.MenuItemSchedule{StartTime: "07:00"}, true},
+		{"same start and end time", models.MenuItemSchedule{StartTime: "07:00", EndTime: "07:00"}, true},
+		{"bad time", models.MenuItemSchedule{StartTime: "7am", EndTime: "11:00"}, true},
+		{"bad date", models.MenuItemSchedule{StartDate: "01.12.2026"}, true},
+		{"end date before start", models.MenuItemSchedule{StartDate: "2026-12-31", EndDate: "2026-12-01"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSchedules([]models.MenuItemSchedule{tt.schedule})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleCovers(t *testing.T) {
+	at := func(s string) time.Time {
+		moment, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return moment
+	}
+	breakfast := models.MenuItemSchedule{Days: []int{1, 2, 3, 4, 5}, StartTime: "07:00", EndTime: "11:00"}
+	lateNight := models.MenuItemSchedule{StartTime: "22:00", EndTime: "02:00"}
+
+	tests := []struct {
+		name     string
+		schedule models.MenuItemSchedule
+		at       string
+		want     bool
+	}{
+		{"inside on a weekday", breakfast, "2026-10-19 08:30", true},
+		{"start is included", breakfast, "2026-10-19 07:00", true},
+		{"end is excluded", breakfast, "2026-10-19 11:00", false},
+		{"on a Sunday", breakfast, "2026-10-18 08:30", false},
+		{"before midnight", lateNight, "2026-10-19 23:00", true},
+		{"after midnight", lateNight, "2026-10-20 01:59", true},
+		{"during the day", lateNight, "2026-10-19 12:00", false},
+		{"before the dates", models.MenuItemSchedule{StartDate: "2026-12-01"}, "2026-11-30 12:00", false},
+		{"last day of the dates", models.MenuItemSchedule{EndDate: "2026-12-31"}, "2026-12-31 23:59", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scheduleCovers(tt.schedule, at(tt.at)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"errors"
-	"os"
+	"fmt"
 	"strings"
+	"time"
 
 	"hot-coffee/internal/dal"
+	"hot-coffee/internal/storage"
 	"hot-coffee/models"
 )
 
@@ -13,28 +15,78 @@ import (
 type MenuService struct {
 	menuRepo      dal.MenuRepository      // Repository for interacting with menu items.
 	inventoryRepo dal.InventoryRepository // Repository for interacting with inventory items.
+	images        storage.Storage         // Storage for menu item images.
 }
 
-// NewMenuService creates a new instance of MenuService with the given repositories.
-func NewMenuService(menuRepo dal.MenuRepository, inventoryRepo dal.InventoryRepository) *MenuService {
-	return &MenuService{menuRepo: menuRepo, inventoryRepo: inventoryRepo}
+// NewMenuService creates a new instance of MenuService with the given repositories and image storage.
+func NewMenuService(menuRepo dal.MenuRepository, inventoryRepo dal.InventoryRepository, images storage.Storage) *MenuService {
+	return &MenuService{menuRepo: menuRepo, inventoryRepo: inventoryRepo, images: images}
 }
 
-// DeleteMenuItem deletes a menu item by its ID. It also removes the associated image if it exists.
-func (s *MenuService) DeleteMenuItem(MenuItemID int) error {
-	// First, retrieve the menu item by its ID to check if it exists
+// DeleteMenuItem archives a menu item by its ID. The item leaves the menu and can no longer be
+// ordered, but past orders and reports keep referring to it. A non-zero version must match the
+// item's current version.
+func (s *MenuService) DeleteMenuItem(MenuItemID, version int) error {
+	if !s.menuRepo.MenuCheckByIDRepo(MenuItemID) {
+		return models.ErrMenuItemNotFound
+	}
+	return s.menuRepo.ArchiveMenuItemRepo(MenuItemID, version)
+}
+
+// RestoreMenuItem puts an archived menu item back on the menu.
+func (s *MenuService) RestoreMenuItem(MenuItemID int) error {
+	if !s.menuRepo.MenuCheckByIDRepo(MenuItemID) {
+		return models.ErrMenuItemNotFound
+	}
+	return s.menuRepo.RestoreMenuItemRepo(MenuItemID)
+}
+
+// PurgeMenuItem permanently deletes a menu item and its image. Only items that no order
+// refers to can be purged. A non-zero version must match the item's current version.
+func (s *MenuService) PurgeMenuItem(MenuItemID, version int) error {
 	menuItem, err := s.GetMenuItem(MenuItemID)
 	if err != nil {
-		return err // Return error if item is not found
+		return err
+	}
+	referenced, err := s.menuRepo.IsReferencedByOrders(MenuItemID)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return models.ErrMenuItemInUse
 	}
 
-	// Remove the image associated with the menu item if it exists
-	if err := RemoveImage(menuItem.Image); err != nil {
-		return err // Return error if image removal fails
+	if err := s.menuRepo.PurgeMenuItemRepo(MenuItemID, version); err != nil {
+		return err
 	}
+	// Remove the image associated with the menu item if nothing else shows it
+	return s.removeUnusedImage(menuItem.Image, MenuItemID)
+}
 
-	// Finally, delete the menu item from the repository
-	return s.menuRepo.DeleteMenuItemRepo(MenuItemID)
+// GetArchivedMenuItems retrieves the menu items that were taken off the menu.
+func (s *MenuService) GetArchivedMenuItems() ([]models.MenuItem, error) {
+	MenuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	archived := []models.MenuItem{}
+	for _, item := range MenuItems {
+		if item.ArchivedAt != nil {
+			archived = append(archived, item)
+		}
+	}
+	return archived, nil
+}
+
+// activeItems leaves out archived menu items.
+func activeItems(items []models.MenuItem) []models.MenuItem {
+	active := make([]models.MenuItem, 0, len(items))
+	for _, item := range items {
+		if item.ArchivedAt == nil {
+			active = append(active, item)
+		}
+	}
+	return active
 }
 
 // UpdateMenuItem updates an existing menu item in the repository.
@@ -43,6 +95,26 @@ func (s *MenuService) UpdateMenuItem(menuItem models.MenuItem) error {
 	return s.menuRepo.UpdateMenuItemRepo(menuItem)
 }
 
+// PatchMenuItem applies a JSON Merge Patch to a menu item and returns the merged item for
+// validation and saving. A non-zero version must match the item's current version. The merged
+// item carries the version it was read at, so saving it fails if the item changes in between.
+func (s *MenuService) PatchMenuItem(MenuItemID int, patch []byte, version int) (models.MenuItem, error) {
+	current, err := s.GetMenuItem(MenuItemID)
+	if err != nil {
+		return models.MenuItem{}, err
+	}
+	if version != 0 && version != current.Version {
+		return models.MenuItem{}, models.ErrVersionConflict
+	}
+
+	var merged models.MenuItem
+	if err := mergePatch(current, patch, &merged); err != nil {
+		return models.MenuItem{}, err
+	}
+	merged.ID, merged.Version = current.ID, current.Version
+	return merged, nil
+}
+
 // MenuCheckByID checks if a menu item exists before adding or deleting it.
 func (s *MenuService) MenuCheckByID(MenuItemID int, isDelete bool) error {
 	if isDelete {
@@ -61,41 +133,131 @@ func (s *MenuService) MenuCheckByID(MenuItemID int, isDelete bool) error {
 }
 
 // IngredientsCheckByID checks if there are enough ingredients for a menu item by its ID.
-func (s *MenuService) IngredientsCheckByID(menuItemID int, quantity int) error {
+// What a line of quantity servings uses is rounded up to the decimals of each stock unit,
+// the way placing the order deducts it. A bundle is checked by the products it is made of:
+// the product of each fixed slot and the one chosen for each choice slot. Choice slots
+// without a valid choice are left to the order, which rejects them. An ingredient whose
+// recipe unit does not convert into its stock unit fails with models.ErrIncompatibleUnit. Orders placed through
+// a channel that uses packaging also need the packaging of each product, which has no substitutes.
+func (s *MenuService) IngredientsCheckByID(menuItemID int, quantity int, choices []models.BundleChoice, channel string) error {
 	// Retrieve all menu items
 	menuItems, _ := s.menuRepo.GetAll()
-	ingredientsNeeded := make(map[int]float64)
-	flag := false
-
-	// Iterate over all menu items to find the required ingredients for the given item
+	byID := make(map[int]models.MenuItem, len(menuItems))
 	for _, item := range menuItems {
-		if item.ID == menuItemID {
-			for _, ingr := range item.Ingredients {
-				ingredientsNeeded[ingr.IngredientID] += float64(ingr.Quantity) * float64(quantity)
+		byID[item.ID] = item
+	}
+	item := byID[menuItemID]
+
+	// Work out how many servings of each product the line is made of
+	products := []int{menuItemID}
+	servings := map[int]int{menuItemID: quantity}
+	if len(item.Bundle) > 0 {
+		chosen := make(map[int]int, len(choices))
+		for _, choice := range choices {
+			chosen[choice.SlotID] = choice.ProductID
+		}
+		products, servings = nil, make(map[int]int)
+		for _, slot := range item.Bundle {
+			productID := slot.ProductID
+			if slot.CategoryID != 0 {
+				productID = chosen[slot.ID]
+			}
+			if _, ok := byID[productID]; !ok {
+				continue
 			}
+			if _, ok := servings[productID]; !ok {
+				products = append(products, productID)
+			}
+			servings[productID] += slot.Quantity * quantity
 		}
 	}
 
 	// Retrieve all inventory items
 	inventoryItems, _ := s.inventoryRepo.GetAll()
+	units, err := s.inventoryRepo.GetUnits()
+	if err != nil {
+		return err
+	}
+	decimals := stockDecimals(inventoryItems, units)
+	stock := make(map[int]float64, len(inventoryItems))
+	for _, inventoryItem := range inventoryItems {
+		stock[inventoryItem.IngredientID] = inventoryItem.Quantity
+	}
+
+	// Each product is deducted as a line of its own, rounded on its own
+	ingredientsNeeded := make(map[int]float64)
+	noSubstitutes := make(map[int]bool)
+	for _, productID := range products {
+		product := byID[productID]
+		for _, ingr := range product.Ingredients {
+			// A recipe unit that no longer converts into the stock unit cannot be deducted
+			if ingr.StockQuantity == 0 && ingr.Quantity > 0 {
+				return fmt.Errorf("%w. IngredientID: %d", models.ErrIncompatibleUnit, ingr.IngredientID)
+			}
+			ingredientsNeeded[ingr.IngredientID] += roundToUnit(ingr.StockQuantity*float64(servings[productID]), decimals[ingr.IngredientID])
+			if product.NoSubstitutes {
+				noSubstitutes[ingr.IngredientID] = true
+			}
+		}
+		if !dal.NeedsPackaging(channel) {
+			continue
+		}
+		for _, packaging := range product.Packaging {
+			ingredientsNeeded[packaging.IngredientID] += roundToUnit(packaging.Quantity*float64(servings[productID]), decimals[packaging.IngredientID])
+			noSubstitutes[packaging.IngredientID] = true
+		}
+	}
+	substitutes, _ := s.inventoryRepo.GetAllSubstitutes()
 
 	// Check if there are sufficient quantities of the ingredients in inventory
+	flag := false
 	for _, inventoryItem := range inventoryItems {
 		if value, exists := ingredientsNeeded[inventoryItem.IngredientID]; exists {
 			flag = true
-			if value > inventoryItem.Quantity {
+			// Without the substitutes every ingredient has to be in stock itself
+			var candidates []models.IngredientSubstitute
+			if !noSubstitutes[inventoryItem.IngredientID] {
+				candidates = substitutes[inventoryItem.IngredientID]
+			}
+			if value > inventoryItem.Quantity && !hasSubstitute(candidates, stock, decimals, value) {
 				return errors.New("not enough ingredients for item") // Not enough inventory for the item
 			}
 		}
 	}
 
 	// Return error if there are no matching ingredients in the inventory
-	if flag {
+	if flag || len(item.Bundle) > 0 {
 		return nil
 	}
 	return errors.New("no ingredients for item in inventory")
 }
 
+// hasSubstitute reports whether one of the substitutes has enough stock to replace needed of an ingredient.
+func hasSubstitute(substitutes []models.IngredientSubstitute, stock map[int]float64, decimals map[int]int, needed float64) bool {
+	for _, substitute := range substitutes {
+		if roundToUnit(needed*substitute.Ratio, decimals[substitute.SubstituteID]) <= stock[substitute.SubstituteID] {
+			return true
+		}
+	}
+	return false
+}
+
+// stockDecimals maps each inventory item to the decimals kept for its stock unit.
+func stockDecimals(inventoryItems []models.InventoryItem, units []models.Unit) map[int]int {
+	byUnit := make(map[string]int, len(units))
+	for _, unit := range units {
+		byUnit[unit.Code] = 3
+		if unit.Decimals != nil {
+			byUnit[unit.Code] = *unit.Decimals
+		}
+	}
+	decimals := make(map[int]int, len(inventoryItems))
+	for _, item := range inventoryItems {
+		decimals[item.IngredientID] = byUnit[item.Unit]
+	}
+	return decimals
+}
+
 // IngredientsCheckForNewItem checks if there are enough ingredients in the inventory to add a new menu item.
 func (s *MenuService) IngredientsCheckForNewItem(menuItem models.MenuItem) error {
 	// Retrieve all inventory items
@@ -107,7 +269,12 @@ func (s *MenuService) IngredientsCheckForNewItem(menuItem models.MenuItem) error
 		for _, ingredients := range menuItem.Ingredients {
 			if ingredients.IngredientID == inventoryItem.IngredientID {
 				count++
-				if ingredients.Quantity > inventoryItem.Quantity {
+				// Recipe quantities may be given in any unit that converts into the stock unit
+				needed, err := s.inventoryRepo.ConvertToStockUnit(ingredients.IngredientID, ingredients.Quantity, ingredients.Unit)
+				if err != nil {
+					return err
+				}
+				if needed > inventoryItem.Quantity {
 					return errors.New("not enough ingredients for item") // Not enough inventory for the new item
 				}
 			}
@@ -118,13 +285,20 @@ func (s *MenuService) IngredientsCheckForNewItem(menuItem models.MenuItem) error
 	if count != len(menuItem.Ingredients) {
 		return errors.New("no ingredients for item in inventory")
 	}
+
+	// Packaging must refer to existing inventory items as well
+	for _, packaging := range menuItem.Packaging {
+		if !s.inventoryRepo.Exists(packaging.IngredientID) {
+			return errors.New("no packaging for item in inventory")
+		}
+	}
 	return nil
 }
 
 // SubtractIngredientsByID subtracts the required ingredients from the inventory when an order is placed.
 func (s *MenuService) SubtractIngredientsByID(OrderID int, quantity int) error {
 	// First, check if there are enough ingredients for the given order
-	if err := s.IngredientsCheckByID(OrderID, quantity); err != nil {
+	if err := s.IngredientsCheckByID(OrderID, quantity, nil, models.ChannelDineIn); err != nil {
 		return errors.New("not enough ingredients or needed ingredients do not exist") // Return error if check fails
 	}
 
@@ -135,7 +309,7 @@ func (s *MenuService) SubtractIngredientsByID(OrderID int, quantity int) error {
 	for _, item := range menuItems {
 		if item.ID == OrderID {
 			for _, ingr := range item.Ingredients {
-				ingredients[ingr.IngredientID] += float64(ingr.Quantity) * float64(quantity)
+				ingredients[ingr.IngredientID] += ingr.StockQuantity * float64(quantity)
 			}
 		}
 	}
@@ -163,17 +337,198 @@ func (s *MenuService) GetMenuItem(MenuItemID int) (models.MenuItem, error) {
 		}
 	}
 	// Return an error if the item is not found by its ID
-	return models.MenuItem{}, errors.New("could not find menu item by the given id")
+	return models.MenuItem{}, models.ErrMenuItemNotFound
 }
 
-// GetMenuItems retrieves all menu items from the repository.
-func (s *MenuService) GetMenuItems() ([]models.MenuItem, error) {
+// GetMenuItems retrieves all menu items from the repository except archived ones, translated
+// into locale. A non-zero at keeps only the items available at that moment.
+func (s *MenuService) GetMenuItems(at time.Time, locale string) ([]models.MenuItem, error) {
 	// Retrieve all menu items from the repository
 	MenuItems, err := s.menuRepo.GetAll()
 	if err != nil {
 		return []models.MenuItem{}, err // Return error if failed to retrieve menu items
 	}
-	return MenuItems, err
+	MenuItems = activeItems(MenuItems)
+	if !at.IsZero() {
+		MenuItems = filterAvailable(MenuItems, at)
+	}
+	return s.translate(MenuItems, locale)
+}
+
+// GetMenuItemsByCategory retrieves the menu items of a category and of all its subcategories,
+// translated into locale. A non-zero at keeps only the items available at that moment.
+func (s *MenuService) GetMenuItemsByCategory(categoryID int, at time.Time, locale string) ([]models.MenuItem, error) {
+	categories, err := s.menuRepo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	included := categoryWithDescendants(categories, categoryID)
+	if len(included) == 0 {
+		return nil, models.ErrCategoryNotFound
+	}
+
+	MenuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	MenuItems = activeItems(MenuItems)
+	if !at.IsZero() {
+		MenuItems = filterAvailable(MenuItems, at)
+	}
+	filtered := []models.MenuItem{}
+	for _, item := range MenuItems {
+		if included[item.CategoryID] {
+			filtered = append(filtered, item)
+		}
+	}
+	return s.translate(filtered, locale)
+}
+
+// GetMenuSections retrieves the menu grouped into nested categories in display order.
+// Items without a category are returned last in an "Uncategorized" section.
+// A non-zero categoryID returns only that category and its subcategories, and a non-zero
+// at keeps only the items available at that moment. Items are translated into locale.
+func (s *MenuService) GetMenuSections(categoryID int, at time.Time, locale string) ([]models.MenuCategory, error) {
+	categories, err := s.menuRepo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	MenuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	MenuItems = activeItems(MenuItems)
+	if !at.IsZero() {
+		MenuItems = filterAvailable(MenuItems, at)
+	}
+	if MenuItems, err = s.translate(MenuItems, locale); err != nil {
+		return nil, err
+	}
+
+	// Items come back already sorted by position
+	itemsByCategory := make(map[int][]models.MenuItem)
+	for _, item := range MenuItems {
+		itemsByCategory[item.CategoryID] = append(itemsByCategory[item.CategoryID], item)
+	}
+
+	var build func(parentID int) []models.MenuCategory
+	build = func(parentID int) []models.MenuCategory {
+		var sections []models.MenuCategory
+		for _, category := range categories {
+			if category.ParentID != parentID {
+				continue
+			}
+			category.Items = itemsByCategory[category.ID]
+			category.Subcategories = build(category.ID)
+			sections = append(sections, category)
+		}
+		return sections
+	}
+
+	if categoryID != 0 {
+		for _, category := range categories {
+			if category.ID == categoryID {
+				category.Items = itemsByCategory[category.ID]
+				category.Subcategories = build(category.ID)
+				return []models.MenuCategory{category}, nil
+			}
+		}
+		return nil, models.ErrCategoryNotFound
+	}
+
+	sections := build(0)
+	if uncategorized := itemsByCategory[0]; len(uncategorized) > 0 {
+		sections = append(sections, models.MenuCategory{Name: "Uncategorized", Items: uncategorized})
+	}
+	return sections, nil
+}
+
+// EightySixMenuItem takes a menu item off sale until the override is lifted.
+func (s *MenuService) EightySixMenuItem(MenuItemID int, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return errors.New("a reason is required to 86 a menu item")
+	}
+	return s.menuRepo.SetEightySix(MenuItemID, strings.TrimSpace(reason))
+}
+
+// LiftEightySix puts a menu item that was 86'd back on sale.
+func (s *MenuService) LiftEightySix(MenuItemID int) error {
+	return s.menuRepo.SetEightySix(MenuItemID, "")
+}
+
+// GetCategories retrieves all menu categories in display order.
+func (s *MenuService) GetCategories() ([]models.MenuCategory, error) {
+	return s.menuRepo.GetCategories()
+}
+
+// AddCategory validates and adds a new menu category.
+func (s *MenuService) AddCategory(category models.MenuCategory) (int, error) {
+	if err := s.checkCategory(category); err != nil {
+		return 0, err
+	}
+	return s.menuRepo.AddCategory(category)
+}
+
+// UpdateCategory validates and updates a menu category.
+func (s *MenuService) UpdateCategory(category models.MenuCategory) error {
+	if err := s.checkCategory(category); err != nil {
+		return err
+	}
+	return s.menuRepo.UpdateCategory(category)
+}
+
+// DeleteCategory removes a menu category. Its items and subcategories are kept without a category.
+func (s *MenuService) DeleteCategory(categoryID int) error {
+	return s.menuRepo.DeleteCategory(categoryID)
+}
+
+// checkCategory validates the name and parent of a category. The parent must exist and
+// must not be the category itself or one of its subcategories.
+func (s *MenuService) checkCategory(category models.MenuCategory) error {
+	if strings.TrimSpace(category.Name) == "" {
+		return errors.New("category name is required")
+	}
+	if category.ParentID == 0 {
+		return nil
+	}
+	categories, err := s.menuRepo.GetCategories()
+	if err != nil {
+		return err
+	}
+	if category.ID != 0 && categoryWithDescendants(categories, category.ID)[category.ParentID] {
+		return models.ErrCategoryCycle
+	}
+	for _, v := range categories {
+		if v.ID == category.ParentID {
+			return nil
+		}
+	}
+	return errors.New("parent category not found")
+}
+
+// categoryWithDescendants returns the set of IDs made of the category and all of its subcategories.
+// The set is empty if the category does not exist.
+func categoryWithDescendants(categories []models.MenuCategory, categoryID int) map[int]bool {
+	included := make(map[int]bool)
+	for _, category := range categories {
+		if category.ID == categoryID {
+			included[categoryID] = true
+		}
+	}
+	if len(included) == 0 {
+		return included
+	}
+	// Keep adding children until no new category is found
+	for grown := true; grown; {
+		grown = false
+		for _, category := range categories {
+			if included[category.ParentID] && !included[category.ID] {
+				included[category.ID] = true
+				grown = true
+			}
+		}
+	}
+	return included
 }
 
 // CheckNewMenu validates the details of a new menu item before adding it to the menu.
@@ -190,13 +545,89 @@ func (s *MenuService) CheckNewMenu(MenuItem models.MenuItem) error {
 	}
 	// Validate that each ingredient's quantity is valid (not negative)
 	for _, ingredient := range MenuItem.Ingredients {
-		if ingredient.Quantity < 0 {
-			return errors.New("new menu item's quantity is awkward") // Quantity should not be negative
+		if ingredient.Quantity < 0 || ingredient.Quantity != roundQuantity(ingredient.Quantity) {
+			return errors.New("new menu item's quantity is awkward") // Quantity should not be negative or finer than stock is kept
 		}
 	}
+	// Validate channel price overrides and packaging
+	for channel, price := range MenuItem.ChannelPrices {
+		if !isValidChannel(channel) {
+			return models.ErrInvalidChannel
+		}
+		if price <= 0 {
+			return errors.New("new menu item's channel price must be greater than zero")
+		}
+	}
+	for _, packaging := range MenuItem.Packaging {
+		if packaging.Quantity <= 0 || packaging.Quantity != roundQuantity(packaging.Quantity) {
+			return errors.New("new menu item's packaging quantity must be greater than zero with at most three decimals")
+		}
+		if packaging.Unit != "" {
+			return errors.New("new menu item's packaging is counted in the stock unit of each item")
+		}
+	}
+	// Validate the category the item is placed in
+	if MenuItem.CategoryID != 0 && !s.menuRepo.CategoryExists(MenuItem.CategoryID) {
+		return models.ErrCategoryNotFound
+	}
+	if MenuItem.Position < 0 {
+		return errors.New("new menu item's position must not be negative")
+	}
+	if err := validateSchedules(MenuItem.Schedules); err != nil {
+		return err
+	}
+	if err := s.checkBundle(MenuItem); err != nil {
+		return err
+	}
 	return nil // Return nil if all validations pass
 }
 
+// checkBundle validates the slots of a bundle. Each slot holds either a fixed regular
+// product or a category to choose from, and bundles cannot contain other bundles.
+func (s *MenuService) checkBundle(MenuItem models.MenuItem) error {
+	if len(MenuItem.Bundle) == 0 {
+		return nil
+	}
+	if len(MenuItem.Ingredients) > 0 {
+		return errors.New("a bundle uses the ingredients of its components and cannot have its own")
+	}
+
+	MenuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, slot := range MenuItem.Bundle {
+		if (slot.ProductID == 0) == (slot.CategoryID == 0) {
+			return errors.New("each bundle slot needs either a product_id or a category_id")
+		}
+		if slot.Quantity < 1 {
+			return errors.New("bundle slot quantity must be greater than zero")
+		}
+		if slot.CategoryID != 0 {
+			if !s.menuRepo.CategoryExists(slot.CategoryID) {
+				return models.ErrCategoryNotFound
+			}
+			continue
+		}
+		if slot.ProductID == MenuItem.ID {
+			return errors.New("a bundle cannot contain itself")
+		}
+		found := false
+		for _, item := range MenuItems {
+			if item.ID == slot.ProductID {
+				if len(item.Bundle) > 0 {
+					return errors.New("a bundle cannot contain another bundle")
+				}
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("bundle component %d does not exist in menu", slot.ProductID)
+		}
+	}
+	return nil
+}
+
 // UpdateMenuItemImage updates the image of a menu item.
 func (s *MenuService) UpdateMenuItemImage(id int, newImagePath string) error {
 	// Retrieve the menu item by its ID
@@ -205,22 +636,23 @@ func (s *MenuService) UpdateMenuItemImage(id int, newImagePath string) error {
 		return err // Return error if the item is not found
 	}
 
-	// Remove the old image associated with the menu item
-	if err := RemoveImage(menuItem.Image); err != nil {
-		return err // Return error if image removal fails
+	// Update the menu item with the new image path
+	if err := s.menuRepo.UpdateMenuItemImageRepo(id, newImagePath); err != nil {
+		return err
 	}
 
-	// Update the menu item with the new image path
-	return s.menuRepo.UpdateMenuItemImageRepo(id, newImagePath)
+	// Remove the old image unless it is still in use
+	if menuItem.Image == newImagePath {
+		return nil
+	}
+	return s.removeUnusedImage(menuItem.Image, id)
 }
 
-// RemoveImage deletes an image from the filesystem if it's not the default image.
-func RemoveImage(imagePath string) error {
-	if imagePath != "uploads/default.jpg" {
-		// Attempt to remove the image file from the filesystem
-		if err := os.Remove(imagePath); err != nil && !os.IsNotExist(err) {
-			return err // Return error if the file cannot be removed
-		}
+// removeUnusedImage deletes an image unless another menu item shows it as well.
+func (s *MenuService) removeUnusedImage(imagePath string, menuItemID int) error {
+	used, err := s.menuRepo.ImageUsedByOthers(imagePath, menuItemID)
+	if err != nil || used {
+		return err
 	}
-	return nil // Return nil if the image removal succeeds or the file does not exist
+	return s.removeImage(imagePath)
 }
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestStockDecimals(t *testing.T) {
+	zero, one := 0, 1
+	units := []models.Unit{
+		{Code: "pc", Decimals: &zero},
+		{Code: "g", Decimals: &one},
+		{Code: "kg"},
+	}
+	items := []models.InventoryItem{
+		{IngredientID: 1, Unit: "pc"},
+		{IngredientID: 2, Unit: "g"},
+		{IngredientID: 3, Unit: "kg"},
+	}
+
+	want := map[int]int{1: 0, 2: 1, 3: 3}
+	if got := stockDecimals(items, units); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestHasSubstitute(t *testing.T) {
+	stock := map[int]float64{10: 3, 11: 0.9}
+	decimals := map[int]int{10: 0, 11: 1}
+
+	tests := []struct {
+		name        string
+		substitutes []models.IngredientSubstitute
+		needed      float64
+		want        bool
+	}{
+		{"enough of the first", []models.IngredientSubstitute{{SubstituteID: 10, Ratio: 1}}, 3, true},
+		{"rounded up past the stock", []models.IngredientSubstitute{{SubstituteID: 10, Ratio: 1.1}}, 2.8, false},
+		{"rounded up within the stock", []models.IngredientSubstitute{{SubstituteID: 10, Ratio: 0.5}}, 4.2, true},
+		{"falls through to the second", []models.IngredientSubstitute{{SubstituteID: 10, Ratio: 2}, {SubstituteID: 11, Ratio: 0.3}}, 3, true},
+		{"none has enough", []models.IngredientSubstitute{{SubstituteID: 11, Ratio: 0.31}}, 3, false},
+		{"no substitutes", nil, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasSubstitute(tt.substitutes, stock, decimals, tt.needed); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
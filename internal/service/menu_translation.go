@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"hot-coffee/models"
+)
+
+// IsSupportedLocale reports whether menu content can be served and searched in locale.
+func IsSupportedLocale(locale string) bool {
+	_, ok := models.SearchConfigs[locale]
+	return ok
+}
+
+// GetTranslatedMenuItem retrieves a menu item with its name and description in locale.
+func (s *MenuService) GetTranslatedMenuItem(MenuItemID int, locale string) (models.MenuItem, error) {
+	menuItem, err := s.GetMenuItem(MenuItemID)
+	if err != nil {
+		return models.MenuItem{}, err
+	}
+	translated, err := s.translate([]models.MenuItem{menuItem}, locale)
+	if err != nil {
+		return models.MenuItem{}, err
+	}
+	return translated[0], nil
+}
+
+// GetMenuItemTranslations retrieves every translation of a menu item.
+func (s *MenuService) GetMenuItemTranslations(MenuItemID int) ([]models.MenuItemTranslation, error) {
+	if !s.menuRepo.MenuCheckByIDRepo(MenuItemID) {
+		return nil, models.ErrMenuItemNotFound
+	}
+	return s.menuRepo.GetMenuItemTranslations(MenuItemID)
+}
+
+// SaveTranslation adds or replaces the translation of a menu item into a locale other than the default one.
+func (s *MenuService) SaveTranslation(translation models.MenuItemTranslation) error {
+	if !IsSupportedLocale(translation.Locale) {
+		return models.ErrUnsupportedLocale
+	}
+	if translation.Locale == models.DefaultLocale {
+		return errors.New("the default locale is edited on the menu item itself")
+	}
+	translation.Name = strings.TrimSpace(translation.Name)
+	translation.Description = strings.TrimSpace(translation.Description)
+	if translation.Name == "" || translation.Description == "" {
+		return errors.New("translation name and description must not be empty")
+	}
+	if len([]rune(translation.Name)) > 100 {
+		return errors.New("translation name must not be longer than 100 characters")
+	}
+	if !s.menuRepo.MenuCheckByIDRepo(translation.ProductID) {
+		return models.ErrMenuItemNotFound
+	}
+	return s.menuRepo.SaveTranslation(translation)
+}
+
+// DeleteTranslation removes the translation of a menu item into a locale.
+func (s *MenuService) DeleteTranslation(MenuItemID int, locale string) error {
+	if !s.menuRepo.MenuCheckByIDRepo(MenuItemID) {
+		return models.ErrMenuItemNotFound
+	}
+	return s.menuRepo.DeleteTranslation(MenuItemID, locale)
+}
+
+// translate replaces the name and description of menu items with their translation into locale.
+// Items without a translation keep the default text.
+func (s *MenuService) translate(MenuItems []models.MenuItem, locale string) ([]models.MenuItem, error) {
+	if locale == "" || locale == models.DefaultLocale {
+		return MenuItems, nil
+	}
+	translations, err := s.menuRepo.GetTranslations(locale)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range MenuItems {
+		if translation, ok := translations[item.ID]; ok {
+			MenuItems[i].Name = translation.Name
+			MenuItems[i].Description = translation.Description
+		}
+	}
+	return MenuItems, nil
+}
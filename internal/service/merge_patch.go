@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"hot-coffee/models"
+)
+
+// mergePatch applies a JSON Merge Patch (RFC 7386) to the JSON form of current and decodes the
+// result into target. Members of the patch replace those of current, null removes a member and
+// nested objects are merged the same way. Arrays are replaced as a whole.
+func mergePatch(current interface{}, patch []byte, target interface{}) error {
+	var patchValue interface{}
+	if err := decodeJSON(patch, &patchValue); err != nil {
+		return fmt.Errorf("%w: %w", models.ErrInvalidPatch, err)
+	}
+	if _, ok := patchValue.(map[string]interface{}); !ok {
+		return fmt.Errorf("%w: the patch must be a JSON object", models.ErrInvalidPatch)
+	}
+
+	document, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var currentValue interface{}
+	if err := decodeJSON(document, &currentValue); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergeValue(currentValue, patchValue))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(merged, target); err != nil {
+		return fmt.Errorf("%w: %w", models.ErrInvalidPatch, err)
+	}
+	return nil
+}
+
+// mergeValue merges a decoded patch into a decoded document as described in RFC 7386.
+func mergeValue(document, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	documentObject, ok := document.(map[string]interface{})
+	if !ok {
+		documentObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(documentObject, name)
+			continue
+		}
+		documentObject[name] = mergeValue(documentObject[name], value)
+	}
+	return documentObject
+}
+
+// decodeJSON decodes data keeping numbers as written, so large IDs and prices survive the round trip.
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after the JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestMergePatch(t *testing.T) {
+	current := map[string]interface{}{
+		"name":  "Latte",
+		"price": 3.5,
+		"notes": map[string]interface{}{"size": "large", "milk": "oat"},
+		"tags":  []interface{}{"hot", "milk"},
+	}
+
+	tests := []struct {
+		name    string
+		patch   string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:  "replace a member",
+			patch: `{"price": 4}`,
+			want: map[string]interface{}{
+				"name": "Latte", "price": 4.0,
+				"notes": map[string]interface{}{"size": "large", "milk": "oat"},
+				"tags":  []interface{}{"hot", "milk"},
+			},
+		},
+		{
+			name:  "null removes a member",
+			patch: `{"tags": null}`,
+			want: map[string]interface{}{
+				"name": "Latte", "price": 3.5,
+				"notes": map[string]interface{}{"size": "large", "milk": "oat"},
+			},
+		},
+		{
+			name:  "nested objects are merged",
+			patch: `{"notes": {"milk": null, "sugar": 2}}`,
+			want: map[string]interface{}{
+				"name": "Latte", "price": 3.5,
+				"notes": map[string]interface{}{"size": "large", "sugar": 2.0},
+				"tags":  []interface{}{"hot", "milk"},
+			},
+		},
+		{
+			name:  "arrays are replaced",
+			patch: `{"tags": ["iced"]}`,
+			want: map[string]interface{}{
+				"name": "Latte", "price": 3.5,
+				"notes": map[string]interface{}{"size": "large", "milk": "oat"},
+				"tags":  []interface{}{"iced"},
+			},
+		},
+		{name: "not an object", patch: `["price"]`, wantErr: true},
+		{name: "invalid JSON", patch: `{"price": }`, wantErr: true},
+		{name: "trailing data", patch: `{} {}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			err := mergePatch(current, []byte(tt.patch), &got)
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrInvalidPatch) {
+					t.Fatalf("got error %v, want %v", err, models.ErrInvalidPatch)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	current := models.Order{
+		ID:           7,
+		CustomerName: "Ann",
+		Items:        []models.OrderItem{{LineID: 1, ProductID: 2, Quantity: 1, Price: 3.5, Status: models.StatusItemQueued}},
+		Status:       "open",
+		Notes:        map[string]interface{}{"table": "window"},
+		Channel:      models.ChannelDineIn,
+		CreatedAt:    "2026-10-19T09:00:00Z",
+		Version:      3,
+	}
+
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr bool
+	}{
+		{"customer name", `{"customer_name": "Bob"}`, false},
+		{"notes removed", `{"notes": null}`, false},
+		{"items replaced", `{"items": [{"product_id": 4, "quantity": 2}]}`, false},
+		{"unchanged read-only field", `{"status": "open", "customer_name": "Bob"}`, false},
+		{"status", `{"status": "closed"}`, true},
+		{"channel", `{"channel": "delivery"}`, true},
+		{"order id", `{"order_id": 8}`, true},
+		{"version", `{"version": 4}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var merged models.Order
+			if err := mergePatch(current, []byte(tt.patch), &merged); err != nil {
+				t.Fatal(err)
+			}
+			err := checkReadOnly(current, merged)
+			if tt.wantErr != errors.Is(err, models.ErrInvalidPatch) {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -29,7 +32,11 @@ func NewOrderService(orderRepo dal.OrderRepository, menuRepo dal.MenuRepository,
 }
 
 // AddOrder processes a single order by validating and adding it to the repository.
-func (s *OrderService) AddOrder(order models.Order) (models.BatchOrderInfo, []models.BatchOrderInventoryUpdate, error) {
+func (s *OrderService) AddOrder(order models.Order, change models.ChangeInfo) (models.BatchOrderInfo, []models.BatchOrderInventoryUpdate, error) {
+	// Orders without a channel are treated as dine-in
+	if order.Channel == "" {
+		order.Channel = models.ChannelDineIn
+	}
 	// Validate the order to ensure the provided data is correct
 	if err := validateOrder(order); err != nil {
 		// If validation fails, return the error message and order rejection status
@@ -42,12 +49,23 @@ func (s *OrderService) AddOrder(order models.Order) (models.BatchOrderInfo, []mo
 		}, []models.BatchOrderInventoryUpdate{}, err
 	}
 
+	// Items that were taken off the menu cannot be ordered
+	if err := s.checkAvailability(order.Items); err != nil {
+		return models.BatchOrderInfo{
+			OrderID:      order.ID,
+			CustomerName: order.CustomerName,
+			Status:       models.StatusOrderRejected,
+			Reason:       err.Error(),
+			Total:        0,
+		}, []models.BatchOrderInventoryUpdate{}, err
+	}
+
 	// If validation passes, proceed to add the order to the repository
-	return s.orderRepo.Add(order)
+	return s.orderRepo.Add(order, change)
 }
 
 // BulkOrders processes multiple orders in a batch, updating the inventory and sales summary.
-func (s *OrderService) BulkOrders(orders []models.Order) (models.BatchOrdersResponce, error) {
+func (s *OrderService) BulkOrders(orders []models.Order, change models.ChangeInfo) (models.BatchOrdersResponce, error) {
 	proccesedOrdersInfo := []models.BatchOrderInfo{} // Store info about each processed order
 	summary := models.BatchOrderSummary{
 		TotalOrders: len(orders),
@@ -57,7 +75,7 @@ func (s *OrderService) BulkOrders(orders []models.Order) (models.BatchOrdersResp
 	invCheckMap := make(map[int]models.BatchOrderInventoryUpdate)
 	for _, order := range orders {
 		// Process each order individually
-		orderInfo, inventoryInfo, err := s.AddOrder(order)
+		orderInfo, inventoryInfo, err := s.AddOrder(order, change)
 		if err != nil {
 			log.Printf("Error: %v", err)
 		}
@@ -75,7 +93,7 @@ func (s *OrderService) BulkOrders(orders []models.Order) (models.BatchOrdersResp
 		for _, v := range inventoryInfo {
 			if value, ok := invCheckMap[v.IngredientID]; ok {
 				// If ingredient already exists in the map, accumulate the quantity used
-				v.Quantity_used += value.Quantity_used
+				v.Quantity_used = roundQuantity(v.Quantity_used + value.Quantity_used)
 				invCheckMap[v.IngredientID] = v
 			} else {
 				invCheckMap[v.IngredientID] = v
@@ -83,7 +101,7 @@ func (s *OrderService) BulkOrders(orders []models.Order) (models.BatchOrdersResp
 		}
 
 		// Close the order in the repository once it has been processed
-		err = s.orderRepo.CloseOrderRepo(orderInfo.OrderID)
+		err = s.orderRepo.CloseOrderRepo(orderInfo.OrderID, change)
 		if err != nil && err != models.ErrOrderNotFound {
 			return models.BatchOrdersResponce{}, err
 		}
@@ -102,52 +120,338 @@ func (s *OrderService) BulkOrders(orders []models.Order) (models.BatchOrdersResp
 	return result, nil
 }
 
+// GetOrderHistory retrieves every recorded change of an order, oldest first. An order without
+// recorded changes has an empty history. Deleted orders are not found, like in GetOrder; their
+// history stays in the database for audits.
+func (s *OrderService) GetOrderHistory(OrderID int) ([]models.OrderChange, error) {
+	if _, err := s.orderRepo.GetOrderByID(OrderID); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetHistory(OrderID)
+}
+
 // GetAllOrders retrieves all orders from the order repository.
 func (s *OrderService) GetAllOrders() ([]models.Order, error) {
-	return s.orderRepo.GetAll()
+	orders, err := s.orderRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		setFulfillment(&orders[i])
+	}
+	return orders, nil
 }
 
 // GetOrder retrieves a specific order by its ID from the repository.
 func (s *OrderService) GetOrder(OrderID int) (models.Order, error) {
-	return s.orderRepo.GetOrderByID(OrderID)
+	order, err := s.orderRepo.GetOrderByID(OrderID)
+	if err != nil {
+		return models.Order{}, err
+	}
+	setFulfillment(&order)
+	return order, nil
+}
+
+// AdvanceOrderItem moves a single order line to its next fulfillment status.
+func (s *OrderService) AdvanceOrderItem(OrderID, LineID int, change models.ChangeInfo) (models.OrderItem, error) {
+	return s.orderRepo.SetItemStatus(OrderID, LineID, "", change)
+}
+
+// SetOrderItemStatus sets the fulfillment status of a single order line, e.g. to send it back to the kitchen.
+func (s *OrderService) SetOrderItemStatus(OrderID, LineID int, status string, change models.ChangeInfo) (models.OrderItem, error) {
+	valid := false
+	for _, v := range models.ItemStatuses {
+		if v == status {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return models.OrderItem{}, models.ErrInvalidStatus
+	}
+	return s.orderRepo.SetItemStatus(OrderID, LineID, status, change)
+}
+
+// setFulfillment derives the overall fulfillment status of an order from its lines
+// and counts how many lines are in each status.
+func setFulfillment(order *models.Order) {
+	if len(order.Items) == 0 {
+		return
+	}
+	order.Progress = make(map[string]int)
+	for _, item := range order.Items {
+		order.Progress[item.Status]++
+	}
+
+	total := len(order.Items)
+	switch {
+	case order.Progress[models.StatusItemServed] == total:
+		order.Fulfillment = models.StatusItemServed
+	case order.Progress[models.StatusItemDone]+order.Progress[models.StatusItemServed] == total:
+		order.Fulfillment = models.StatusOrderReady
+	case order.Progress[models.StatusItemQueued] == total:
+		order.Fulfillment = models.StatusItemQueued
+	default:
+		order.Fulfillment = models.StatusItemInProgress
+	}
 }
 
 // UpdateOrder updates an existing order in the repository.
-func (s *OrderService) UpdateOrder(updatedOrder models.Order, OrderID string) error {
+func (s *OrderService) UpdateOrder(updatedOrder models.Order, OrderID string, change models.ChangeInfo) error {
 	// Validate the updated order
 	if err := validateOrder(updatedOrder); err != nil {
 		return err
 	}
+	if err := s.checkAvailability(updatedOrder.Items); err != nil {
+		return err
+	}
 	// Save the updated order to the repository
-	return s.orderRepo.SaveUpdatedOrder(updatedOrder, OrderID)
+	return s.orderRepo.SaveUpdatedOrder(updatedOrder, OrderID, change)
+}
+
+// PatchOrder applies a JSON Merge Patch to an order and returns the merged order for validation
+// and saving. A non-zero version must match the order's current version, and the merged order
+// keeps the version it was read at. Only the customer name, notes and items can be changed;
+// a patch that changes any other field is rejected. The price, status and line ID of the
+// patched items are ignored, as in PutOrder.
+func (s *OrderService) PatchOrder(OrderID int, patch []byte, version int) (models.Order, error) {
+	current, err := s.GetOrder(OrderID)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if version != 0 && version != current.Version {
+		return models.Order{}, models.ErrVersionConflict
+	}
+
+	var merged models.Order
+	if err := mergePatch(current, patch, &merged); err != nil {
+		return models.Order{}, err
+	}
+	if err := checkReadOnly(current, merged); err != nil {
+		return models.Order{}, err
+	}
+	return merged, nil
+}
+
+// checkReadOnly returns models.ErrInvalidPatch if merged differs from current in anything
+// but the customer name, notes and items. Both are compared in their JSON form, the way
+// the patch was applied.
+func checkReadOnly(current, merged models.Order) error {
+	merged.CustomerName, merged.Notes, merged.Items = current.CustomerName, current.Notes, current.Items
+	want, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	got, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("%w: only customer_name, notes and items can be changed", models.ErrInvalidPatch)
+	}
+	return nil
+}
+
+// SplitOrder divides an open order into checks by line item, by seat or into equal parts.
+// Checks only divide the bill: the order keeps its items, so inventory is not deducted again.
+func (s *OrderService) SplitOrder(OrderID int, request models.SplitRequest, change models.ChangeInfo) ([]models.Check, error) {
+	order, err := s.orderRepo.GetOrderByID(OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == "closed" {
+		return nil, models.ErrOrderClosed
+	}
+	if len(order.Checks) > 0 {
+		return nil, models.ErrOrderSplit
+	}
+	if len(order.Items) == 0 {
+		return nil, errors.New("the order has no items to split")
+	}
+
+	var checks []models.Check
+	switch request.Mode {
+	case models.SplitByItem:
+		checks, err = splitByItem(order.Items, request.Checks)
+	case models.SplitBySeat:
+		checks, err = splitBySeat(order.Items)
+	case models.SplitEvenly:
+		checks, err = splitEvenly(order.Items, request.Parts)
+	default:
+		err = errors.New("invalid split mode, must be 'item', 'seat' or 'even'")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.orderRepo.CreateChecks(OrderID, checks, change)
+}
+
+// CloseCheck pays a single check of a split order. The order is closed once all of its checks are paid.
+func (s *OrderService) CloseCheck(OrderID, CheckID int, change models.ChangeInfo) (bool, error) {
+	return s.orderRepo.PayCheck(OrderID, CheckID, change)
+}
+
+// splitByItem builds one check per requested group of lines. Every line of the order must be
+// assigned exactly once across the groups.
+func splitByItem(items []models.OrderItem, groups [][]models.OrderItem) ([]models.Check, error) {
+	if len(groups) < 2 {
+		return nil, errors.New("split by item needs at least two checks")
+	}
+
+	type lineKey struct{ productID, seat int }
+	remaining := make(map[lineKey]int)
+	prices := make(map[lineKey]float64)
+	for _, item := range items {
+		key := lineKey{item.ProductID, item.Seat}
+		remaining[key] += item.Quantity
+		prices[key] = item.Price
+	}
+
+	checks := make([]models.Check, 0, len(groups))
+	for i, group := range groups {
+		if len(group) == 0 {
+			return nil, fmt.Errorf("check %d has no items", i+1)
+		}
+		check := models.Check{Label: fmt.Sprintf("Check %d", i+1)}
+		var cents int64
+		for _, item := range group {
+			key := lineKey{item.ProductID, item.Seat}
+			left, ok := remaining[key]
+			if !ok {
+				return nil, fmt.Errorf("product %d (seat %d) is not on the order", item.ProductID, item.Seat)
+			}
+			if item.Quantity < 1 || item.Quantity > left {
+				return nil, fmt.Errorf("invalid quantity %d for product %d (seat %d), %d left to assign", item.Quantity, item.ProductID, item.Seat, left)
+			}
+			remaining[key] = left - item.Quantity
+			item.Price = prices[key]
+			cents += toCents(float64(item.Quantity) * item.Price)
+			check.Items = append(check.Items, item)
+		}
+		check.Amount = fromCents(cents)
+		checks = append(checks, check)
+	}
+
+	for key, left := range remaining {
+		if left > 0 {
+			return nil, fmt.Errorf("%d of product %d (seat %d) not assigned to any check", left, key.productID, key.seat)
+		}
+	}
+	return checks, nil
+}
+
+// splitBySeat builds one check per seat. Lines without a seat go to a shared check.
+// An order whose lines all sit on one seat, or none, cannot be split this way.
+func splitBySeat(items []models.OrderItem) ([]models.Check, error) {
+	var checks []models.Check
+	bySeat := make(map[int]int) // seat -> index in checks
+	cents := make(map[int]int64)
+	for _, item := range items {
+		idx, ok := bySeat[item.Seat]
+		if !ok {
+			label := fmt.Sprintf("Seat %d", item.Seat)
+			if item.Seat == 0 {
+				label = "Shared"
+			}
+			checks = append(checks, models.Check{Label: label})
+			idx = len(checks) - 1
+			bySeat[item.Seat] = idx
+		}
+		checks[idx].Items = append(checks[idx].Items, item)
+		cents[idx] += toCents(float64(item.Quantity) * item.Price)
+	}
+	if len(checks) < 2 {
+		return nil, errors.New("split by seat needs items on at least two seats")
+	}
+	for idx := range checks {
+		checks[idx].Amount = fromCents(cents[idx])
+	}
+	return checks, nil
+}
+
+// splitEvenly divides the order total into equal parts. Leftover cents go to the first checks,
+// so the parts always add up to the order total.
+func splitEvenly(items []models.OrderItem, parts int) ([]models.Check, error) {
+	if parts < 2 {
+		return nil, errors.New("an even split needs at least two parts")
+	}
+
+	var total int64
+	for _, item := range items {
+		total += toCents(float64(item.Quantity) * item.Price)
+	}
+	if int64(parts) > total {
+		return nil, errors.New("cannot split the order into more parts than cents in its total")
+	}
+
+	share, rest := total/int64(parts), total%int64(parts)
+	checks := make([]models.Check, parts)
+	for i := range checks {
+		amount := share
+		if int64(i) < rest {
+			amount++
+		}
+		checks[i] = models.Check{
+			Label:  fmt.Sprintf("Part %d of %d", i+1, parts),
+			Amount: fromCents(amount),
+		}
+	}
+	return checks, nil
+}
+
+// toCents converts an amount of money to whole cents.
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromCents converts whole cents back to an amount of money.
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
 }
 
 // GetTotalSales calculates the total sales by summing up the quantities of all items in all orders.
-func (s *OrderService) GetTotalSales() (models.TotalSales, error) {
+// A non-empty channel limits the total to orders placed through that channel.
+func (s *OrderService) GetTotalSales(channel string) (models.TotalSales, error) {
+	if channel != "" && !isValidChannel(channel) {
+		return models.TotalSales{}, models.ErrInvalidChannel
+	}
+
 	existingOrders, err := s.orderRepo.GetAll()
 	if err != nil {
 		return models.TotalSales{}, err
 	}
 
-	totalSales := models.TotalSales{}
+	totalSales := models.TotalSales{
+		ByChannel: make(map[string]int),
+	}
 
-	// Sum the quantities of items in each order
+	// Sum the quantities of items in each order, per channel and overall
 	for _, order := range existingOrders {
+		if channel != "" && order.Channel != channel {
+			continue
+		}
 		for _, item := range order.Items {
 			totalSales.TotalSales += item.Quantity
+			totalSales.ByChannel[order.Channel] += item.Quantity
 		}
 	}
+
+	totalSales.ByCategory, err = s.orderRepo.GetSalesByCategory(channel)
+	if err != nil {
+		return models.TotalSales{}, err
+	}
 	return totalSales, nil
 }
 
-// DeleteOrderByID deletes a specific order by its ID.
-func (s *OrderService) DeleteOrderByID(OrderID int) error {
-	return s.orderRepo.DeleteOrder(OrderID)
+// DeleteOrderByID deletes a specific order by its ID. A non-zero version must match the order's current version.
+func (s *OrderService) DeleteOrderByID(OrderID, version int, change models.ChangeInfo) error {
+	return s.orderRepo.DeleteOrder(OrderID, version, change)
 }
 
 // CloseOrder marks an order as closed in the repository.
-func (s *OrderService) CloseOrder(OrderID int) error {
-	return s.orderRepo.CloseOrderRepo(OrderID)
+func (s *OrderService) CloseOrder(OrderID int, change models.ChangeInfo) error {
+	return s.orderRepo.CloseOrderRepo(OrderID, change)
 }
 
 // GetNumberOfItems returns the number of ordered items between the provided date range.
@@ -250,12 +554,73 @@ func validateOrder(order models.Order) error {
 		return errors.New("customer name is required")
 	}
 
+	if order.Channel != "" && !isValidChannel(order.Channel) {
+		return models.ErrInvalidChannel
+	}
+
 	// Ensure that each item has a valid quantity
 	for _, order := range order.Items {
 		if order.Quantity < 1 {
 			return errors.New("quantity a product must be greater than zero")
 		}
+		if order.Seat < 0 {
+			return errors.New("seat number must not be negative")
+		}
 	}
 
 	return nil
 }
+
+// checkAvailability makes sure none of the ordered menu items is archived, 86'd or outside its availability windows.
+func (s *OrderService) checkAvailability(items []models.OrderItem) error {
+	now := time.Now()
+	var ids []int
+	for _, v := range items {
+		ids = append(ids, v.ProductID)
+		for _, choice := range v.Choices {
+			ids = append(ids, choice.ProductID)
+		}
+	}
+	byID, err := s.menuRepo.GetAvailability(ids)
+	if err != nil {
+		return err
+	}
+	for _, v := range items {
+		// The products chosen for a bundle have to be available as well
+		productIDs := []int{v.ProductID}
+		for _, choice := range v.Choices {
+			productIDs = append(productIDs, choice.ProductID)
+		}
+		for _, slot := range byID[v.ProductID].Bundle {
+			if slot.ProductID != 0 {
+				productIDs = append(productIDs, slot.ProductID)
+			}
+		}
+		for _, productID := range productIDs {
+			item, ok := byID[productID]
+			if !ok {
+				return models.ErrMenuItemNotFound
+			}
+			if item.ArchivedAt != nil {
+				return fmt.Errorf("%w: %s is no longer on the menu", models.ErrItemUnavailable, item.Name)
+			}
+			if item.EightySix != nil {
+				return fmt.Errorf("%w: %s is 86'd (%s)", models.ErrItemUnavailable, item.Name, item.EightySix.Reason)
+			}
+			if !isAvailableAt(item, now) {
+				return fmt.Errorf("%w: %s is not sold at %s, it is available %s", models.ErrItemUnavailable, item.Name, now.Format("Mon 15:04"), describeSchedules(item.Schedules))
+			}
+		}
+	}
+	return nil
+}
+
+// isValidChannel checks that the channel is one of the supported order channels.
+func isValidChannel(channel string) bool {
+	for _, v := range models.Channels {
+		if v == channel {
+			return true
+		}
+	}
+	return false
+}
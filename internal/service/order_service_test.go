@@ -0,0 +1,100 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestSplitBySeat(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []models.OrderItem
+		labels  []string
+		amounts []float64
+		wantErr bool
+	}{
+		{
+			name: "two seats and a shared line",
+			items: []models.OrderItem{
+				{ProductID: 1, Quantity: 2, Seat: 1, Price: 3.5},
+				{ProductID: 2, Quantity: 1, Seat: 2, Price: 4.25},
+				{ProductID: 3, Quantity: 1, Price: 6},
+				{ProductID: 4, Quantity: 3, Seat: 1, Price: 0.1},
+			},
+			labels:  []string{"Seat 1", "Seat 2", "Shared"},
+			amounts: []float64{7.3, 4.25, 6},
+		},
+		{
+			name: "everything shared",
+			items: []models.OrderItem{
+				{ProductID: 1, Quantity: 2, Price: 3.5},
+				{ProductID: 2, Quantity: 1, Price: 4.25},
+			},
+			wantErr: true,
+		},
+		{
+			name: "a single seat",
+			items: []models.OrderItem{
+				{ProductID: 1, Quantity: 1, Seat: 3, Price: 3.5},
+				{ProductID: 2, Quantity: 1, Seat: 3, Price: 4.25},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := splitBySeat(tt.items)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d checks", len(checks))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var labels []string
+			var amounts []float64
+			for _, check := range checks {
+				labels = append(labels, check.Label)
+				amounts = append(amounts, check.Amount)
+			}
+			if !reflect.DeepEqual(labels, tt.labels) || !reflect.DeepEqual(amounts, tt.amounts) {
+				t.Errorf("got %v %v, want %v %v", labels, amounts, tt.labels, tt.amounts)
+			}
+		})
+	}
+}
+
+func TestSplitEvenly(t *testing.T) {
+	items := []models.OrderItem{{ProductID: 1, Quantity: 1, Price: 10}}
+
+	tests := []struct {
+		name    string
+		parts   int
+		amounts []float64
+		wantErr bool
+	}{
+		{"two parts", 2, []float64{5, 5}, false},
+		{"leftover cents go first", 3, []float64{3.34, 3.33, 3.33}, false},
+		{"one part", 1, nil, true},
+		{"more parts than cents", 1001, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := splitEvenly(items, tt.parts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			var amounts []float64
+			for _, check := range checks {
+				amounts = append(amounts, check.Amount)
+			}
+			if !reflect.DeepEqual(amounts, tt.amounts) {
+				t.Errorf("got %v, want %v", amounts, tt.amounts)
+			}
+		})
+	}
+}
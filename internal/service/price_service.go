@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// PriceService exposes the price log and applies scheduled price changes.
+type PriceService struct {
+	priceRepo dal.PriceRepository // Repository for the price log and scheduled changes.
+	menuRepo  dal.MenuRepository  // Repository for checking menu items and categories.
+}
+
+// NewPriceService creates and returns a new instance of PriceService with the given repositories.
+func NewPriceService(priceRepo dal.PriceRepository, menuRepo dal.MenuRepository) *PriceService {
+	return &PriceService{priceRepo: priceRepo, menuRepo: menuRepo}
+}
+
+// GetItemPriceHistory retrieves every price change of a menu item, newest first.
+func (s *PriceService) GetItemPriceHistory(menuItemID int) ([]models.PriceChange, error) {
+	if !s.menuRepo.MenuCheckByIDRepo(menuItemID) {
+		return nil, models.ErrMenuItemNotFound
+	}
+	return s.priceRepo.GetHistory(menuItemID, time.Time{}, time.Now())
+}
+
+// GetPriceHistory retrieves the store-wide price change log between from and to.
+// A zero from starts at the beginning and a zero to ends now.
+func (s *PriceService) GetPriceHistory(from, to time.Time) ([]models.PriceChange, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if to.Before(from) {
+		return nil, errors.New("from must be before to")
+	}
+	return s.priceRepo.GetHistory(0, from, to)
+}
+
+// SchedulePriceChange validates and stores a price change to be applied at its effective time.
+func (s *PriceService) SchedulePriceChange(change models.ScheduledPriceChange) (int, error) {
+	switch change.Mode {
+	case models.PriceChangePercent:
+		if change.Value <= -100 {
+			return 0, errors.New("a percent change must be greater than -100")
+		}
+	case models.PriceChangeAmount:
+	case models.PriceChangeSet:
+		if change.Value <= 0 {
+			return 0, errors.New("a new price must be greater than zero")
+		}
+	default:
+		return 0, errors.New("invalid mode, must be 'percent', 'amount' or 'set'")
+	}
+	if change.Value == 0 && change.Mode != models.PriceChangeSet {
+		return 0, errors.New("the price change value must not be zero")
+	}
+	if change.EffectiveAt.IsZero() {
+		return 0, errors.New("effective_at is required")
+	}
+	if change.EffectiveAt.Before(time.Now()) {
+		return 0, errors.New("effective_at must not be in the past")
+	}
+	if change.CategoryID != 0 && !s.menuRepo.CategoryExists(change.CategoryID) {
+		return 0, models.ErrCategoryNotFound
+	}
+	for _, id := range change.ProductIDs {
+		if !s.menuRepo.MenuCheckByIDRepo(id) {
+			return 0, models.ErrMenuItemNotFound
+		}
+	}
+	return s.priceRepo.AddScheduledChange(change)
+}
+
+// GetScheduledPriceChanges retrieves scheduled price changes, optionally only those with the given status.
+func (s *PriceService) GetScheduledPriceChanges(status string) ([]models.ScheduledPriceChange, error) {
+	switch status {
+	case "", models.StatusPriceChangePending, models.StatusPriceChangeApplied,
+		models.StatusPriceChangeFailed, models.StatusPriceChangeCancelled:
+		return s.priceRepo.GetScheduledChanges(status)
+	}
+	return nil, errors.New("invalid status, must be 'pending', 'applied', 'failed' or 'cancelled'")
+}
+
+// CancelScheduledPriceChange cancels a price change that has not been applied yet.
+func (s *PriceService) CancelScheduledPriceChange(id int) error {
+	return s.priceRepo.CancelScheduledChange(id)
+}
+
+// StartPriceScheduler applies due price changes right away and then every interval in the background.
+func (s *PriceService) StartPriceScheduler(interval time.Duration, logger *slog.Logger) {
+	apply := func() {
+		applied, err := s.priceRepo.ApplyDueChanges(time.Now())
+		if err != nil {
+			logger.Error("Could not apply scheduled price changes", "error", err)
+		}
+		if len(applied) > 0 {
+			logger.Info("Applied scheduled price changes", "ids", applied)
+		}
+	}
+
+	go func() {
+		apply()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			apply()
+		}
+	}()
+}
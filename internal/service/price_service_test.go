@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"hot-coffee/models"
+)
+
+func TestSchedulePriceChangeValidation(t *testing.T) {
+	s := &PriceService{}
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		change models.ScheduledPriceChange
+	}{
+		{"past effective_at", models.ScheduledPriceChange{EffectiveAt: time.Now().Add(-time.Hour), Mode: models.PriceChangePercent, Value: 10}},
+		{"missing effective_at", models.ScheduledPriceChange{Mode: models.PriceChangePercent, Value: 10}},
+		{"unknown mode", models.ScheduledPriceChange{EffectiveAt: future, Mode: "double", Value: 10}},
+		{"percent at -100", models.ScheduledPriceChange{EffectiveAt: future, Mode: models.PriceChangePercent, Value: -100}},
+		{"zero amount", models.ScheduledPriceChange{EffectiveAt: future, Mode: models.PriceChangeAmount}},
+		{"zero price", models.ScheduledPriceChange{EffectiveAt: future, Mode: models.PriceChangeSet}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.SchedulePriceChange(tt.change); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"hot-coffee/models"
+)
+
+// GetRecipeVersions retrieves every version of a menu item's recipe, oldest first.
+func (s *MenuService) GetRecipeVersions(menuItemID int) ([]models.RecipeVersion, error) {
+	if !s.menuRepo.MenuCheckByIDRepo(menuItemID) {
+		return nil, models.ErrMenuItemNotFound
+	}
+	return s.menuRepo.GetRecipeVersions(menuItemID)
+}
+
+// AddRecipeVersion stores a new version of a menu item's recipe and returns its version number.
+// Versions with a future EffectiveFrom replace the current recipe once that date has passed.
+func (s *MenuService) AddRecipeVersion(version models.RecipeVersion) (int, error) {
+	menuItem, err := s.GetMenuItem(version.ProductID)
+	if err != nil {
+		return 0, err
+	}
+	if len(menuItem.Bundle) > 0 {
+		return 0, errors.New("bundles have no recipe of their own")
+	}
+	if len(version.Ingredients) == 0 {
+		return 0, errors.New("a recipe needs at least one ingredient")
+	}
+	seen := make(map[int]bool)
+	for _, ingredient := range version.Ingredients {
+		if ingredient.Quantity <= 0 || ingredient.Quantity != roundQuantity(ingredient.Quantity) {
+			return 0, errors.New("ingredient quantity must be positive with at most three decimals")
+		}
+		if seen[ingredient.IngredientID] {
+			return 0, errors.New("each ingredient can only be listed once")
+		}
+		seen[ingredient.IngredientID] = true
+		if !s.inventoryRepo.Exists(ingredient.IngredientID) {
+			return 0, errors.New("no ingredients for item in inventory")
+		}
+	}
+	return s.menuRepo.AddRecipeVersion(version)
+}
+
+// CompareRecipeVersions lists the ingredient differences between two versions of a recipe.
+// A zero to compares against the version in effect, and a zero from against the version before to.
+func (s *MenuService) CompareRecipeVersions(menuItemID, from, to int) (models.RecipeComparison, error) {
+	versions, err := s.GetRecipeVersions(menuItemID)
+	if err != nil {
+		return models.RecipeComparison{}, err
+	}
+
+	toIndex, fromIndex := -1, -1
+	for i, v := range versions {
+		if (to == 0 && v.Current) || (to != 0 && v.Version == to) {
+			toIndex = i
+		}
+	}
+	if toIndex == -1 {
+		return models.RecipeComparison{}, models.ErrRecipeNotFound
+	}
+	if from == 0 {
+		fromIndex = toIndex - 1
+	} else {
+		for i, v := range versions {
+			if v.Version == from {
+				fromIndex = i
+			}
+		}
+	}
+	if fromIndex == -1 {
+		return models.RecipeComparison{}, models.ErrRecipeNotFound
+	}
+
+	inventoryItems, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return models.RecipeComparison{}, err
+	}
+	names := make(map[int]string, len(inventoryItems))
+	for _, item := range inventoryItems {
+		names[item.IngredientID] = item.Name
+	}
+
+	comparison := models.RecipeComparison{
+		ProductID: menuItemID,
+		From:      versions[fromIndex],
+		To:        versions[toIndex],
+		Changes:   diffRecipes(versions[fromIndex].Ingredients, versions[toIndex].Ingredients),
+	}
+	for i := range comparison.Changes {
+		comparison.Changes[i].Name = names[comparison.Changes[i].IngredientID]
+	}
+	return comparison, nil
+}
+
+// StartRecipeScheduler puts recipe versions that came due into effect right away and then every
+// interval in the background.
+func (s *MenuService) StartRecipeScheduler(interval time.Duration, logger *slog.Logger) {
+	promote := func() {
+		promoted, err := s.menuRepo.PromoteDueRecipes()
+		if err != nil {
+			logger.Error("Could not promote scheduled recipe versions", "error", err)
+		}
+		if len(promoted) > 0 {
+			logger.Info("Promoted scheduled recipe versions", "menu_items", promoted)
+		}
+	}
+
+	go func() {
+		promote()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			promote()
+		}
+	}()
+}
+
+// diffRecipes returns the ingredients that were added, removed or changed between two recipes.
+func diffRecipes(before, after []models.MenuItemIngredient) []models.RecipeIngredientChange {
+	old := make(map[int]models.MenuItemIngredient, len(before))
+	for _, ingredient := range before {
+		old[ingredient.IngredientID] = ingredient
+	}
+
+	changes := []models.RecipeIngredientChange{}
+	for _, ingredient := range after {
+		previous, ok := old[ingredient.IngredientID]
+		delete(old, ingredient.IngredientID)
+		switch {
+		case !ok:
+			changes = append(changes, models.RecipeIngredientChange{IngredientID: ingredient.IngredientID, Change: models.RecipeIngredientAdded, NewQuantity: ingredient.Quantity, NewUnit: ingredient.Unit})
+		case previous.Quantity != ingredient.Quantity || previous.Unit != ingredient.Unit:
+			changes = append(changes, models.RecipeIngredientChange{IngredientID: ingredient.IngredientID, Change: models.RecipeIngredientChanged,
+				OldQuantity: previous.Quantity, NewQuantity: ingredient.Quantity, OldUnit: previous.Unit, NewUnit: ingredient.Unit})
+		}
+	}
+	for id, previous := range old {
+		changes = append(changes, models.RecipeIngredientChange{IngredientID: id, Change: models.RecipeIngredientRemoved, OldQuantity: previous.Quantity, OldUnit: previous.Unit})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].IngredientID < changes[j].IngredientID })
+	return changes
+}
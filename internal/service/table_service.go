@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// TableService manages dine-in tables and the orders seated at them.
+type TableService struct {
+	tableRepo dal.TableRepository // Repository for interacting with tables.
+}
+
+// NewTableService creates and returns a new instance of TableService with the given repository.
+func NewTableService(tableRepo dal.TableRepository) *TableService {
+	return &TableService{tableRepo: tableRepo}
+}
+
+// GetTables retrieves all tables with their occupancy and running totals.
+func (s *TableService) GetTables() ([]models.Table, error) {
+	return s.tableRepo.GetAll()
+}
+
+// AddTable validates and adds a new table.
+func (s *TableService) AddTable(table models.Table) error {
+	if strings.TrimSpace(table.Name) == "" {
+		return errors.New("table name is required")
+	}
+	if table.Seats < 1 {
+		return errors.New("table must have at least one seat")
+	}
+	return s.tableRepo.Add(table)
+}
+
+// AssignOrder seats an open order at a table, moving it if it was seated elsewhere.
+func (s *TableService) AssignOrder(orderID, tableID int, change models.ChangeInfo) error {
+	if !s.tableRepo.Exists(tableID) {
+		return models.ErrTableNotFound
+	}
+	return s.tableRepo.AssignOrder(orderID, tableID, change)
+}
+
+// MergeTables merges the open orders of the source table into one check at the target table.
+func (s *TableService) MergeTables(targetID, sourceID int, change models.ChangeInfo) (int, error) {
+	if targetID == sourceID {
+		return 0, errors.New("cannot merge a table with itself")
+	}
+	if !s.tableRepo.Exists(targetID) || !s.tableRepo.Exists(sourceID) {
+		return 0, models.ErrTableNotFound
+	}
+	return s.tableRepo.Merge(targetID, sourceID, change)
+}
+
+// CloseTable closes all open orders of a table together.
+func (s *TableService) CloseTable(tableID int, change models.ChangeInfo) ([]int, error) {
+	if !s.tableRepo.Exists(tableID) {
+		return nil, models.ErrTableNotFound
+	}
+	return s.tableRepo.CloseTable(tableID, change)
+}
@@ -0,0 +1,51 @@
+package setup
+
+import (
+	"log/slog"
+	"os"
+
+	"hot-coffee/internal/storage"
+)
+
+// SetupStorage creates the storage for uploaded files. STORAGE_BACKEND=s3 stores them in an
+// S3-compatible bucket configured by the S3_* variables, still serving files missing from the
+// bucket from UPLOADS_DIR if it exists; otherwise they are kept in UPLOADS_DIR (uploads by default).
+func SetupStorage(logger *slog.Logger) storage.Storage {
+	dir := os.Getenv("UPLOADS_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+
+	if os.Getenv("STORAGE_BACKEND") == "s3" {
+		s3, err := storage.NewS3(storage.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       os.Getenv("S3_PATH_STYLE") != "false",
+		})
+		if err != nil {
+			logger.Error("Error configuring S3 storage", "Error", err)
+			os.Exit(1)
+		}
+		logger.Info("Storing uploads in S3", "endpoint", os.Getenv("S3_ENDPOINT"), "bucket", os.Getenv("S3_BUCKET"))
+
+		// The images shipped with the app are read from the uploads directory until they are copied over
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			local, err := storage.NewLocal(dir)
+			if err == nil {
+				logger.Info("Serving uploads missing from S3 from the uploads directory", "dir", dir)
+				return storage.NewFallback(s3, local)
+			}
+		}
+		return s3
+	}
+
+	local, err := storage.NewLocal(dir)
+	if err != nil {
+		logger.Error("Error creating uploads directory", "Error", err)
+		os.Exit(1)
+	}
+	return local
+}
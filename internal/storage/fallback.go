@@ -0,0 +1,39 @@
+package storage
+
+// Fallback reads objects missing from a primary storage from a second, read-only one. It lets
+// an object store serve the images shipped in the uploads directory without copying them over.
+type Fallback struct {
+	primary  Storage
+	fallback Storage
+}
+
+// NewFallback creates a storage that writes to primary and reads from fallback whatever
+// primary does not have.
+func NewFallback(primary, fallback Storage) *Fallback {
+	return &Fallback{primary: primary, fallback: fallback}
+}
+
+func (f *Fallback) Put(key string, data []byte, contentType string) error {
+	return f.primary.Put(key, data, contentType)
+}
+
+func (f *Fallback) Get(key string) (Object, error) {
+	object, err := f.primary.Get(key)
+	if err == ErrNotFound {
+		return f.fallback.Get(key)
+	}
+	return object, err
+}
+
+func (f *Fallback) Exists(key string) (bool, error) {
+	exists, err := f.primary.Exists(key)
+	if err != nil || exists {
+		return exists, err
+	}
+	return f.fallback.Exists(key)
+}
+
+// Delete only removes the object from primary; the fallback is never written to.
+func (f *Fallback) Delete(key string) error {
+	return f.primary.Delete(key)
+}
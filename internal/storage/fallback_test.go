@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	shipped := t.TempDir()
+	if err := os.WriteFile(filepath.Join(shipped, "latte.jpg"), []byte("shipped"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fallback, err := NewLocal(shipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := NewFallback(primary, fallback)
+
+	if err := images.Put("upload.jpg", []byte("uploaded"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := fallback.Exists("upload.jpg"); exists {
+		t.Error("Put wrote to the fallback")
+	}
+
+	tests := []struct {
+		key    string
+		want   string
+		exists bool
+	}{
+		{"upload.jpg", "uploaded", true},
+		{"latte.jpg", "shipped", true},
+		{"missing.jpg", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			exists, err := images.Exists(tt.key)
+			if err != nil || exists != tt.exists {
+				t.Errorf("Exists = %v, %v, want %v", exists, err, tt.exists)
+			}
+			object, err := images.Get(tt.key)
+			if !tt.exists {
+				if err != ErrNotFound {
+					t.Errorf("Get = %v, want ErrNotFound", err)
+				}
+				return
+			}
+			if err != nil || string(object.Data) != tt.want {
+				t.Errorf("Get = %q, %v, want %q", object.Data, err, tt.want)
+			}
+		})
+	}
+
+	// Shipped files stay in place; only the primary copy is removed
+	if err := images.Delete("latte.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := fallback.Exists("latte.jpg"); !exists {
+		t.Error("Delete removed the shipped file")
+	}
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"latte.jpg", true},
+		{"menu/latte.jpg", true},
+		{"", false},
+		{"/latte.jpg", false},
+		{"../latte.jpg", false},
+		{"menu/../../latte.jpg", false},
+		{"menu//latte.jpg", false},
+		{"menu\\latte.jpg", false},
+		{".", false},
+	}
+	for _, tt := range tests {
+		if _, err := cleanKey(tt.key); (err == nil) != tt.valid {
+			t.Errorf("cleanKey(%q) = %v, want valid %v", tt.key, err, tt.valid)
+		}
+	}
+}
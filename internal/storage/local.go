@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// Local stores objects as files under a directory. It is only shared between instances that
+// mount the same directory.
+type Local struct {
+	root string
+}
+
+// NewLocal creates a Local storage rooted at dir, creating the directory if needed.
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{root: dir}, nil
+}
+
+// Put writes the object to a temporary file first so a half-written file is never served.
+func (l *Local) Put(key string, data []byte, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (l *Local) Get(key string) (Object, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Data: data, ContentType: mime.TypeByExtension(filepath.Ext(path)), ModTime: info.ModTime()}, nil
+}
+
+func (l *Local) Exists(key string) (bool, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *Local) Delete(key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,46 @@
+// Package storage keeps uploaded files, such as menu item images, in a backend shared by every
+// instance of the server.
+package storage
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage stores objects under slash-separated keys such as "4ae3139e.jpg".
+type Storage interface {
+	// Put stores data under key, replacing any existing object.
+	Put(key string, data []byte, contentType string) error
+	// Get returns the object stored under key, or ErrNotFound.
+	Get(key string) (Object, error)
+	// Exists reports whether an object is stored under key.
+	Exists(key string) (bool, error)
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(key string) error
+}
+
+// Object is a stored file with its metadata.
+type Object struct {
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+}
+
+// cleanKey rejects keys that are empty or could escape the storage root.
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,34 @@
+package models
+
+// MenuItemCosting compares what a menu item costs to make with what it sells for.
+// Percentages are given as 0-100.
+type MenuItemCosting struct {
+	ProductID       int              `json:"product_id"`
+	Name            string           `json:"name"`
+	Price           float64          `json:"price"`
+	Cost            float64          `json:"cost"`
+	GrossMargin     float64          `json:"gross_margin"`
+	MarginPercent   float64          `json:"margin_percent"`
+	FoodCostPercent float64          `json:"food_cost_percent"`
+	TargetMargin    float64          `json:"target_margin,omitempty"`
+	SuggestedPrice  float64          `json:"suggested_price,omitempty"`
+	BelowThreshold  bool             `json:"below_threshold,omitempty"`
+	Ingredients     []IngredientCost `json:"ingredients,omitempty"`
+}
+
+// IngredientCost is the share of one ingredient in the cost of a menu item.
+type IngredientCost struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Quantity     float64 `json:"quantity"`
+	UnitCost     float64 `json:"unit_cost"`
+	Cost         float64 `json:"cost"`
+}
+
+// MarginReport lists the margin of every menu item, lowest first, and flags the ones below Threshold.
+type MarginReport struct {
+	Threshold float64           `json:"threshold"`
+	Flagged   int               `json:"flagged"`
+	Items     []MenuItemCosting `json:"items"`
+}
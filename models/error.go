@@ -3,8 +3,50 @@ package models
 import "errors"
 
 var (
-	ErrOrderClosed   = errors.New("the order is already closed")
-	ErrOrderNotFound = errors.New("order not found")
+	ErrOrderClosed         = errors.New("the order is already closed")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrInvalidChannel      = errors.New("invalid channel. Available channels: dine_in, takeaway, delivery, online")
+	ErrTableNotFound       = errors.New("table not found")
+	ErrTableNoOrders       = errors.New("the table has no open orders")
+	ErrOrderNotDineIn      = errors.New("only dine-in orders can be seated at a table")
+	ErrOrderHasPaidChecks  = errors.New("the order has paid checks, so its lines can no longer change")
+	ErrOrderUnpaidChecks   = errors.New("the order has checks that are not paid yet")
+	ErrMergePriceConflict  = errors.New("the orders have the same item on a seat at different prices and cannot be merged")
+	ErrOrderSplit          = errors.New("the order is already split into checks")
+	ErrCheckNotFound       = errors.New("check not found")
+	ErrCheckPaid           = errors.New("the check is already paid")
+	ErrLineNotFound        = errors.New("order line not found")
+	ErrLineServed          = errors.New("the order line is already served")
+	ErrInvalidStatus       = errors.New("invalid status. Available statuses: queued, in_progress, done, served")
+	ErrCategoryNotFound    = errors.New("menu category not found")
+	ErrCategoryCycle       = errors.New("a category cannot be nested inside itself")
+	ErrMenuItemNotFound    = errors.New("could not find menu item by the given id")
+	ErrItemUnavailable     = errors.New("menu item is not available")
+	ErrBundleChoice        = errors.New("invalid bundle choice")
+	ErrMenuItemInUse       = errors.New("the menu item is referenced by orders and can only be archived")
+	ErrRecipeNotFound      = errors.New("recipe version not found")
+	ErrInvalidMargin       = errors.New("margin must be between 0 and 100")
+	ErrInvalidImage        = errors.New("image must be a JPEG or PNG file")
+	ErrImageTooLarge       = errors.New("image is too large")
+	ErrInvalidImageSize    = errors.New("invalid image size. Available sizes: thumb, medium, large")
+	ErrPriceChangeNotFound = errors.New("scheduled price change not found")
+	ErrPriceChangeDone     = errors.New("the price change is no longer pending")
+	ErrInvalidImportFile   = errors.New("invalid import file")
+	ErrTranslationNotFound = errors.New("translation not found")
+	ErrUnsupportedLocale   = errors.New("unsupported locale. Available locales: en, ru, kk, de, fr, es")
+	ErrVersionConflict     = errors.New("the resource has been changed since it was read. Fetch it again and retry")
+	ErrInvalidPatch        = errors.New("invalid merge patch")
+	ErrInvalidRanking      = errors.New("invalid ranking. Available rankings: lift, confidence")
+	ErrEmptyBasket         = errors.New("the basket has no items")
+	ErrInvalidSubstitute   = errors.New("a substitute must be another inventory item with a positive ratio, listed once")
+	ErrUnknownUnit         = errors.New("unknown unit")
+	ErrIncompatibleUnit    = errors.New("the unit cannot be converted into the ingredient's stock unit")
+	ErrInvalidUnit         = errors.New("a unit needs a code, a name, a dimension of mass, volume or count, a positive factor and 0 to 3 decimals")
+	ErrUnitCodeTaken       = errors.New("the code is already used by a unit or a pack unit")
+	ErrInvalidPackUnit     = errors.New("a pack unit needs a code that is not a regular unit, listed once, and a positive quantity")
+	ErrIngredientInUse     = errors.New("the inventory item is used by a recipe and cannot be deleted")
+	ErrInvalidRestock      = errors.New("a restock needs a positive quantity and a cost that is not negative")
+	ErrOutOfStock          = errors.New("insufficient_inventory")
 )
 
 type Error struct {
@@ -5,4 +5,20 @@ type InventoryItem struct {
 	Name         string  `json:"name"`
 	Quantity     float64 `json:"quantity"`
 	Unit         string  `json:"unit"`
+	// Density in grams per millilitre lets recipes measure the ingredient by weight and volume alike.
+	Density float64 `json:"density,omitempty"`
+	// UnitCost is the weighted average cost of one unit of the stock. Restocks update it.
+	UnitCost float64 `json:"unit_cost"`
+	// PurchaseCost is what one unit cost on the last restock.
+	PurchaseCost float64 `json:"purchase_cost"`
+	// Version is the row version behind the item's ETag. It is bumped on every write.
+	Version int `json:"version"`
+}
+
+// Restock is a delivery of an ingredient. Quantity may be given in any unit that converts into
+// the stock unit, and Cost is what the whole delivery cost.
+type Restock struct {
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit,omitempty"`
+	Cost     float64 `json:"cost"`
 }
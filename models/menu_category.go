@@ -0,0 +1,11 @@
+package models
+
+// MenuCategory is a section of the menu. Categories can be nested one inside another.
+type MenuCategory struct {
+	ID            int            `json:"category_id"`
+	Name          string         `json:"name"`
+	ParentID      int            `json:"parent_id,omitempty"`
+	DisplayOrder  int            `json:"display_order"`
+	Items         []MenuItem     `json:"items,omitempty"`
+	Subcategories []MenuCategory `json:"subcategories,omitempty"`
+}
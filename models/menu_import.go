@@ -0,0 +1,41 @@
+package models
+
+// MenuImportItem is a menu item in the portable form used by menu import and export. Categories
+// and ingredients are referred to by name, so a file can be moved between installations.
+type MenuImportItem struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	// Category is the category path with levels separated by "/", e.g. "Coffee/Espresso Drinks".
+	// A plain name is accepted as long as it is unique.
+	Category    string                 `json:"category,omitempty"`
+	Position    int                    `json:"position"`
+	Ingredients []MenuImportIngredient `json:"ingredients"`
+}
+
+// MenuImportIngredient is one recipe line of an imported menu item. The ingredient is matched
+// by name and the quantity may be given in any unit that converts into its stock unit, so a
+// recipe never silently switches between grams and millilitres.
+type MenuImportIngredient struct {
+	Name     string  `json:"name"`
+	Unit     string  `json:"unit"`
+	Quantity float64 `json:"quantity"`
+}
+
+// MenuImportResult reports what an import did or, on a dry run, would do. Nothing is applied
+// while Errors is not empty.
+type MenuImportResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Rows    int               `json:"rows"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Errors  []MenuImportError `json:"errors"`
+}
+
+// MenuImportError lists the problems found in one row of an import. Rows are numbered from 1,
+// not counting the CSV header.
+type MenuImportError struct {
+	Row    int      `json:"row"`
+	Name   string   `json:"name,omitempty"`
+	Errors []string `json:"errors"`
+}
@@ -1,5 +1,14 @@
 package models
 
+import "time"
+
+// Renditions every uploaded menu item image is stored in.
+var (
+	ImageSizeThumb  = "thumb"
+	ImageSizeMedium = "medium"
+	ImageSizeLarge  = "large"
+)
+
 type MenuItem struct {
 	ID          int                  `json:"product_id"`
 	Name        string               `json:"name"`
@@ -7,9 +16,64 @@ type MenuItem struct {
 	Price       float64              `json:"price"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
 	Image       string               `json:"image"`
+	// CategoryID is 0 for items that are not in any category.
+	CategoryID int `json:"category_id,omitempty"`
+	// Position orders items inside their category.
+	Position int `json:"position"`
+	// ChannelPrices overrides Price for the listed order channels.
+	ChannelPrices map[string]float64 `json:"channel_prices,omitempty"`
+	// Packaging is deducted on top of Ingredients for takeaway, delivery and online orders.
+	Packaging []MenuItemIngredient `json:"packaging,omitempty"`
+	// AvailableServings is how many servings the current stock allows; nil when the item has no ingredients.
+	AvailableServings *int `json:"available_servings"`
+	// SoldOut is set when no servings can be made or the item is 86'd.
+	SoldOut   bool       `json:"sold_out"`
+	EightySix *EightySix `json:"eighty_six,omitempty"`
+	// Schedules limit when the item is sold. Items without schedules are always available.
+	Schedules []MenuItemSchedule `json:"schedules,omitempty"`
+	// Bundle lists the slots of a bundle item. Bundles are sold at Price and deduct the
+	// ingredients of their components instead of their own.
+	Bundle []BundleSlot `json:"bundle,omitempty"`
+	// ArchivedAt is set once the item is deleted from the menu. Archived items cannot be
+	// ordered but are kept for past orders and reports.
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	// NoSubstitutes keeps the item from being made with substitutes of ingredients that ran out.
+	NoSubstitutes bool `json:"no_substitutes,omitempty"`
+	// RecipeVersion is the number of the recipe version in effect.
+	RecipeVersion int `json:"recipe_version,omitempty"`
+	// Version is the row version behind the item's ETag. It is bumped on every write.
+	Version int `json:"version"`
+}
+
+// BundleSlot is one part of a bundle: either a fixed product or a choice from a category.
+type BundleSlot struct {
+	ID         int `json:"slot_id"`
+	ProductID  int `json:"product_id,omitempty"`
+	CategoryID int `json:"category_id,omitempty"`
+	Quantity   int `json:"quantity"`
+}
+
+// MenuItemSchedule is one availability window of a menu item. Empty fields do not restrict it.
+type MenuItemSchedule struct {
+	Days      []int  `json:"days,omitempty"`       // ISO weekdays, 1 = Monday ... 7 = Sunday
+	StartTime string `json:"start_time,omitempty"` // HH:MM
+	EndTime   string `json:"end_time,omitempty"`   // HH:MM, may be earlier than StartTime to span midnight
+	StartDate string `json:"start_date,omitempty"` // YYYY-MM-DD
+	EndDate   string `json:"end_date,omitempty"`   // YYYY-MM-DD, inclusive
+}
+
+// EightySix is a manual override that takes a menu item off sale until it is lifted.
+type EightySix struct {
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
 }
 
 type MenuItemIngredient struct {
 	IngredientID int     `json:"ingredient_id"`
 	Quantity     float64 `json:"quantity"`
+	// Unit is the unit Quantity is given in. Empty means the ingredient's stock unit, which the
+	// recipe is then saved with.
+	Unit string `json:"unit,omitempty"`
+	// StockQuantity is Quantity converted into the stock unit. It is only set when reading.
+	StockQuantity float64 `json:"stock_quantity,omitempty"`
 }
@@ -5,18 +5,106 @@ var (
 	StatusOrderRejected = "rejected"
 )
 
+var (
+	ChannelDineIn   = "dine_in"
+	ChannelTakeaway = "takeaway"
+	ChannelDelivery = "delivery"
+	ChannelOnline   = "online"
+)
+
+// Channels lists every channel an order can be placed through.
+var Channels = []string{ChannelDineIn, ChannelTakeaway, ChannelDelivery, ChannelOnline}
+
+var (
+	SplitByItem = "item"
+	SplitBySeat = "seat"
+	SplitEvenly = "even"
+)
+
+var (
+	StatusCheckOpen = "open"
+	StatusCheckPaid = "paid"
+)
+
+var (
+	StatusItemQueued     = "queued"
+	StatusItemInProgress = "in_progress"
+	StatusItemDone       = "done"
+	StatusItemServed     = "served"
+	// StatusOrderReady is the fulfillment status of an order whose lines are all done but not all served.
+	StatusOrderReady = "ready"
+)
+
+// ItemStatuses lists the fulfillment statuses of an order line in the order they are reached.
+var ItemStatuses = []string{StatusItemQueued, StatusItemInProgress, StatusItemDone, StatusItemServed}
+
 type Order struct {
 	ID           int                    `json:"order_id"`
 	CustomerName string                 `json:"customer_name"`
 	Items        []OrderItem            `json:"items"`
 	Status       string                 `json:"status"`
 	Notes        map[string]interface{} `json:"notes"`
+	Channel      string                 `json:"channel"`
+	TableID      int                    `json:"table_id,omitempty"`
 	CreatedAt    string                 `json:"created_at"`
+	Checks       []Check                `json:"checks,omitempty"`
+	// Fulfillment is derived from the statuses of the order lines.
+	Fulfillment string         `json:"fulfillment_status,omitempty"`
+	Progress    map[string]int `json:"progress,omitempty"`
+	// Version is the row version behind the order's ETag. Changing the order, its lines or
+	// its checks bumps it.
+	Version int `json:"version"`
 }
 
 type OrderItem struct {
+	LineID    int     `json:"line_id,omitempty"`
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Seat      int     `json:"seat,omitempty"`
+	Price     float64 `json:"price,omitempty"`
+	Status    string  `json:"status,omitempty"`
+	// Choices pick the product for the choice slots of a bundle.
+	Choices []BundleChoice `json:"choices,omitempty"`
+	// Components are the products a bundle line was made of and their share of its revenue.
+	Components []OrderItemComponent `json:"components,omitempty"`
+	// RecipeVersionID is the recipe version the line was made with.
+	RecipeVersionID int `json:"recipe_version_id,omitempty"`
+	// Substitutions are the ingredients that replaced ones of the recipe that had run out.
+	Substitutions []Substitution `json:"substitutions,omitempty"`
+}
+
+type BundleChoice struct {
+	SlotID    int `json:"slot_id"`
 	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+}
+
+type OrderItemComponent struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Revenue   float64 `json:"revenue"`
+	// RecipeVersionID is the recipe version the component was made with.
+	RecipeVersionID int `json:"recipe_version_id,omitempty"`
+}
+
+type ItemStatusUpdate struct {
+	Status string `json:"status"`
+}
+
+// Check is one part of a split bill. It is paid and closed on its own.
+type Check struct {
+	ID      int         `json:"check_id"`
+	OrderID int         `json:"order_id"`
+	Label   string      `json:"label"`
+	Amount  float64     `json:"amount"`
+	Status  string      `json:"status"`
+	Items   []OrderItem `json:"items,omitempty"`
+	PaidAt  string      `json:"paid_at,omitempty"`
+}
+
+type SplitRequest struct {
+	Mode   string        `json:"mode"`
+	Parts  int           `json:"parts,omitempty"`
+	Checks [][]OrderItem `json:"checks,omitempty"`
 }
 
 type BatchOrdersResponce struct {
@@ -30,6 +118,8 @@ type BatchOrderInfo struct {
 	Status       string  `json:"status"`
 	Reason       string  `json:"reason"`
 	Total        float64 `json:"total"`
+	// Substitutions lists the ingredients that were replaced because they ran out.
+	Substitutions []Substitution `json:"substitutions,omitempty"`
 }
 
 type BatchOrderSummary struct {
@@ -41,8 +131,8 @@ type BatchOrderSummary struct {
 }
 
 type BatchOrderInventoryUpdate struct {
-	IngredientID  int    `json:"ingredient_id"`
-	Name          string `json:"name"`
-	Quantity_used int    `json:"quantity_used"`
-	Remaining     int    `json:"remaining"`
+	IngredientID  int     `json:"ingredient_id"`
+	Name          string  `json:"name"`
+	Quantity_used float64 `json:"quantity_used"`
+	Remaining     float64 `json:"remaining"`
 }
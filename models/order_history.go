@@ -0,0 +1,32 @@
+package models
+
+var (
+	OrderActionCreated   = "created"
+	OrderActionUpdated   = "updated"
+	OrderActionClosed    = "closed"
+	OrderActionDeleted   = "deleted"
+	OrderActionSeated    = "seated"
+	OrderActionMerged    = "merged"
+	OrderActionSplit     = "split"
+	OrderActionCheckPaid = "check_paid"
+	OrderActionItemState = "item_status"
+)
+
+// ChangeInfo describes who changed an order and why.
+type ChangeInfo struct {
+	Actor  string
+	Reason string
+}
+
+// OrderChange is one entry of an order's change history. Before and After
+// only hold the fields that changed.
+type OrderChange struct {
+	ID        int                    `json:"change_id"`
+	OrderID   int                    `json:"order_id"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"`
+	Reason    string                 `json:"reason,omitempty"`
+	Before    map[string]interface{} `json:"before"`
+	After     map[string]interface{} `json:"after"`
+	ChangedAt string                 `json:"changed_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+var (
+	PriceChangePercent = "percent"
+	PriceChangeAmount  = "amount"
+	PriceChangeSet     = "set"
+)
+
+var (
+	StatusPriceChangePending   = "pending"
+	StatusPriceChangeApplied   = "applied"
+	StatusPriceChangeFailed    = "failed"
+	StatusPriceChangeCancelled = "cancelled"
+)
+
+// PriceChange is one entry of the price log written by the price change trigger.
+type PriceChange struct {
+	ID                int       `json:"history_id"`
+	ProductID         int       `json:"product_id"`
+	Name              string    `json:"name"`
+	OldPrice          float64   `json:"old_price"`
+	NewPrice          float64   `json:"new_price"`
+	ChangedAt         time.Time `json:"changed_at"`
+	ScheduledChangeID int       `json:"scheduled_change_id,omitempty"`
+}
+
+// ScheduledPriceChange is a price change that is applied automatically at EffectiveAt.
+// Mode is percent (Value is a percentage), amount (Value is added) or set (Value is the new price).
+// Without CategoryID or ProductIDs it applies to the whole menu.
+type ScheduledPriceChange struct {
+	ID          int        `json:"id"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	Mode        string     `json:"mode"`
+	Value       float64    `json:"value"`
+	CategoryID  int        `json:"category_id,omitempty"`
+	ProductIDs  []int      `json:"product_ids,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	Status      string     `json:"status"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
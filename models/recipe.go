@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+var (
+	RecipeIngredientAdded   = "added"
+	RecipeIngredientRemoved = "removed"
+	RecipeIngredientChanged = "changed"
+)
+
+// RecipeVersion is one version of a menu item's recipe. The version in effect is the latest one
+// whose EffectiveFrom has passed.
+type RecipeVersion struct {
+	ID            int                  `json:"version_id"`
+	ProductID     int                  `json:"product_id"`
+	Version       int                  `json:"version"`
+	EffectiveFrom time.Time            `json:"effective_from"`
+	Note          string               `json:"note,omitempty"`
+	Current       bool                 `json:"current"`
+	Ingredients   []MenuItemIngredient `json:"ingredients"`
+	CreatedAt     time.Time            `json:"created_at"`
+}
+
+// RecipeComparison lists the ingredient differences between two versions of a recipe.
+type RecipeComparison struct {
+	ProductID int                      `json:"product_id"`
+	From      RecipeVersion            `json:"from"`
+	To        RecipeVersion            `json:"to"`
+	Changes   []RecipeIngredientChange `json:"changes"`
+}
+
+type RecipeIngredientChange struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Change       string  `json:"change"`
+	OldQuantity  float64 `json:"old_quantity"`
+	NewQuantity  float64 `json:"new_quantity"`
+	OldUnit      string  `json:"old_unit,omitempty"`
+	NewUnit      string  `json:"new_unit,omitempty"`
+}
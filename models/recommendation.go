@@ -0,0 +1,25 @@
+package models
+
+// Scores recommendations can be ranked by.
+var (
+	RankByLift       = "lift"
+	RankByConfidence = "confidence"
+)
+
+// Recommendation is a menu item that is often bought together with the items a customer picked.
+type Recommendation struct {
+	ProductID int     `json:"product_id"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	// Orders is how many orders had both items.
+	Orders int `json:"orders"`
+	// Confidence is the share of orders with the picked item that also had this one.
+	Confidence float64 `json:"confidence"`
+	// Lift is how many times more often the items are bought together than by chance.
+	Lift float64 `json:"lift"`
+}
+
+// BasketRecommendationRequest holds the cart recommendations are made for.
+type BasketRecommendationRequest struct {
+	Items []OrderItem `json:"items"`
+}
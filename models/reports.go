@@ -1,11 +1,22 @@
 package models
 
 type TotalSales struct {
-	TotalSales int `json:"total_sales"`
+	TotalSales int            `json:"total_sales"`
+	ByChannel  map[string]int `json:"by_channel"`
+	ByCategory map[string]int `json:"by_category"`
 }
 
 type PopularItems struct {
-	Items []PopularItem `json:"popular_items"`
+	Channel string        `json:"channel,omitempty"`
+	Items   []PopularItem `json:"popular_items"`
+	// Categories is only filled when the report is grouped by category.
+	Categories []PopularCategory `json:"popular_categories,omitempty"`
+}
+
+type PopularCategory struct {
+	CategoryID int    `json:"category_id"`
+	Name       string `json:"name"`
+	Quantity   int    `json:"quantity"`
 }
 
 type PopularItem struct {
@@ -14,6 +25,23 @@ type PopularItem struct {
 	Description string `json:"description"`
 	Quantity    int    `json:"quantity"`
 	Image       string `json:"image"`
+	Category    string `json:"category,omitempty"`
+}
+
+type RevenueReport struct {
+	Channel      string        `json:"channel,omitempty"`
+	TotalRevenue float64       `json:"total_revenue"`
+	Items        []ItemRevenue `json:"items"`
+}
+
+// ItemRevenue is the revenue earned by a menu item. Items sold inside bundles are credited
+// with their share of the bundle price, which is also reported separately as BundleRevenue.
+type ItemRevenue struct {
+	ProductID     int     `json:"product_id"`
+	Name          string  `json:"name"`
+	Quantity      int     `json:"quantity"`
+	Revenue       float64 `json:"revenue"`
+	BundleRevenue float64 `json:"bundle_revenue"`
 }
 
 type SearchResult struct {
@@ -37,3 +65,79 @@ type SearchOrderResult struct {
 	Total        float64  `json:"total"`
 	Relevance    float64  `json:"relavance"`
 }
+
+// IngredientUsageReport is the stock consumed by orders, worked out from the recipe version
+// each order line was made with.
+type IngredientUsageReport struct {
+	From        string            `json:"from,omitempty"`
+	To          string            `json:"to,omitempty"`
+	Ingredients []IngredientUsage `json:"ingredients"`
+	TotalCost   float64           `json:"total_cost"`
+}
+
+type IngredientUsage struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Quantity     float64 `json:"quantity"`
+	// Cost values the quantity at the ingredient's current unit cost.
+	Cost float64 `json:"cost"`
+}
+
+// Classes of the menu engineering matrix.
+var (
+	MenuClassStar      = "star"      // popular and profitable: keep as is
+	MenuClassPlowhorse = "plowhorse" // popular but low margin: reprice or cut its cost
+	MenuClassPuzzle    = "puzzle"    // profitable but rarely sold: promote or reposition
+	MenuClassDog       = "dog"       // neither: candidate for removal
+)
+
+// MenuEngineeringReport classifies the menu items by popularity and contribution margin over a
+// period. An item is popular when its share of the items sold reaches PopularityThreshold, 70% of
+// an even share, and profitable when its contribution margin reaches MarginThreshold, the
+// average margin per item sold.
+type MenuEngineeringReport struct {
+	From                string                `json:"from,omitempty"`
+	To                  string                `json:"to,omitempty"`
+	Channel             string                `json:"channel,omitempty"`
+	ItemsSold           int                   `json:"items_sold"`
+	PopularityThreshold float64               `json:"popularity_threshold"`
+	MarginThreshold     float64               `json:"margin_threshold"`
+	TotalMargin         float64               `json:"total_margin"`
+	Classes             map[string]int        `json:"classes"`
+	Items               []MenuEngineeringItem `json:"items"`
+}
+
+// MenuEngineeringItem is one menu item of the menu engineering matrix with the figures behind
+// its class. MenuMix is its share of the items sold in percent.
+type MenuEngineeringItem struct {
+	ProductID          int     `json:"product_id"`
+	Name               string  `json:"name"`
+	Category           string  `json:"category,omitempty"`
+	Price              float64 `json:"price"`
+	Cost               float64 `json:"cost"`
+	ContributionMargin float64 `json:"contribution_margin"`
+	Sold               int     `json:"sold"`
+	MenuMix            float64 `json:"menu_mix"`
+	TotalMargin        float64 `json:"total_margin"`
+	Popular            bool    `json:"popular"`
+	Profitable         bool    `json:"profitable"`
+	Class              string  `json:"class"`
+}
+
+// InventoryValuationReport values the stock held at a point in time.
+type InventoryValuationReport struct {
+	Date        string                `json:"date"`
+	Ingredients []IngredientValuation `json:"ingredients"`
+	TotalValue  float64               `json:"total_value"`
+}
+
+type IngredientValuation struct {
+	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Quantity     float64 `json:"quantity"`
+	// UnitCost is the weighted average cost of one unit at the time of the report.
+	UnitCost float64 `json:"unit_cost"`
+	Value    float64 `json:"value"`
+}
@@ -0,0 +1,22 @@
+package models
+
+// IngredientSubstitute is an ingredient that can be used when another one runs out. Ratio is how
+// much of the substitute replaces one unit of the original.
+type IngredientSubstitute struct {
+	SubstituteID int     `json:"substitute_id"`
+	Name         string  `json:"name,omitempty"`
+	Unit         string  `json:"unit,omitempty"`
+	Ratio        float64 `json:"ratio"`
+}
+
+// Substitution records an ingredient an order line was made with in place of the one in its recipe.
+type Substitution struct {
+	// ProductID is the ordered menu item. It is only set in order placement results.
+	ProductID        int     `json:"product_id,omitempty"`
+	IngredientID     int     `json:"ingredient_id"`
+	Ingredient       string  `json:"ingredient"`
+	SubstituteID     int     `json:"substitute_id"`
+	Substitute       string  `json:"substitute"`
+	ReplacedQuantity float64 `json:"replaced_quantity"`
+	Quantity         float64 `json:"quantity"`
+}
@@ -0,0 +1,14 @@
+package models
+
+type Table struct {
+	ID           int     `json:"table_id"`
+	Name         string  `json:"name"`
+	Seats        int     `json:"seats"`
+	Occupied     bool    `json:"occupied"`
+	OpenOrders   []int   `json:"open_orders"`
+	RunningTotal float64 `json:"running_total"`
+}
+
+type TableAssignment struct {
+	TableID int `json:"table_id"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// DefaultLocale is the language the name and description of menu_items are written in.
+// Other languages are stored as translations.
+const DefaultLocale = "en"
+
+// SearchConfigs maps every supported locale to the PostgreSQL text search configuration used
+// to search menu content in that language. Kazakh has no stemmer in PostgreSQL, so it only
+// gets the "simple" configuration.
+var SearchConfigs = map[string]string{
+	"en": "english",
+	"ru": "russian",
+	"kk": "simple",
+	"de": "german",
+	"fr": "french",
+	"es": "spanish",
+}
+
+// MenuItemTranslation is the name and description of a menu item in one locale.
+type MenuItemTranslation struct {
+	ProductID   int    `json:"product_id"`
+	Locale      string `json:"locale"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,26 @@
+package models
+
+var (
+	UnitMass   = "mass"
+	UnitVolume = "volume"
+	UnitCount  = "count"
+)
+
+// Unit is a unit of measure. Factor converts one unit into the base unit of its dimension:
+// grams for mass, millilitres for volume and pieces for count.
+type Unit struct {
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Dimension string  `json:"dimension"`
+	Factor    float64 `json:"factor"`
+	// Decimals is how many decimals stock counted in the unit is kept to, 0 to 3. Orders round
+	// what they use up to it. It defaults to 3.
+	Decimals *int `json:"decimals,omitempty"`
+}
+
+// PackUnit is a unit that only exists for one ingredient, such as a bag of coffee beans.
+// Quantity is how much of the ingredient's stock unit one pack holds.
+type PackUnit struct {
+	Code     string  `json:"code"`
+	Quantity float64 `json:"quantity"`
+}
@@ -1,6 +1,6 @@
 async function loadMenu() {
     try {
-        let url = `/menu`;
+        let url = `/menu?all=true`;
         const response = await fetch(url);
         if (!response.ok) {
             throw new Error('Menu items could not be loaded.');
@@ -1,34 +1,50 @@
+function createCard(item) {
+    const card = document.createElement('div');
+    card.classList.add('menu-card');
+    const imagePath = item.image.startsWith("uploads/") ? `/${item.image}` : `/uploads/${item.image}`;
+    const img = document.createElement('img');
+    img.src = imagePath;
+    img.alt = item.name;
+    const body = document.createElement('div');
+    body.classList.add('card-body');
+    const title = document.createElement('h5');
+    title.textContent = item.name;
+    const description = document.createElement('p');
+    description.textContent = item.description;
+    const price = document.createElement('p');
+    price.textContent = `$${item.price}`;
+    body.appendChild(title);
+    body.appendChild(description);
+    body.appendChild(price);
+    card.appendChild(img);
+    card.appendChild(body);
+    return card;
+}
+
+function renderSection(section, container, level) {
+    const heading = document.createElement(level === 0 ? 'h2' : 'h4');
+    heading.classList.add('menu-section-title', 'w-100', 'text-center', 'mt-4');
+    heading.textContent = section.name;
+    container.appendChild(heading);
+
+    const items = document.createElement('div');
+    items.classList.add('d-flex', 'flex-wrap', 'justify-content-center', 'w-100');
+    (section.items || []).forEach(item => items.appendChild(createCard(item)));
+    container.appendChild(items);
+
+    (section.subcategories || []).forEach(sub => renderSection(sub, container, level + 1));
+}
+
 async function fetchMenu() {
     try {
-      const response = await fetch('http://localhost:8080/menu');
+      const response = await fetch('http://localhost:8080/menu?groupBy=category');
       const data = await response.json();
       const menuContainer = document.getElementById('menu');
       menuContainer.innerHTML = '';
-      data.forEach(item => {
-        const card = document.createElement('div');
-        card.classList.add('menu-card');
-        const imagePath = item.image.startsWith("uploads/") ? `/${item.image}` : `/uploads/${item.image}`;
-        const img = document.createElement('img');
-        img.src = imagePath;
-        img.alt = item.name;
-        const body = document.createElement('div');
-        body.classList.add('card-body');
-        const title = document.createElement('h5');
-        title.textContent = item.name;
-        const description = document.createElement('p');
-        description.textContent = item.description;
-        const price = document.createElement('p');
-        price.textContent = `$${item.price}`;
-        body.appendChild(title);
-        body.appendChild(description);
-        body.appendChild(price);
-        card.appendChild(img);
-        card.appendChild(body);
-        menuContainer.appendChild(card);
-      });
+      data.forEach(section => renderSection(section, menuContainer, 0));
     } catch (error) {
       console.error('Error fetching menu:', error);
     }
   }
   
-  fetchMenu();
\ No newline at end of file
+  fetchMenu();